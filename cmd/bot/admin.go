@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"feishu-bot/internal/deadletter"
+	"feishu-bot/internal/store"
+)
+
+// adminServer 本地管理接口（死信查看与重投、运行记录与用量查询）
+type adminServer struct {
+	token       string
+	deadLetters *deadletter.Store
+	retrier     *deadletter.Retrier
+	state       store.Store
+}
+
+// startAdminServer 在 addr 上启动管理接口（addr 为空时不启动；未配置 token 时拒绝启动）
+func startAdminServer(addr, token string, deadLetters *deadletter.Store, retrier *deadletter.Retrier, state store.Store) {
+	if addr == "" {
+		return
+	}
+	if token == "" {
+		log.Printf("Admin HTTP server not started: ADMIN_HTTP_TOKEN is required when ADMIN_HTTP_ADDR is set")
+		return
+	}
+
+	srv := &adminServer{token: token, deadLetters: deadLetters, retrier: retrier, state: state}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dead-letters", srv.auth(srv.listDeadLetters))
+	mux.HandleFunc("POST /dead-letters/redeliver", srv.auth(srv.redeliverAll))
+	mux.HandleFunc("POST /dead-letters/{id}/redeliver", srv.auth(srv.redeliverOne))
+	mux.HandleFunc("DELETE /dead-letters/{id}", srv.auth(srv.deleteOne))
+	mux.HandleFunc("GET /runs", srv.auth(srv.listRuns))
+	mux.HandleFunc("GET /usage", srv.auth(srv.listUsage))
+
+	go func() {
+		log.Printf("Admin HTTP server listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Admin HTTP server stopped: %v", err)
+		}
+	}()
+}
+
+// auth 校验 Authorization: Bearer <ADMIN_HTTP_TOKEN>
+func (s *adminServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *adminServer) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	entries := s.deadLetters.List()
+	if receiveID := r.URL.Query().Get("receive_id"); receiveID != "" {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.ReceiveID == receiveID {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *adminServer) redeliverOne(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.retrier.Redeliver(id); errors.Is(err, deadletter.ErrInFlight) {
+		writeJSON(w, http.StatusConflict, map[string]string{"id": id, "error": err.Error()})
+		return
+	} else if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"id": id, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "status": "redelivered"})
+}
+
+func (s *adminServer) redeliverAll(w http.ResponseWriter, r *http.Request) {
+	results := make(map[string]string)
+	for _, e := range s.deadLetters.List() {
+		if err := s.retrier.Redeliver(e.ID); err != nil {
+			results[e.ID] = err.Error()
+		} else {
+			results[e.ID] = "redelivered"
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *adminServer) deleteOne(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.deadLetters.Remove(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"id": id, "error": "not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "status": "deleted"})
+}
+
+// listRuns 列出最近的运行（since 为时长，默认 24h）
+func (s *adminServer) listRuns(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid since: " + v})
+			return
+		}
+		window = d
+	}
+
+	runs, err := s.state.ListRuns(time.Now().Add(-window))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if chatID := r.URL.Query().Get("chat_id"); chatID != "" {
+		filtered := runs[:0]
+		for _, run := range runs {
+			if run.ChatID == chatID {
+				filtered = append(filtered, run)
+			}
+		}
+		runs = filtered
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// listUsage 按天、按会话列出用量（since 为日期 2006-01-02，默认最近 7 天）
+func (s *adminServer) listUsage(w http.ResponseWriter, r *http.Request) {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		since = time.Now().AddDate(0, 0, -6).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", since); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid since: " + since})
+		return
+	}
+
+	records, err := s.state.ListUsage(since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Admin HTTP write failed: %v", err)
+	}
+}
@@ -3,14 +3,21 @@ package main
 import (
 	"bufio"
 	"context"
+	"feishu-bot/internal/bot/card"
 	"feishu-bot/internal/bot/client"
 	"feishu-bot/internal/bot/handlers"
+	"feishu-bot/internal/config"
+	"feishu-bot/internal/deadletter"
+	"feishu-bot/internal/redact"
+	"feishu-bot/internal/store"
 	"feishu-bot/internal/utils"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
@@ -31,17 +38,36 @@ func main() {
 		log.Println("No .env found; relying on existing environment variables")
 	}
 
+	// 日志统一经过脱敏（需在加载 .env 之后初始化，才能收集到敏感环境变量的值）
+	redact.Default()
+	log.SetOutput(redact.NewWriter(os.Stderr))
+
 	// 验证必需的环境变量
 	if err := validateConfig(); err != nil {
 		log.Fatalf("配置验证失败: %v\n请检查 .env 文件是否配置正确", err)
 	}
 
+	// 聊天配置自检：文件无法解析时直接退出，而不是在第一条消息时才报错
+	chatConfig, err := config.Load()
+	if err != nil {
+		log.Fatalf("聊天配置无效（%s）: %v\n请修正或删除该文件后重新启动", config.ConfigFile, err)
+	}
+
+	// 访问控制自检：未配置白名单或管理员时提示
+	if access, err := config.LoadAccess(); err != nil {
+		log.Fatalf("访问控制配置无效: %v", err)
+	} else {
+		for _, warning := range access.Warnings() {
+			log.Printf("WARNING: access control: %s", warning)
+		}
+	}
+
 	// 获取配置
-	appID := getEnv("FEISHU_APP_ID", "")
-	appSecret := getEnv("FEISHU_APP_SECRET", "")
+	appID := utils.GetEnvOrDefault("FEISHU_APP_ID", "")
+	appSecret := utils.GetEnvOrDefault("FEISHU_APP_SECRET", "")
 	log.Printf("Using FEISHU_APP_ID=%s", appID)
 
-	logLevel := getEnv("LOG_LEVEL", "info")
+	logLevel := utils.GetEnvOrDefault("LOG_LEVEL", "info")
 	larkLogLevel := larkcore.LogLevelInfo
 	if logLevel == "debug" {
 		larkLogLevel = larkcore.LogLevelDebug
@@ -62,11 +88,63 @@ func main() {
 	if token, err := feishuClient.GetTenantAccessToken(); err != nil {
 		log.Printf("Tenant token self-check failed: %v", err)
 	} else {
-		log.Printf("Tenant token self-check ok: token=%s", token)
+		log.Printf("Tenant token self-check ok: token_len=%d", len(token))
+	}
+
+	// 死信存储：最终发送失败的消息落盘，后台定期重投
+	deadLetterStore, err := deadletter.Open(utils.GetEnvOrDefault("DEAD_LETTER_FILE", deadletter.DefaultFile))
+	if err != nil {
+		log.Fatalf("Failed to open dead letter store: %v", err)
+	}
+	feishuClient.SetDeadLetterSink(deadLetterStore)
+	retryInterval, err := time.ParseDuration(utils.GetEnvOrDefault("DEAD_LETTER_RETRY_INTERVAL", "5m"))
+	if err != nil || retryInterval <= 0 {
+		log.Printf("Invalid DEAD_LETTER_RETRY_INTERVAL, using 5m: %v", err)
+		retryInterval = 5 * time.Minute
+	}
+	deadLetterTTL, err := time.ParseDuration(utils.GetEnvOrDefault("DEAD_LETTER_TTL", "168h"))
+	if err != nil {
+		log.Printf("Invalid DEAD_LETTER_TTL, using 168h: %v", err)
+		deadLetterTTL = 168 * time.Hour
 	}
+	retrier := deadletter.NewRetrier(deadLetterStore, feishuClient, retryInterval, 12, deadLetterTTL)
+	go retrier.Run(context.Background())
+
+	// 运行状态存储：Claude 会话、项目绑定、消息去重、机器人消息 -> 运行 -> 会话（回复机器人消息时恢复会话）、用量
+	// 首次打开时导入旧版保存在聊天配置中的项目绑定
+	stateStore, err := store.Open(store.Options{
+		Driver:             utils.GetEnvOrDefault("STORE_DRIVER", store.DriverJSON),
+		Path:               utils.GetEnvOrDefault("STORE_PATH", ""),
+		LegacyProjectPaths: chatConfig.ExportProjectPaths(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+	defer stateStore.Close()
+	linkTTL, err := time.ParseDuration(utils.GetEnvOrDefault("SESSION_LINK_TTL", "720h"))
+	if err != nil || linkTTL <= 0 {
+		log.Printf("Invalid SESSION_LINK_TTL, using 720h: %v", err)
+		linkTTL = 720 * time.Hour
+	}
+	go store.RunJanitor(context.Background(), stateStore, time.Hour, linkTTL, handlers.DedupWindow)
+	feishuClient.SetSentSink(handlers.NewSentRecorder(stateStore))
+
+	// 本地管理接口（默认关闭）
+	startAdminServer(utils.GetEnvOrDefault("ADMIN_HTTP_ADDR", ""), utils.GetEnvOrDefault("ADMIN_HTTP_TOKEN", ""), deadLetterStore, retrier, stateStore)
 
 	// 初始化消息处理器
-	messageHandler := handlers.NewMessageHandler(feishuClient)
+	messageHandler := handlers.NewMessageHandler(feishuClient, stateStore)
+	go messageHandler.RunInboxJanitor(context.Background(), time.Hour)
+
+	// 获取机器人自身 open_id，用于准确识别 @机器人；无法确定时拒绝启动，避免把 @其他人 当成 @机器人
+	if botInfo, err := feishuClient.GetBotInfo(); err != nil {
+		log.Printf("Failed to get bot info, falling back to FEISHU_BOT_OPEN_ID: %v", err)
+	} else {
+		messageHandler.SetBotOpenID(botInfo.OpenID)
+	}
+	if messageHandler.BotOpenID() == "" {
+		log.Fatalf("Bot open_id is unknown: bot info API failed and FEISHU_BOT_OPEN_ID is not set")
+	}
 
 	// 注册事件处理器
 	eventHandler := dispatcher.NewEventDispatcher("", "").
@@ -141,28 +219,42 @@ func main() {
 				return &callback.CardActionTriggerResponse{}, nil
 			}
 
-			action, ok := event.Event.Action.Value["action"].(string)
-			if !ok {
-				log.Printf("Cannot parse action from card event")
+			var value cardActionValue
+			if err := card.DecodeValue(event.Event.Action.Value, &value); err != nil || value.Action == "" {
+				log.Printf("Cannot parse action from card event: %v", err)
 				return &callback.CardActionTriggerResponse{}, nil
 			}
+			action := value.Action
 
 			log.Printf("Processing card action: %s", action)
 
 			// 处理不同的action
 			switch action {
-			case "complete_alarm":
+			case handlers.ActionCompleteAlarm:
 				// 读取表单输入值
-				notes := ""
+				var form alarmFormValue
 				if event.Event.Action.FormValue != nil {
-					if n, ok := event.Event.Action.FormValue["notes_input"]; ok {
-						if str, ok := n.(string); ok {
-							notes = str
-						} else {
-							notes = fmt.Sprintf("%v", n)
-						}
+					if err := card.DecodeValue(event.Event.Action.FormValue, &form); err != nil {
+						log.Printf("Cannot parse form value from card event: %v", err)
 					}
 				}
+				notes := string(form.NotesInput)
+
+				// 完成状态卡片：处理人、完成时间、备注
+				openID := ""
+				if event.Event.Operator != nil {
+					openID = event.Event.Operator.OpenID
+				}
+				cardData, err := handlers.AlarmCompletedCard(openID, notes, time.Now()).Map()
+				if err != nil {
+					log.Printf("Failed to build alarm card: %v", err)
+					return &callback.CardActionTriggerResponse{
+						Toast: &callback.Toast{
+							Type:    "error",
+							Content: "卡片渲染失败",
+						},
+					}, nil
+				}
 
 				// 构造响应，更新卡片为完成状态
 				response := &callback.CardActionTriggerResponse{
@@ -171,21 +263,43 @@ func main() {
 						Content: "处理完成！",
 					},
 					Card: &callback.Card{
-						Type: "template",
-						Data: &callback.TemplateCard{
-							TemplateID: "", // 卡片功能已弃用，留空
-							TemplateVariable: map[string]interface{}{
-								"complete_time": time.Now().Format("2006-01-02 15:04:05 (UTC+8)"), // 动态完成时间
-								"notes":         notes,                                            // 用户输入的备注
-								"open_id":       event.Event.Operator.OpenID,                      // 处理人
-							},
-						},
+						Type: "raw",
+						Data: cardData,
 					},
 				}
 
 				log.Printf("Card action processed successfully, notes: %s", notes)
 				return response, nil
 
+			case handlers.ActionBindProject:
+				chatID := ""
+				if event.Event.Context != nil {
+					chatID = event.Event.Context.OpenChatID
+				}
+				openID, userID := "", ""
+				if operator := event.Event.Operator; operator != nil {
+					openID = operator.OpenID
+					if operator.UserID != nil {
+						userID = *operator.UserID
+					}
+				}
+				message, err := messageHandler.HandleBindAction(openID, userID, chatID, value.ChatID, value.Scope, value.Path)
+				if err != nil {
+					log.Printf("Failed to bind project from card: %v", err)
+					return &callback.CardActionTriggerResponse{
+						Toast: &callback.Toast{
+							Type:    "error",
+							Content: err.Error(),
+						},
+					}, nil
+				}
+				return &callback.CardActionTriggerResponse{
+					Toast: &callback.Toast{
+						Type:    "success",
+						Content: message,
+					},
+				}, nil
+
 			default:
 				log.Printf("Unknown card action: %s", action)
 				return &callback.CardActionTriggerResponse{
@@ -207,14 +321,41 @@ func main() {
 	wsClient := larkws.NewClient(appID, appSecret,
 		larkws.WithEventHandler(eventHandler),
 		larkws.WithLogLevel(wsLogLevel),
+		larkws.WithLogger(prefixedLogger{prefix: "LARK-WS"}),
 	)
 
+	// 收到退出信号时关闭存储，写入尚未落盘的修改
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("Received %s, shutting down", sig)
+		if err := stateStore.Close(); err != nil {
+			log.Printf("Failed to close state store: %v", err)
+		}
+		os.Exit(0)
+	}()
+
 	log.Println("Starting WebSocket connection to Feishu...")
 	if err := wsClient.Start(context.Background()); err != nil {
 		log.Fatalf("Failed to start WebSocket client: %v", err)
 	}
 }
 
+// cardActionValue 卡片按钮回传的 value
+type cardActionValue struct {
+	Action string `json:"action"`
+	Token  string `json:"token,omitempty"`
+	Path   string `json:"path,omitempty"`    // bind_project：项目路径
+	Scope  string `json:"scope,omitempty"`   // bind_project：group / p2p
+	ChatID string `json:"chat_id,omitempty"` // bind_project：卡片发出的会话
+}
+
+// alarmFormValue complete_alarm 卡片的表单值
+type alarmFormValue struct {
+	NotesInput card.FormString `json:"notes_input"`
+}
+
 var (
 	buildVersion = "dev"
 	buildTime    = "unknown"
@@ -278,14 +419,6 @@ func writeTraceLine(line string) {
 	_ = os.WriteFile(traceLogPath, []byte(line), 0644)
 }
 
-// getEnv 获取环境变量，如果不存在则使用默认值
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
 func loadDotEnv(paths ...string) (string, bool) {
 	for _, candidate := range paths {
 		path := candidate
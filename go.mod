@@ -2,9 +2,13 @@ module feishu-bot
 
 go 1.22.6
 
-require github.com/larksuite/oapi-sdk-go/v3 v3.4.9
+require (
+	github.com/larksuite/oapi-sdk-go/v3 v3.4.9
+	go.etcd.io/bbolt v1.3.11
+)
 
 require (
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 )
@@ -0,0 +1,128 @@
+// Package card 提供飞书消息卡片 JSON 的类型化构建器
+//
+// 用法示例：
+//
+//	c := card.New().
+//		WithHeader("任务完成", card.HeaderGreen).
+//		Add(card.NewMarkdown("**项目:** demo")).
+//		Add(card.NewHr()).
+//		Add(card.NewAction(card.NewButton("继续", card.ButtonPrimary, map[string]string{"action": "continue"})))
+//	data, err := c.String()
+package card
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 卡片标题颜色
+const (
+	HeaderBlue   = "blue"
+	HeaderGreen  = "green"
+	HeaderRed    = "red"
+	HeaderOrange = "orange"
+	HeaderGrey   = "grey"
+)
+
+// Element 卡片元素（div、markdown、hr、column_set、action、form 等）
+type Element interface {
+	json.Marshaler
+	elementTag() string
+}
+
+// Card 消息卡片
+type Card struct {
+	Config   *Config   `json:"config,omitempty"`
+	Header   *Header   `json:"header,omitempty"`
+	Elements []Element `json:"elements"`
+}
+
+// Config 卡片全局配置
+type Config struct {
+	WideScreenMode bool `json:"wide_screen_mode"`
+	EnableForward  bool `json:"enable_forward"`
+	UpdateMulti    bool `json:"update_multi,omitempty"`
+}
+
+// Header 卡片标题
+type Header struct {
+	Title    *Text  `json:"title"`
+	Template string `json:"template,omitempty"`
+}
+
+// New 创建卡片（默认宽屏、允许转发）
+func New() *Card {
+	return &Card{
+		Config:   &Config{WideScreenMode: true, EnableForward: true},
+		Elements: []Element{},
+	}
+}
+
+// WithHeader 设置标题
+func (c *Card) WithHeader(title, template string) *Card {
+	c.Header = &Header{Title: PlainText(title), Template: template}
+	return c
+}
+
+// WithConfig 设置全局配置
+func (c *Card) WithConfig(config *Config) *Card {
+	c.Config = config
+	return c
+}
+
+// Add 追加元素
+func (c *Card) Add(elements ...Element) *Card {
+	c.Elements = append(c.Elements, elements...)
+	return c
+}
+
+// String 序列化为卡片 JSON（用于 msg_type=interactive 的 content）
+func (c *Card) String() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("序列化卡片失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// Map 序列化为通用 map（用于卡片回调响应中的 raw 卡片）
+func (c *Card) Map() (map[string]interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("序列化卡片失败: %w", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析卡片失败: %w", err)
+	}
+	return out, nil
+}
+
+// Text 文本对象（plain_text 或 lark_md）
+type Text struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+// PlainText 纯文本
+func PlainText(content string) *Text {
+	return &Text{Tag: "plain_text", Content: content}
+}
+
+// LarkMd 支持飞书 markdown 语法的文本
+func LarkMd(content string) *Text {
+	return &Text{Tag: "lark_md", Content: content}
+}
+
+// marshalTagged 序列化元素并在最前面插入 "tag" 字段，保证输出稳定
+func marshalTagged(tag string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	head := []byte(fmt.Sprintf(`{"tag":%q`, tag))
+	if string(data) == "{}" {
+		return append(head, '}'), nil
+	}
+	return append(append(head, ','), data[1:]...), nil
+}
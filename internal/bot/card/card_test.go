@@ -0,0 +1,134 @@
+package card
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// assertGolden 比较卡片 JSON 与 testdata/<name>.golden.json（go test -update 重新生成）
+func assertGolden(t *testing.T, name string, c *Card) {
+	t.Helper()
+	data, err := c.String()
+	if err != nil {
+		t.Fatalf("String() error: %v", err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(data), "", "  "); err != nil {
+		t.Fatalf("card is not valid JSON: %v\n%s", err, data)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Errorf("%s mismatch\n--- got\n%s\n--- want\n%s", path, indented.Bytes(), want)
+	}
+}
+
+func TestBuilderGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		card *Card
+	}{
+		{
+			name: "empty",
+			card: New(),
+		},
+		{
+			name: "text",
+			card: New().
+				WithHeader("任务完成", HeaderGreen).
+				Add(NewDiv("**项目:** demo")).
+				Add(NewFields("**耗时:** 1.0 秒", "**花费:** $0.0100")).
+				Add(NewMarkdown("第一行\n第二行")).
+				Add(NewHr()).
+				Add(NewNote("💡 提示")),
+		},
+		{
+			name: "columns",
+			card: New().
+				WithConfig(&Config{WideScreenMode: true}).
+				Add(NewColumnSet(
+					NewColumn(2, NewMarkdown("左")),
+					NewAutoColumn(NewMarkdown("右")),
+				)),
+		},
+		{
+			name: "actions",
+			card: New().
+				WithHeader("审批", HeaderOrange).
+				Add(NewAction(
+					NewButton("同意", ButtonPrimary, map[string]string{"action": "approve", "token": "t1"}),
+					NewButton("拒绝", ButtonDanger, map[string]string{"action": "reject"}).WithConfirm("确认拒绝？", "拒绝后无法撤销"),
+					NewLinkButton("详情", "https://example.com/detail"),
+				)).
+				Add(&Div{Text: LarkMd("**#1 api**"), Extra: NewButton("绑定", ButtonPrimary, struct {
+					Action string `json:"action"`
+					Path   string `json:"path"`
+				}{Action: "bind_project", Path: "/srv/api"})}),
+		},
+		{
+			name: "form",
+			card: New().
+				WithHeader("告警", HeaderRed).
+				Add(NewForm("alarm_form",
+					&Input{Name: "notes_input", Placeholder: PlainText("备注"), Label: PlainText("处理备注"), MaxLength: 200, InputType: "multiline_text"},
+					NewSelectStatic("level", "级别", NewOption("高", "high"), NewOption("低", "low")),
+					NewSubmitButton("submit", "处理完成", map[string]string{"action": "complete_alarm"}),
+				)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertGolden(t, tt.name, tt.card)
+		})
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	var value struct {
+		Action string     `json:"action"`
+		Notes  FormString `json:"notes_input"`
+	}
+	tests := []struct {
+		raw  map[string]interface{}
+		want FormString
+	}{
+		{map[string]interface{}{"notes_input": "已重启"}, "已重启"},
+		{map[string]interface{}{"notes_input": float64(42)}, "42"},
+		{map[string]interface{}{"notes_input": float64(1000000)}, "1000000"},
+		{map[string]interface{}{"notes_input": 1.5}, "1.5"},
+		{map[string]interface{}{"notes_input": true}, "true"},
+		{map[string]interface{}{"notes_input": []interface{}{"a", float64(2)}}, "a,2"},
+		{map[string]interface{}{"notes_input": nil}, ""},
+		{map[string]interface{}{}, ""},
+	}
+	for _, tt := range tests {
+		value.Notes = ""
+		if err := DecodeValue(tt.raw, &value); err != nil {
+			t.Errorf("DecodeValue(%v) error: %v", tt.raw, err)
+			continue
+		}
+		if value.Notes != tt.want {
+			t.Errorf("DecodeValue(%v) notes = %q, want %q", tt.raw, value.Notes, tt.want)
+		}
+	}
+
+	if err := DecodeValue(map[string]interface{}{"action": 1}, &value); err == nil {
+		t.Error("DecodeValue with non-string action: want error")
+	}
+}
@@ -0,0 +1,153 @@
+package card
+
+// Div 文本块（可包含并排字段）
+type Div struct {
+	Text   *Text    `json:"text,omitempty"`
+	Fields []*Field `json:"fields,omitempty"`
+	Extra  Element  `json:"extra,omitempty"`
+}
+
+// Field Div 中的字段
+type Field struct {
+	IsShort bool  `json:"is_short"`
+	Text    *Text `json:"text"`
+}
+
+// NewDiv 创建 lark_md 文本块
+func NewDiv(content string) *Div {
+	return &Div{Text: LarkMd(content)}
+}
+
+// NewFields 创建由并排字段组成的文本块（每个字段都是 lark_md 短字段）
+func NewFields(contents ...string) *Div {
+	div := &Div{}
+	for _, content := range contents {
+		div.Fields = append(div.Fields, &Field{IsShort: true, Text: LarkMd(content)})
+	}
+	return div
+}
+
+func (d *Div) elementTag() string { return "div" }
+
+// MarshalJSON 实现 json.Marshaler
+func (d *Div) MarshalJSON() ([]byte, error) {
+	type alias Div
+	return marshalTagged(d.elementTag(), (*alias)(d))
+}
+
+// Markdown markdown 组件
+type Markdown struct {
+	Content   string `json:"content"`
+	TextAlign string `json:"text_align,omitempty"`
+}
+
+// NewMarkdown 创建 markdown 组件
+func NewMarkdown(content string) *Markdown {
+	return &Markdown{Content: content}
+}
+
+func (m *Markdown) elementTag() string { return "markdown" }
+
+// MarshalJSON 实现 json.Marshaler
+func (m *Markdown) MarshalJSON() ([]byte, error) {
+	type alias Markdown
+	return marshalTagged(m.elementTag(), (*alias)(m))
+}
+
+// Hr 分割线
+type Hr struct{}
+
+// NewHr 创建分割线
+func NewHr() *Hr {
+	return &Hr{}
+}
+
+func (h *Hr) elementTag() string { return "hr" }
+
+// MarshalJSON 实现 json.Marshaler
+func (h *Hr) MarshalJSON() ([]byte, error) {
+	return marshalTagged(h.elementTag(), struct{}{})
+}
+
+// Note 备注（灰色小字）
+type Note struct {
+	Elements []*Text `json:"elements"`
+}
+
+// NewNote 创建纯文本备注
+func NewNote(content string) *Note {
+	return &Note{Elements: []*Text{PlainText(content)}}
+}
+
+func (n *Note) elementTag() string { return "note" }
+
+// MarshalJSON 实现 json.Marshaler
+func (n *Note) MarshalJSON() ([]byte, error) {
+	type alias Note
+	return marshalTagged(n.elementTag(), (*alias)(n))
+}
+
+// ColumnSet 多列布局
+type ColumnSet struct {
+	FlexMode        string    `json:"flex_mode,omitempty"`
+	BackgroundStyle string    `json:"background_style,omitempty"`
+	Columns         []*Column `json:"columns"`
+}
+
+// NewColumnSet 创建多列布局
+func NewColumnSet(columns ...*Column) *ColumnSet {
+	return &ColumnSet{FlexMode: "none", Columns: columns}
+}
+
+func (cs *ColumnSet) elementTag() string { return "column_set" }
+
+// MarshalJSON 实现 json.Marshaler
+func (cs *ColumnSet) MarshalJSON() ([]byte, error) {
+	type alias ColumnSet
+	return marshalTagged(cs.elementTag(), (*alias)(cs))
+}
+
+// Column 列
+type Column struct {
+	Width         string    `json:"width,omitempty"` // auto / weighted
+	Weight        int       `json:"weight,omitempty"`
+	VerticalAlign string    `json:"vertical_align,omitempty"`
+	Elements      []Element `json:"elements"`
+}
+
+// NewColumn 创建按权重分配宽度的列
+func NewColumn(weight int, elements ...Element) *Column {
+	return &Column{Width: "weighted", Weight: weight, VerticalAlign: "center", Elements: elements}
+}
+
+// NewAutoColumn 创建宽度自适应的列
+func NewAutoColumn(elements ...Element) *Column {
+	return &Column{Width: "auto", VerticalAlign: "center", Elements: elements}
+}
+
+func (c *Column) elementTag() string { return "column" }
+
+// MarshalJSON 实现 json.Marshaler
+func (c *Column) MarshalJSON() ([]byte, error) {
+	type alias Column
+	return marshalTagged(c.elementTag(), (*alias)(c))
+}
+
+// Action 交互模块（按钮、下拉框等）
+type Action struct {
+	Actions []Element `json:"actions"`
+	Layout  string    `json:"layout,omitempty"` // bisected / trisection / flow
+}
+
+// NewAction 创建交互模块
+func NewAction(actions ...Element) *Action {
+	return &Action{Actions: actions}
+}
+
+func (a *Action) elementTag() string { return "action" }
+
+// MarshalJSON 实现 json.Marshaler
+func (a *Action) MarshalJSON() ([]byte, error) {
+	type alias Action
+	return marshalTagged(a.elementTag(), (*alias)(a))
+}
@@ -0,0 +1,183 @@
+package card
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 按钮样式
+const (
+	ButtonDefault = "default"
+	ButtonPrimary = "primary"
+	ButtonDanger  = "danger"
+)
+
+// Button 按钮；Value 会原样出现在卡片回调的 action.value 中
+type Button struct {
+	Text       *Text       `json:"text"`
+	Type       string      `json:"type,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+	URL        string      `json:"url,omitempty"`
+	Name       string      `json:"name,omitempty"`        // 表单内按钮的名称
+	ActionType string      `json:"action_type,omitempty"` // 表单内按钮：form_submit / form_reset
+	Confirm    *Confirm    `json:"confirm,omitempty"`
+}
+
+// Confirm 二次确认弹窗
+type Confirm struct {
+	Title *Text `json:"title"`
+	Text  *Text `json:"text"`
+}
+
+// NewButton 创建回传交互按钮（value 可以是 map 或带 json tag 的结构体）
+func NewButton(text, buttonType string, value interface{}) *Button {
+	return &Button{Text: PlainText(text), Type: buttonType, Value: value}
+}
+
+// NewLinkButton 创建跳转链接按钮
+func NewLinkButton(text, url string) *Button {
+	return &Button{Text: PlainText(text), Type: ButtonDefault, URL: url}
+}
+
+// NewSubmitButton 创建表单提交按钮
+func NewSubmitButton(name, text string, value interface{}) *Button {
+	return &Button{Text: PlainText(text), Type: ButtonPrimary, Name: name, ActionType: "form_submit", Value: value}
+}
+
+// WithConfirm 设置二次确认
+func (b *Button) WithConfirm(title, text string) *Button {
+	b.Confirm = &Confirm{Title: PlainText(title), Text: PlainText(text)}
+	return b
+}
+
+func (b *Button) elementTag() string { return "button" }
+
+// MarshalJSON 实现 json.Marshaler
+func (b *Button) MarshalJSON() ([]byte, error) {
+	type alias Button
+	return marshalTagged(b.elementTag(), (*alias)(b))
+}
+
+// Form 表单容器，提交时回调的 form_value 以组件 name 为键
+type Form struct {
+	Name     string    `json:"name"`
+	Elements []Element `json:"elements"`
+}
+
+// NewForm 创建表单
+func NewForm(name string, elements ...Element) *Form {
+	return &Form{Name: name, Elements: elements}
+}
+
+func (f *Form) elementTag() string { return "form" }
+
+// MarshalJSON 实现 json.Marshaler
+func (f *Form) MarshalJSON() ([]byte, error) {
+	type alias Form
+	return marshalTagged(f.elementTag(), (*alias)(f))
+}
+
+// Input 输入框
+type Input struct {
+	Name         string `json:"name"`
+	Required     bool   `json:"required,omitempty"`
+	Placeholder  *Text  `json:"placeholder,omitempty"`
+	DefaultValue string `json:"default_value,omitempty"`
+	Label        *Text  `json:"label,omitempty"`
+	MaxLength    int    `json:"max_length,omitempty"`
+	InputType    string `json:"input_type,omitempty"` // text / multiline_text / password
+}
+
+// NewInput 创建输入框
+func NewInput(name, placeholder string) *Input {
+	return &Input{Name: name, Placeholder: PlainText(placeholder)}
+}
+
+func (i *Input) elementTag() string { return "input" }
+
+// MarshalJSON 实现 json.Marshaler
+func (i *Input) MarshalJSON() ([]byte, error) {
+	type alias Input
+	return marshalTagged(i.elementTag(), (*alias)(i))
+}
+
+// SelectStatic 下拉单选
+type SelectStatic struct {
+	Name          string      `json:"name,omitempty"`
+	Placeholder   *Text       `json:"placeholder,omitempty"`
+	InitialOption string      `json:"initial_option,omitempty"`
+	Options       []*Option   `json:"options"`
+	Value         interface{} `json:"value,omitempty"`
+}
+
+// Option 下拉选项
+type Option struct {
+	Text  *Text  `json:"text"`
+	Value string `json:"value"`
+}
+
+// NewSelectStatic 创建下拉单选
+func NewSelectStatic(name, placeholder string, options ...*Option) *SelectStatic {
+	return &SelectStatic{Name: name, Placeholder: PlainText(placeholder), Options: options}
+}
+
+// NewOption 创建下拉选项
+func NewOption(text, value string) *Option {
+	return &Option{Text: PlainText(text), Value: value}
+}
+
+func (s *SelectStatic) elementTag() string { return "select_static" }
+
+// MarshalJSON 实现 json.Marshaler
+func (s *SelectStatic) MarshalJSON() ([]byte, error) {
+	type alias SelectStatic
+	return marshalTagged(s.elementTag(), (*alias)(s))
+}
+
+// DecodeValue 将卡片回调中 map 形式的 action.value / form_value 解码到结构体
+func DecodeValue(raw map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("序列化回调值失败: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析回调值失败: %w", err)
+	}
+	return nil
+}
+
+// FormString 表单值：输入框回传字符串，数字输入、多选等组件回传数字、布尔或数组，统一转换为字符串
+// （数组以逗号连接，null 为空字符串）
+type FormString string
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (s *FormString) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // 保留数字原样，避免 1e+06 之类的格式
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+	*s = FormString(formatFormValue(v))
+	return nil
+}
+
+// formatFormValue 将任意 JSON 值格式化为字符串
+func formatFormValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = formatFormValue(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
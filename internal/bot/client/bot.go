@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// BotInfo 机器人自身信息
+type BotInfo struct {
+	AppName string `json:"app_name"`
+	OpenID  string `json:"open_id"`
+}
+
+// AppID 返回应用 ID（机器人发出的消息 sender.id 即为 app_id）
+func (fc *FeishuClient) AppID() string {
+	return fc.appID
+}
+
+// GetBotInfo 获取机器人自身信息（/open-apis/bot/v3/info）
+func (fc *FeishuClient) GetBotInfo() (*BotInfo, error) {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	apiResp, err := fc.client.Get(context.Background(), "/open-apis/bot/v3/info", nil,
+		larkcore.AccessTokenTypeTenant, larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot info: %w", err)
+	}
+
+	var result struct {
+		larkcore.CodeError
+		Bot *BotInfo `json:"bot"`
+	}
+	if err := json.Unmarshal(apiResp.RawBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse bot info: %w", err)
+	}
+	if apiResp.StatusCode != http.StatusOK || result.Code != 0 {
+		return nil, newFeishuError(result.CodeError, apiResp)
+	}
+	if result.Bot == nil || result.Bot.OpenID == "" {
+		return nil, fmt.Errorf("bot info: empty open_id")
+	}
+
+	log.Printf("[FeishuClient] Bot info: app_name=%s open_id=%s", result.Bot.AppName, result.Bot.OpenID)
+	return result.Bot, nil
+}
+
+// GetMessage 获取单条消息
+func (fc *FeishuClient) GetMessage(messageID string) (*larkim.Message, error) {
+	items, err := fc.GetMessageItems(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 || items[0] == nil {
+		return nil, fmt.Errorf("message not found: %s", messageID)
+	}
+	return items[0], nil
+}
+
+// GetMessageItems 获取消息及其子消息（合并转发消息会返回自身和被转发的全部消息，
+// 子消息的 upper_message_id 指向上一层合并转发消息）
+func (fc *FeishuClient) GetMessageItems(messageID string) ([]*larkim.Message, error) {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := fc.client.Im.Message.Get(context.Background(), larkim.NewGetMessageReqBuilder().
+		MessageId(messageID).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if !resp.Success() {
+		return nil, newFeishuError(resp.CodeError, resp.ApiResp)
+	}
+	if resp.Data == nil {
+		return nil, nil
+	}
+	return resp.Data.Items, nil
+}
+
+// ListRecentMessages 获取群聊最近的消息（按时间倒序，最多 50 条）
+func (fc *FeishuClient) ListRecentMessages(chatID string, limit int) ([]*larkim.Message, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := fc.client.Im.Message.List(context.Background(), larkim.NewListMessageReqBuilder().
+		ContainerIdType("chat").
+		ContainerId(chatID).
+		SortType("ByCreateTimeDesc").
+		PageSize(limit).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	if !resp.Success() {
+		return nil, newFeishuError(resp.CodeError, resp.ApiResp)
+	}
+	if resp.Data == nil {
+		return nil, nil
+	}
+	return resp.Data.Items, nil
+}
+
+// IsBotMessage 判断消息是否由本应用发送
+func (fc *FeishuClient) IsBotMessage(message *larkim.Message) bool {
+	if message == nil || message.Sender == nil || message.Sender.SenderType == nil || message.Sender.Id == nil {
+		return false
+	}
+	return *message.Sender.SenderType == "app" && *message.Sender.Id == fc.appID
+}
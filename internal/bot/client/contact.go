@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkcontact "github.com/larksuite/oapi-sdk-go/v3/service/contact/v3"
+)
+
+const (
+	defaultUserNameTTL = time.Hour       // 用户信息缓存默认有效期
+	userNameFailureTTL = 5 * time.Minute // 查询失败的缓存时长，避免无权限时反复请求
+)
+
+// UserInfo 通讯录中的用户信息
+type UserInfo struct {
+	Name          string
+	DepartmentIDs []string // open_department_id
+}
+
+// userInfoEntry 用户信息缓存项
+type userInfoEntry struct {
+	info      *UserInfo
+	err       error
+	expiresAt time.Time
+}
+
+// userInfoCache open_id -> 用户信息
+type userInfoCache struct {
+	mu      sync.Mutex
+	entries map[string]userInfoEntry
+}
+
+// GetUserName 获取用户显示名（带缓存，有效期 USER_NAME_CACHE_TTL），需要通讯录读取权限
+func (fc *FeishuClient) GetUserName(openID string) (string, error) {
+	info, err := fc.GetUserInfo(openID)
+	if err != nil {
+		return "", err
+	}
+	return info.Name, nil
+}
+
+// GetUserInfo 获取用户信息（带缓存，有效期 USER_NAME_CACHE_TTL），需要通讯录读取权限
+func (fc *FeishuClient) GetUserInfo(openID string) (*UserInfo, error) {
+	fc.userInfos.mu.Lock()
+	entry, ok := fc.userInfos.entries[openID]
+	fc.userInfos.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.info, entry.err
+	}
+
+	info, err := fc.fetchUserInfo(openID)
+	ttl := userNameTTL()
+	if err != nil {
+		ttl = userNameFailureTTL
+	}
+
+	fc.userInfos.mu.Lock()
+	if fc.userInfos.entries == nil {
+		fc.userInfos.entries = make(map[string]userInfoEntry)
+	}
+	// 顺带清理过期项，避免缓存无限增长
+	now := time.Now()
+	for id, e := range fc.userInfos.entries {
+		if now.After(e.expiresAt) {
+			delete(fc.userInfos.entries, id)
+		}
+	}
+	fc.userInfos.entries[openID] = userInfoEntry{info: info, err: err, expiresAt: now.Add(ttl)}
+	fc.userInfos.mu.Unlock()
+	return info, err
+}
+
+// fetchUserInfo 通过通讯录接口查询用户信息
+func (fc *FeishuClient) fetchUserInfo(openID string) (*UserInfo, error) {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := fc.client.Contact.User.Get(context.Background(), larkcontact.NewGetUserReqBuilder().
+		UserId(openID).
+		UserIdType("open_id").
+		DepartmentIdType("open_department_id").
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !resp.Success() {
+		return nil, newFeishuError(resp.CodeError, resp.ApiResp)
+	}
+	if resp.Data == nil || resp.Data.User == nil || resp.Data.User.Name == nil {
+		return nil, fmt.Errorf("user not found: %s", openID)
+	}
+	return &UserInfo{
+		Name:          *resp.Data.User.Name,
+		DepartmentIDs: resp.Data.User.DepartmentIds,
+	}, nil
+}
+
+// userNameTTL 用户信息缓存有效期（USER_NAME_CACHE_TTL）
+func userNameTTL() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("USER_NAME_CACHE_TTL")); err == nil && value > 0 {
+		return value
+	}
+	return defaultUserNameTTL
+}
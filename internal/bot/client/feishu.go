@@ -8,9 +8,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"feishu-bot/internal/bot/card"
+	"feishu-bot/internal/redact"
+
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
@@ -25,6 +29,8 @@ type FeishuClient struct {
 	tenantAccessToken string
 	tokenExpireTime   time.Time
 	tokenMutex        sync.RWMutex
+	outbox            *Outbox
+	userInfos         userInfoCache
 }
 
 // FeishuConfig 飞书配置
@@ -37,13 +43,15 @@ type FeishuConfig struct {
 func NewFeishuClient(config FeishuConfig) *FeishuClient {
 	client := lark.NewClient(config.AppID, config.AppSecret)
 
-	return &FeishuClient{
+	fc := &FeishuClient{
 		client:          client,
 		httpClient:      &http.Client{Timeout: 10 * time.Second},
 		appID:           config.AppID,
 		appSecret:       config.AppSecret,
 		tokenExpireTime: time.Now(), // 初始化为过去时间，强制首次获取 token
 	}
+	fc.outbox = NewOutbox(fc, DefaultRetryPolicy())
+	return fc
 }
 
 // GetClient 获取原始客户端（用于高级操作）
@@ -53,9 +61,11 @@ func (fc *FeishuClient) GetClient() *lark.Client {
 
 // FeishuError 飞书API错误
 type FeishuError struct {
-	Code      int    `json:"code"`
-	Message   string `json:"message"`
-	RequestID string `json:"request_id"`
+	Code       int           `json:"code"`
+	Message    string        `json:"message"`
+	RequestID  string        `json:"request_id"`
+	HTTPStatus int           `json:"-"`
+	RetryAfter time.Duration `json:"-"` // 限流时 x-ogw-ratelimit-reset 给出的等待时间
 }
 
 func (e *FeishuError) Error() string {
@@ -101,7 +111,7 @@ func (fc *FeishuClient) GetTenantAccessToken() (string, error) {
 		AppID:     fc.appID,
 		AppSecret: fc.appSecret,
 	}
-	log.Printf("[FeishuClient] tenant token request: app_id=%s app_secret=%s at=%s", fc.appID, fc.appSecret, time.Now().Format(time.RFC3339))
+	log.Printf("[FeishuClient] tenant token request: app_id=%s at=%s", fc.appID, time.Now().Format(time.RFC3339))
 
 	jsonData, err := json.Marshal(reqData)
 	if err != nil {
@@ -124,7 +134,7 @@ func (fc *FeishuClient) GetTenantAccessToken() (string, error) {
 	}
 	defer resp.Body.Close()
 
-	body, err := httpMaxBytesReader(resp.Body, 1<<20) // 限制 1MB
+	body, err := readLimited(resp.Body, 1<<20) // 限制 1MB
 	if err != nil {
 		return "", fmt.Errorf("failed to read response: %w", err)
 	}
@@ -141,6 +151,9 @@ func (fc *FeishuClient) GetTenantAccessToken() (string, error) {
 	}
 	log.Printf("[FeishuClient] tenant token response: http=%d cost_ms=%d code=%d expire_s=%d", resp.StatusCode, time.Since(start).Milliseconds(), result.Code, result.Expire)
 
+	// 运行时获取的 token 同样加入脱敏
+	redact.Default().AddValue(result.TenantAccessToken)
+
 	// 缓存 token（提前 5 分钟过期）
 	fc.tenantAccessToken = result.TenantAccessToken
 	fc.tokenExpireTime = time.Now().Add(time.Duration(result.Expire-300) * time.Second)
@@ -148,50 +161,183 @@ func (fc *FeishuClient) GetTenantAccessToken() (string, error) {
 	return result.TenantAccessToken, nil
 }
 
-// httpMaxBytesReader 限制读取最大字节数
-func httpMaxBytesReader(r io.Reader, maxBytes int64) ([]byte, error) {
+// readLimited 最多读取 maxBytes 字节（超出部分不读取，也不报错）
+func readLimited(r io.Reader, maxBytes int64) ([]byte, error) {
 	limited := io.LimitReader(r, maxBytes)
 	return io.ReadAll(limited)
 }
 
-// SendMessage 发送消息（支持多种 receive_id_type）
+// SendMessage 发送文本消息（经出站队列按序发送，失败自动重试）
 func (fc *FeishuClient) SendMessage(receiveID, receiveIDType, content string) error {
-	// 按照飞书文本消息格式要求，内容必须是 JSON 字符串
-	textContent := map[string]string{"text": content}
-	jsonContent, err := json.Marshal(textContent)
+	return <-fc.EnqueueMessage(receiveID, receiveIDType, content, "")
+}
+
+// EnqueueMessage 将文本消息加入出站队列（不阻塞），返回的 channel 收到最终发送结果
+// runID 标识产生该消息的运行，发送失败进入死信时一并记录；文本在序列化前脱敏
+func (fc *FeishuClient) EnqueueMessage(receiveID, receiveIDType, content, runID string) <-chan error {
+	return fc.EnqueueFormatted(receiveID, receiveIDType, FormatText, content, runID)
+}
+
+// 消息格式（与会话设置 response_mode 对应）
+const (
+	FormatText = "text" // 纯文本
+	FormatPost = "post" // 富文本（md 标签渲染 Markdown）
+	FormatCard = "card" // 消息卡片（markdown 组件）
+)
+
+// EnqueueFormatted 按指定格式将 Markdown 文本加入出站队列；未知格式按纯文本发送
+// 文本在序列化前脱敏（JSON 转义后的敏感值无法再匹配）
+func (fc *FeishuClient) EnqueueFormatted(receiveID, receiveIDType, format, content, runID string) <-chan error {
+	if redacted, n := redact.Default().Redact(content); n > 0 {
+		log.Printf("[FeishuClient] Redacted %d secret(s) from outbound message: receive_id=%s run_id=%s", n, receiveID, runID)
+		content = redacted
+	}
+	return fc.EnqueueRedacted(receiveID, receiveIDType, format, content, runID)
+}
+
+// EnqueueRedacted 与 EnqueueFormatted 相同，但 content 已由调用方脱敏（如流式分段），不再重复处理
+func (fc *FeishuClient) EnqueueRedacted(receiveID, receiveIDType, format, content, runID string) <-chan error {
+	var msgType, jsonContent string
+	var err error
+	switch format {
+	case FormatPost:
+		msgType = "post"
+		jsonContent, err = postContent(content)
+	case FormatCard:
+		msgType = "interactive"
+		jsonContent, err = markdownCard(content).String()
+	default:
+		msgType = "text"
+		jsonContent, err = textContent(content)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal text content: %w", err)
+		return completedResult(err)
 	}
 
+	return fc.outbox.Enqueue(&OutboundMessage{
+		ReceiveID:     receiveID,
+		ReceiveIDType: receiveIDType,
+		MsgType:       msgType,
+		Content:       jsonContent,
+		RunID:         runID,
+	})
+}
+
+// Redeliver 重新投递一条消息（用于死信重投）
+func (fc *FeishuClient) Redeliver(msg *OutboundMessage) <-chan error {
+	return fc.outbox.Enqueue(msg)
+}
+
+// SetDeadLetterSink 设置发送最终失败消息的接收者
+func (fc *FeishuClient) SetDeadLetterSink(sink DeadLetterSink) {
+	fc.outbox.SetDeadLetterSink(sink)
+}
+
+// SetSentSink 设置发送成功消息的接收者
+func (fc *FeishuClient) SetSentSink(sink SentSink) {
+	fc.outbox.SetSentSink(sink)
+}
+
+// SendCard 发送交互式卡片消息（cardJSON 为完整的卡片 JSON），runID 为空表示不属于任何运行
+// 卡片内容不会再脱敏，调用方需在渲染前对变量脱敏
+func (fc *FeishuClient) SendCard(receiveID, receiveIDType, cardJSON, runID string) error {
+	return fc.outbox.Send(&OutboundMessage{
+		ReceiveID:     receiveID,
+		ReceiveIDType: receiveIDType,
+		MsgType:       "interactive",
+		Content:       cardJSON,
+		RunID:         runID,
+	})
+}
+
+// textContent 按照飞书文本消息格式要求，将文本包装为 JSON 字符串
+func textContent(text string) (string, error) {
+	jsonContent, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal text content: %w", err)
+	}
+	return string(jsonContent), nil
+}
+
+// markdownCard 以单个 markdown 组件承载回答的卡片
+func markdownCard(content string) *card.Card {
+	return card.New().
+		WithConfig(&card.Config{WideScreenMode: true, EnableForward: true}).
+		Add(card.NewMarkdown(content))
+}
+
+// postContent 将 Markdown 文本包装为富文本消息内容（单个 md 段落）
+func postContent(markdown string) (string, error) {
+	jsonContent, err := json.Marshal(map[string]interface{}{
+		"zh_cn": map[string]interface{}{
+			"content": [][]map[string]string{{{"tag": "md", "text": markdown}}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal post content: %w", err)
+	}
+	return string(jsonContent), nil
+}
+
+// completedResult 返回已包含结果的 channel
+func completedResult(err error) <-chan error {
+	ch := make(chan error, 1)
+	ch <- err
+	close(ch)
+	return ch
+}
+
+// sendOnce 调用消息发送接口（单次尝试，不重试），返回消息的 message_id
+func (fc *FeishuClient) sendOnce(receiveID, receiveIDType, msgType, content string) (string, error) {
 	token, err := fc.GetTenantAccessToken()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// 根据不同的 receive_id_type 构建
 	resp, err := fc.client.Im.Message.Create(context.Background(), larkim.NewCreateMessageReqBuilder().
 		ReceiveIdType(receiveIDType).
 		Body(larkim.NewCreateMessageReqBodyBuilder().
-			MsgType("text").
+			MsgType(msgType).
 			ReceiveId(receiveID).
-			Content(string(jsonContent)).
+			Content(content).
 			Build()).
 		Build(), larkcore.WithTenantAccessToken(token))
 
 	if err != nil {
-		return fmt.Errorf("failed to create message: %w", err)
+		return "", fmt.Errorf("failed to create message: %w", err)
 	}
 
 	if !resp.Success() {
-		return &FeishuError{
-			Code:      resp.Code,
-			Message:   resp.Msg,
-			RequestID: resp.RequestId(),
-		}
+		return "", newFeishuError(resp.CodeError, resp.ApiResp)
 	}
 
-	log.Printf("[FeishuClient] Message sent: receive_id=%s receive_id_type=%s len=%d msg_id=%s",
-		receiveID, receiveIDType, len(content), *resp.Data.MessageId)
+	messageID := ""
+	if resp.Data != nil && resp.Data.MessageId != nil {
+		messageID = *resp.Data.MessageId
+	}
+	log.Printf("[FeishuClient] Message sent: receive_id=%s receive_id_type=%s msg_type=%s len=%d msg_id=%s",
+		receiveID, receiveIDType, msgType, len(content), messageID)
+
+	return messageID, nil
+}
+
+// newFeishuError 根据接口响应构造错误（带 HTTP 状态码和限流等待时间）
+func newFeishuError(codeErr larkcore.CodeError, apiResp *larkcore.ApiResp) *FeishuError {
+	feishuErr := &FeishuError{
+		Code:    codeErr.Code,
+		Message: codeErr.Msg,
+	}
+	if apiResp == nil {
+		return feishuErr
+	}
 
-	return nil
+	feishuErr.RequestID = apiResp.RequestId()
+	feishuErr.HTTPStatus = apiResp.StatusCode
+	if reset := apiResp.Header.Get("x-ogw-ratelimit-reset"); reset != "" {
+		if seconds, err := strconv.Atoi(reset); err == nil && seconds > 0 {
+			feishuErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return feishuErr
 }
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestMarkdownCardGolden(t *testing.T) {
+	data, err := markdownCard("**结论**\n- 第一点\n- 第二点").String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(data), "", "  "); err != nil {
+		t.Fatalf("card is not valid JSON: %v\n%s", err, data)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", "markdown_card.golden.json")
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Errorf("%s mismatch\n--- got\n%s\n--- want\n%s", path, indented.Bytes(), want)
+	}
+}
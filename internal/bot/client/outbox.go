@@ -0,0 +1,254 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 飞书限流相关错误码
+var rateLimitCodes = map[int]bool{
+	99991400: true, // 应用请求频率超限
+	230020:   true, // 发送消息频率超限
+	11232:    true, // 群消息发送频率超限
+}
+
+// 服务端临时错误码（可重试）
+var transientCodes = map[int]bool{
+	1000:     true, // 服务内部错误
+	1500:     true, // 服务内部错误
+	99991672: true, // 服务繁忙
+	230004:   true, // 内部错误，请稍后重试
+}
+
+// RetryPolicy 发送重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（含首次）
+	BaseDelay   time.Duration // 首次重试等待时间
+	MaxDelay    time.Duration // 单次等待上限
+}
+
+// DefaultRetryPolicy 默认重试策略：最多 5 次，指数退避 0.5s ~ 10s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// backoff 第 attempt 次失败后的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// OutboundMessage 待发送的消息
+type OutboundMessage struct {
+	ReceiveID     string
+	ReceiveIDType string
+	MsgType       string // text / interactive / post / file
+	Content       string // 已序列化的 content JSON
+	RunID         string // 产生该消息的运行（触发消息的 message_id）
+	DeadLetterID  string // 从死信重新投递时的死信 ID
+	MessageID     string // 发送成功后飞书返回的 message_id
+	notice        bool   // 发送失败通知本身（失败时不再通知）
+	done          chan error
+}
+
+// DeadLetterSink 接收最终发送失败的消息（用于持久化和后续重投）
+type DeadLetterSink interface {
+	Add(msg *OutboundMessage, err error)
+}
+
+// SentSink 接收发送成功的消息（用于记录 message_id 与运行的对应关系）
+type SentSink interface {
+	Sent(msg *OutboundMessage)
+}
+
+// Outbox 出站消息队列
+// 同一接收者的消息严格按入队顺序串行发送；临时错误和限流错误按退避策略重试；
+// 最终失败时向接收者发送一条失败提示。
+type Outbox struct {
+	client      *FeishuClient
+	policy      RetryPolicy
+	mu          sync.Mutex
+	queues      map[string]*receiverQueue
+	deadLetters DeadLetterSink
+	sent        SentSink
+}
+
+// receiverQueue 单个接收者的发送队列
+type receiverQueue struct {
+	pending []*OutboundMessage
+	running bool
+}
+
+// NewOutbox 创建出站消息队列
+func NewOutbox(client *FeishuClient, policy RetryPolicy) *Outbox {
+	return &Outbox{
+		client: client,
+		policy: policy,
+		queues: make(map[string]*receiverQueue),
+	}
+}
+
+// SetDeadLetterSink 设置死信接收者
+func (o *Outbox) SetDeadLetterSink(sink DeadLetterSink) {
+	o.mu.Lock()
+	o.deadLetters = sink
+	o.mu.Unlock()
+}
+
+// SetSentSink 设置发送成功消息的接收者
+func (o *Outbox) SetSentSink(sink SentSink) {
+	o.mu.Lock()
+	o.sent = sink
+	o.mu.Unlock()
+}
+
+// Enqueue 将消息加入接收者队列（不阻塞），返回的 channel 在发送完成或最终失败后收到结果
+func (o *Outbox) Enqueue(msg *OutboundMessage) <-chan error {
+	msg.done = make(chan error, 1)
+	key := msg.ReceiveIDType + ":" + msg.ReceiveID
+
+	o.mu.Lock()
+	q, ok := o.queues[key]
+	if !ok {
+		q = &receiverQueue{}
+		o.queues[key] = q
+	}
+	q.pending = append(q.pending, msg)
+	start := !q.running
+	q.running = true
+	o.mu.Unlock()
+
+	if start {
+		go o.drain(key, q)
+	}
+	return msg.done
+}
+
+// Send 加入队列并等待发送结果
+func (o *Outbox) Send(msg *OutboundMessage) error {
+	return <-o.Enqueue(msg)
+}
+
+// drain 串行发送队列中的消息，队列清空后退出
+func (o *Outbox) drain(key string, q *receiverQueue) {
+	for {
+		o.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			delete(o.queues, key)
+			o.mu.Unlock()
+			return
+		}
+		msg := q.pending[0]
+		q.pending = q.pending[1:]
+		o.mu.Unlock()
+
+		err := o.deliver(msg)
+		if err == nil && msg.RunID != "" {
+			o.mu.Lock()
+			sent := o.sent
+			o.mu.Unlock()
+			if sent != nil {
+				sent.Sent(msg)
+			}
+		}
+		msg.done <- err
+		close(msg.done)
+
+		if err != nil && !msg.notice {
+			o.mu.Lock()
+			sink := o.deadLetters
+			o.mu.Unlock()
+			if sink != nil {
+				sink.Add(msg, err)
+			}
+			// 死信重投失败不再重复提示用户
+			if msg.DeadLetterID == "" {
+				o.notifyFailure(msg, err, sink != nil)
+			}
+		}
+	}
+}
+
+// deliver 发送单条消息，按策略重试
+func (o *Outbox) deliver(msg *OutboundMessage) error {
+	var err error
+	for attempt := 1; attempt <= o.policy.MaxAttempts; attempt++ {
+		var messageID string
+		messageID, err = o.client.sendOnce(msg.ReceiveID, msg.ReceiveIDType, msg.MsgType, msg.Content)
+		if err == nil {
+			msg.MessageID = messageID
+			return nil
+		}
+
+		retry, wait := o.retryDecision(err, attempt)
+		if !retry || attempt == o.policy.MaxAttempts {
+			break
+		}
+		log.Printf("[Outbox] Send failed, retrying: receive_id=%s attempt=%d/%d wait=%s err=%v",
+			msg.ReceiveID, attempt, o.policy.MaxAttempts, wait, err)
+		time.Sleep(wait)
+	}
+
+	log.Printf("[Outbox] Send failed permanently: receive_id=%s receive_id_type=%s msg_type=%s len=%d err=%v",
+		msg.ReceiveID, msg.ReceiveIDType, msg.MsgType, len(msg.Content), err)
+	return err
+}
+
+// retryDecision 判断错误是否可重试以及等待时间
+func (o *Outbox) retryDecision(err error, attempt int) (bool, time.Duration) {
+	var feishuErr *FeishuError
+	if !errors.As(err, &feishuErr) {
+		// 网络错误等非飞书业务错误，按临时错误处理
+		return true, o.policy.backoff(attempt)
+	}
+
+	if rateLimitCodes[feishuErr.Code] || feishuErr.HTTPStatus == 429 {
+		wait := feishuErr.RetryAfter
+		if wait <= 0 {
+			wait = o.policy.backoff(attempt)
+		}
+		return true, wait
+	}
+
+	if transientCodes[feishuErr.Code] || feishuErr.HTTPStatus >= 500 {
+		return true, o.policy.backoff(attempt)
+	}
+
+	return false, 0
+}
+
+// notifyFailure 向接收者发送失败提示（不阻塞队列中后续消息的顺序）
+func (o *Outbox) notifyFailure(msg *OutboundMessage, err error, willRetry bool) {
+	code := 0
+	var feishuErr *FeishuError
+	if errors.As(err, &feishuErr) {
+		code = feishuErr.Code
+	}
+	text := fmt.Sprintf("⚠️ 有一条消息发送失败（code=%d），回复内容可能不完整", code)
+	if willRetry {
+		text += "，稍后将自动重试"
+	}
+
+	notice, marshalErr := textContent(text)
+	if marshalErr != nil {
+		return
+	}
+	o.Enqueue(&OutboundMessage{
+		ReceiveID:     msg.ReceiveID,
+		ReceiveIDType: msg.ReceiveIDType,
+		MsgType:       "text",
+		Content:       notice,
+		notice:        true,
+	})
+}
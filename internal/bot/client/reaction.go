@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// AddReaction 给消息添加表情回复，返回 reaction_id（用于后续删除）
+func (fc *FeishuClient) AddReaction(messageID, emojiType string) (string, error) {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := fc.client.Im.MessageReaction.Create(context.Background(), larkim.NewCreateMessageReactionReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewCreateMessageReactionReqBodyBuilder().
+			ReactionType(larkim.NewEmojiBuilder().EmojiType(emojiType).Build()).
+			Build()).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to create reaction: %w", err)
+	}
+
+	if !resp.Success() {
+		return "", &FeishuError{
+			Code:      resp.Code,
+			Message:   resp.Msg,
+			RequestID: resp.RequestId(),
+		}
+	}
+
+	reactionID := ""
+	if resp.Data != nil && resp.Data.ReactionId != nil {
+		reactionID = *resp.Data.ReactionId
+	}
+	log.Printf("[FeishuClient] Reaction added: message_id=%s emoji=%s reaction_id=%s", messageID, emojiType, reactionID)
+
+	return reactionID, nil
+}
+
+// DeleteReaction 删除消息上的表情回复
+func (fc *FeishuClient) DeleteReaction(messageID, reactionID string) error {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return err
+	}
+
+	resp, err := fc.client.Im.MessageReaction.Delete(context.Background(), larkim.NewDeleteMessageReactionReqBuilder().
+		MessageId(messageID).
+		ReactionId(reactionID).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to delete reaction: %w", err)
+	}
+
+	if !resp.Success() {
+		return &FeishuError{
+			Code:      resp.Code,
+			Message:   resp.Msg,
+			RequestID: resp.RequestId(),
+		}
+	}
+
+	log.Printf("[FeishuClient] Reaction deleted: message_id=%s reaction_id=%s", messageID, reactionID)
+	return nil
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkdocx "github.com/larksuite/oapi-sdk-go/v3/service/docx/v1"
+	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+const (
+	docxTextBlockType = 2    // 文本块
+	docxMaxChildren   = 50   // 单次创建子块的数量上限
+	docxMaxBlockRunes = 2000 // 单个文本块的最大字符数
+)
+
+// UploadFile 上传文件到消息文件存储，返回 file_key
+func (fc *FeishuClient) UploadFile(fileName string, data []byte) (string, error) {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := fc.client.Im.File.Create(context.Background(), larkim.NewCreateFileReqBuilder().
+		Body(larkim.NewCreateFileReqBodyBuilder().
+			FileType("stream").
+			FileName(fileName).
+			File(bytes.NewReader(data)).
+			Build()).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if !resp.Success() {
+		return "", newFeishuError(resp.CodeError, resp.ApiResp)
+	}
+	if resp.Data == nil || resp.Data.FileKey == nil {
+		return "", fmt.Errorf("upload file: empty file_key")
+	}
+
+	log.Printf("[FeishuClient] File uploaded: name=%s size=%d file_key=%s", fileName, len(data), *resp.Data.FileKey)
+	return *resp.Data.FileKey, nil
+}
+
+// messageResourceURL 消息中资源文件的下载接口（测试中替换为本地服务）
+var messageResourceURL = "https://open.feishu.cn/open-apis/im/v1/messages/%s/resources/%s?type=file"
+
+// fileDownloadTimeout 下载单个消息文件的超时
+const fileDownloadTimeout = 2 * time.Minute
+
+// DownloadMessageFile 下载消息中的文件，超过 maxBytes 时返回错误
+// 直接读取 HTTP 响应流（SDK 会先把整个文件读入内存）：Content-Length 超限时不下载，否则最多读取 maxBytes+1 字节
+func (fc *FeishuClient) DownloadMessageFile(messageID, fileKey string, maxBytes int64) ([]byte, error) {
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fileDownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf(messageResourceURL, url.PathEscape(messageID), url.PathEscape(fileKey)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, downloadError(resp)
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("文件超过大小限制 %d 字节", maxBytes)
+	}
+
+	data, err := readLimited(resp.Body, maxBytes+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("文件超过大小限制 %d 字节", maxBytes)
+	}
+
+	log.Printf("[FeishuClient] File downloaded: message_id=%s file_key=%s size=%d", messageID, fileKey, len(data))
+	return data, nil
+}
+
+// downloadError 将下载接口的错误响应（{"code":..,"msg":..}）转为 FeishuError
+func downloadError(resp *http.Response) error {
+	feishuErr := &FeishuError{
+		Message:    fmt.Sprintf("download file: http status %d", resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Tt-Logid"),
+		HTTPStatus: resp.StatusCode,
+	}
+	body, _ := readLimited(resp.Body, 64<<10)
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal(body, &result) == nil && result.Code != 0 {
+		feishuErr.Code = result.Code
+		feishuErr.Message = result.Msg
+	}
+	return feishuErr
+}
+
+// SendFile 发送文件消息（经出站队列）
+func (fc *FeishuClient) SendFile(receiveID, receiveIDType, fileKey, runID string) error {
+	content, err := json.Marshal(map[string]string{"file_key": fileKey})
+	if err != nil {
+		return fmt.Errorf("failed to marshal file content: %w", err)
+	}
+
+	return fc.outbox.Send(&OutboundMessage{
+		ReceiveID:     receiveID,
+		ReceiveIDType: receiveIDType,
+		MsgType:       "file",
+		Content:       string(content),
+		RunID:         runID,
+	})
+}
+
+// CreateDocument 创建飞书云文档并写入纯文本内容（每行一个文本块），返回 document_id
+// 文档只授予接收者（群聊或用户）阅读权限，不开放链接分享；授权失败时返回错误
+func (fc *FeishuClient) CreateDocument(title, content, receiveID, receiveIDType string) (string, error) {
+	memberType, ok := docMemberTypes[receiveIDType]
+	if !ok {
+		return "", fmt.Errorf("create document: unsupported receive_id_type %q", receiveIDType)
+	}
+
+	token, err := fc.GetTenantAccessToken()
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+
+	createResp, err := fc.client.Docx.Document.Create(ctx, larkdocx.NewCreateDocumentReqBuilder().
+		Body(larkdocx.NewCreateDocumentReqBodyBuilder().
+			Title(title).
+			Build()).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to create document: %w", err)
+	}
+	if !createResp.Success() {
+		return "", newFeishuError(createResp.CodeError, createResp.ApiResp)
+	}
+	if createResp.Data == nil || createResp.Data.Document == nil || createResp.Data.Document.DocumentId == nil {
+		return "", fmt.Errorf("create document: empty document_id")
+	}
+	documentID := *createResp.Data.Document.DocumentId
+
+	// 根块 ID 与文档 ID 相同，按批追加文本块
+	blocks := textBlocks(content)
+	for start := 0; start < len(blocks); start += docxMaxChildren {
+		end := start + docxMaxChildren
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		childResp, err := fc.client.Docx.DocumentBlockChildren.Create(ctx, larkdocx.NewCreateDocumentBlockChildrenReqBuilder().
+			DocumentId(documentID).
+			BlockId(documentID).
+			Body(larkdocx.NewCreateDocumentBlockChildrenReqBodyBuilder().
+				Children(blocks[start:end]).
+				Build()).
+			Build(), larkcore.WithTenantAccessToken(token))
+		if err != nil {
+			return "", fmt.Errorf("failed to write document: %w", err)
+		}
+		if !childResp.Success() {
+			return "", newFeishuError(childResp.CodeError, childResp.ApiResp)
+		}
+	}
+
+	permResp, err := fc.client.Drive.PermissionMember.Create(ctx, larkdrive.NewCreatePermissionMemberReqBuilder().
+		Token(documentID).
+		Type("docx").
+		NeedNotification(false).
+		BaseMember(larkdrive.NewBaseMemberBuilder().
+			MemberType(memberType).
+			MemberId(receiveID).
+			Perm("view").
+			Build()).
+		Build(), larkcore.WithTenantAccessToken(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to share document: %w", err)
+	}
+	if !permResp.Success() {
+		return "", newFeishuError(permResp.CodeError, permResp.ApiResp)
+	}
+
+	log.Printf("[FeishuClient] Document created: document_id=%s blocks=%d shared_with=%s:%s", documentID, len(blocks), memberType, receiveID)
+	return documentID, nil
+}
+
+// docMemberTypes 消息接收者类型 -> 云文档协作者类型
+var docMemberTypes = map[string]string{
+	"chat_id":  "openchat",
+	"open_id":  "openid",
+	"user_id":  "userid",
+	"union_id": "unionid",
+	"email":    "email",
+}
+
+// textBlocks 将文本按行拆分为文本块（跳过空行，过长的行再按字符数拆分）
+func textBlocks(content string) []*larkdocx.Block {
+	var blocks []*larkdocx.Block
+	for _, line := range strings.Split(content, "\n") {
+		runes := []rune(line)
+		for len(runes) > 0 {
+			part := runes
+			if len(part) > docxMaxBlockRunes {
+				part = runes[:docxMaxBlockRunes]
+			}
+			blocks = append(blocks, larkdocx.NewBlockBuilder().
+				BlockType(docxTextBlockType).
+				Text(larkdocx.NewTextBuilder().
+					Elements([]*larkdocx.TextElement{
+						larkdocx.NewTextElementBuilder().
+							TextRun(larkdocx.NewTextRunBuilder().Content(string(part)).Build()).
+							Build(),
+					}).
+					Build()).
+				Build())
+			runes = runes[len(part):]
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadMessageFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer t-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.URL.Query().Get("type") != "file" {
+			t.Errorf("type = %q, want file", r.URL.Query().Get("type"))
+		}
+		// 路径为 /open-apis/im/v1/messages/{kind}/resources/{size}
+		parts := strings.Split(r.URL.Path, "/")
+		size, _ := strconv.Atoi(parts[len(parts)-1])
+		switch {
+		case strings.Contains(r.URL.Path, "/missing/"):
+			w.Header().Set("X-Tt-Logid", "log_1")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":234003,"msg":"File not in msg."}`))
+			return
+		case strings.Contains(r.URL.Path, "/chunked/"):
+			// 不带 Content-Length，分块写出
+			for i := 0; i < size; i += 1024 {
+				if _, err := w.Write([]byte(strings.Repeat("x", 1024))); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.Write([]byte(strings.Repeat("x", size)))
+	}))
+	defer server.Close()
+
+	oldURL := messageResourceURL
+	messageResourceURL = server.URL + "/open-apis/im/v1/messages/%s/resources/%s?type=file"
+	defer func() { messageResourceURL = oldURL }()
+
+	fc := &FeishuClient{tenantAccessToken: "t-test", tokenExpireTime: time.Now().Add(time.Hour)}
+	download := func(kind string, size, max int64) ([]byte, error) {
+		return fc.DownloadMessageFile(kind, strconv.FormatInt(size, 10), max)
+	}
+
+	data, err := download("ok", 100, 100)
+	if err != nil || len(data) != 100 {
+		t.Fatalf("download within limit = %d bytes, %v", len(data), err)
+	}
+
+	if _, err := download("large", 4<<20, 1024); err == nil || !strings.Contains(err.Error(), "超过大小限制") {
+		t.Fatalf("download with large Content-Length error = %v, want size limit error", err)
+	}
+
+	if _, err := download("chunked", 4<<20, 1024); err == nil || !strings.Contains(err.Error(), "超过大小限制") {
+		t.Fatalf("chunked download error = %v, want size limit error", err)
+	}
+
+	_, err = download("missing", 10, 1024)
+	var feishuErr *FeishuError
+	if !errors.As(err, &feishuErr) || feishuErr.Code != 234003 || feishuErr.RequestID != "log_1" || feishuErr.HTTPStatus != http.StatusBadRequest {
+		t.Fatalf("download error = %#v, want FeishuError 234003", err)
+	}
+}
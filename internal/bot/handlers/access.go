@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"feishu-bot/internal/config"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// defaultAuditLogFile 拒绝记录的默认审计日志文件
+const defaultAuditLogFile = "data/access_audit.log"
+
+// auditMu 串行写入审计日志
+var auditMu sync.Mutex
+
+// auditRecord 一条拒绝访问的审计记录
+type auditRecord struct {
+	Time      string `json:"time"`
+	OpenID    string `json:"open_id,omitempty"`
+	UnionID   string `json:"union_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	ChatID    string `json:"chat_id,omitempty"`
+	ChatType  string `json:"chat_type,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	Command   string `json:"command"`
+	Reason    string `json:"reason"`
+}
+
+// subjectFromEvent 从消息事件中取出发送者标识
+func subjectFromEvent(event *larkim.P2MessageReceiveV1) config.Subject {
+	var s config.Subject
+	if event.Event.Sender == nil || event.Event.Sender.SenderId == nil {
+		return s
+	}
+	id := event.Event.Sender.SenderId
+	if id.OpenId != nil {
+		s.OpenID = *id.OpenId
+	}
+	if id.UnionId != nil {
+		s.UnionID = *id.UnionId
+	}
+	if id.UserId != nil {
+		s.UserID = *id.UserId
+	}
+	return s
+}
+
+// authorize 检查发送者和群聊是否允许使用机器人
+// 返回访问控制配置和发送者，供后续检查命令权限；配置加载失败时拒绝访问
+func (mh *MessageHandler) authorize(event *larkim.P2MessageReceiveV1) (*config.AccessConfig, config.Subject, error) {
+	chatID := ""
+	message := event.Event.Message
+	if message.ChatType != nil && *message.ChatType != "p2p" && message.ChatId != nil {
+		chatID = *message.ChatId
+	}
+	return mh.authorizeSubject(subjectFromEvent(event), chatID)
+}
+
+// authorizeSubject 检查用户和群聊（chatID 为空表示单聊，不检查群聊）是否允许使用机器人
+func (mh *MessageHandler) authorizeSubject(subject config.Subject, chatID string) (*config.AccessConfig, config.Subject, error) {
+	access, err := config.LoadAccess()
+	if err != nil {
+		mh.logger.Printf("Failed to load access config: %v", err)
+		return nil, subject, fmt.Errorf("访问控制配置加载失败")
+	}
+
+	if chatID != "" {
+		if err := access.CheckChat(chatID); err != nil {
+			return access, subject, err
+		}
+	}
+
+	err = access.CheckUser(subject)
+	if err != nil && access.UsesDepartments() && subject.OpenID != "" {
+		// 仅在用户不在白名单中时才查询部门，减少通讯录接口调用
+		if info, infoErr := mh.feishuClient.GetUserInfo(subject.OpenID); infoErr != nil {
+			mh.logger.Printf("Failed to get departments for %s: %v", subject.OpenID, infoErr)
+		} else {
+			subject.DepartmentIDs = info.DepartmentIDs
+			err = access.CheckUser(subject)
+		}
+	}
+	return access, subject, err
+}
+
+// auditDenied 记录一次被拒绝的访问
+func (mh *MessageHandler) auditDenied(event *larkim.P2MessageReceiveV1, subject config.Subject, command string, reason error) {
+	record := auditRecord{
+		Time:    time.Now().Format(time.RFC3339),
+		OpenID:  subject.OpenID,
+		UnionID: subject.UnionID,
+		UserID:  subject.UserID,
+		Command: command,
+		Reason:  reason.Error(),
+	}
+	if message := event.Event.Message; message != nil {
+		if message.ChatId != nil {
+			record.ChatID = *message.ChatId
+		}
+		if message.ChatType != nil {
+			record.ChatType = *message.ChatType
+		}
+		if message.MessageId != nil {
+			record.MessageID = *message.MessageId
+		}
+	}
+	mh.writeAudit(record)
+}
+
+// writeAudit 追加一条审计记录到审计日志
+func (mh *MessageHandler) writeAudit(record auditRecord) {
+	mh.logger.Printf("[Access] Denied: open_id=%s chat_id=%s command=%s reason=%s", record.OpenID, record.ChatID, record.Command, record.Reason)
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	path := os.Getenv("AUDIT_LOG_FILE")
+	if path == "" {
+		path = defaultAuditLogFile
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		mh.logger.Printf("Failed to create audit log dir: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		mh.logger.Printf("Failed to open audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		mh.logger.Printf("Failed to write audit log: %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+const (
+	defaultFileMaxBytes   = 20 << 20         // 单个文件默认大小上限 20MB
+	defaultFileRetention  = 24 * time.Hour   // 下载文件默认保留时长
+	defaultFileInboxDir   = ".feishu-inbox"  // 项目下的默认收件目录
+	inboxIgnoreFile       = ".gitignore"     // 收件目录内忽略自身的 .gitignore，避免项目工作区显示为有改动
+	pendingAttachmentTTL  = 30 * time.Minute // 文件等待下一条消息的时长，超时后丢弃
+	defaultFileAllowedExt = ".txt,.log,.csv,.tsv,.json,.jsonl,.yaml,.yml,.xml,.md,.patch,.diff,.pdf,.sql,.html,.go,.py,.js,.ts,.java,.sh"
+)
+
+// errFileTypeNotAllowed 文件扩展名不在允许列表中
+var errFileTypeNotAllowed = errors.New("file type not allowed")
+
+// pendingAttachment 已收到、等待随下一条消息交给 Claude 的文件
+type pendingAttachment struct {
+	messageID  string
+	fileKey    string
+	fileName   string
+	receivedAt time.Time
+}
+
+// attachmentStore 按会话和发送者暂存文件消息，并记录用过的收件目录（用于定期清理）
+type attachmentStore struct {
+	mu      sync.Mutex
+	pending map[string][]pendingAttachment
+	dirs    map[string]bool
+}
+
+// fileMessageContent 文件消息的 content
+type fileMessageContent struct {
+	FileKey  string `json:"file_key"`
+	FileName string `json:"file_name"`
+}
+
+// isFileMessage 是否为文件消息
+func isFileMessage(message *larkim.EventMessage) bool {
+	return message != nil && message.MessageType != nil && *message.MessageType == "file"
+}
+
+// holdAttachment 校验文件消息并暂存，返回文件名；扩展名不允许时返回 errFileTypeNotAllowed
+func (mh *MessageHandler) holdAttachment(message *larkim.EventMessage, chatID, openID string) (string, error) {
+	if message.Content == nil || message.MessageId == nil {
+		return "", fmt.Errorf("invalid file message")
+	}
+	var file fileMessageContent
+	if err := json.Unmarshal([]byte(*message.Content), &file); err != nil {
+		return "", fmt.Errorf("failed to parse file message: %w", err)
+	}
+	if file.FileKey == "" {
+		return "", fmt.Errorf("file message without file_key")
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.FileName))
+	if !fileExtAllowed(ext) {
+		return file.FileName, errFileTypeNotAllowed
+	}
+
+	key := forwardKey(chatID, openID)
+	mh.attachments.mu.Lock()
+	if mh.attachments.pending == nil {
+		mh.attachments.pending = make(map[string][]pendingAttachment)
+	}
+	mh.attachments.pending[key] = append(mh.attachments.pending[key], pendingAttachment{
+		messageID:  *message.MessageId,
+		fileKey:    file.FileKey,
+		fileName:   file.FileName,
+		receivedAt: time.Now(),
+	})
+	mh.attachments.mu.Unlock()
+
+	mh.logger.Printf("[DEBUG] Attachment held: chat_id=%s open_id=%s file=%s", chatID, openID, file.FileName)
+	return file.FileName, nil
+}
+
+// handleP2PFile 单聊收到文件：暂存并提示用户发送问题
+func (mh *MessageHandler) handleP2PFile(event *larkim.P2MessageReceiveV1) error {
+	openID := *event.Event.Sender.SenderId.OpenId
+	chatID := ""
+	if event.Event.Message.ChatId != nil {
+		chatID = *event.Event.Message.ChatId
+	}
+
+	fileName, err := mh.holdAttachment(event.Event.Message, chatID, openID)
+	if errors.Is(err, errFileTypeNotAllowed) {
+		return mh.sendTextMessage(openID, "open_id", fileTypeNotice(fileName))
+	}
+	if err != nil {
+		mh.logger.Printf("Failed to hold attachment: %v", err)
+		return mh.sendTextMessage(openID, "open_id", "❌ 无法读取文件消息: "+err.Error())
+	}
+	return mh.sendTextMessage(openID, "open_id",
+		fmt.Sprintf("📎 已收到文件 %s，将随你的下一条消息一起交给 Claude", fileName))
+}
+
+// fileTypeNotice 文件类型不允许时的提示
+func fileTypeNotice(fileName string) string {
+	return fmt.Sprintf("⚠️ 不支持的文件类型: %s\n允许的类型: %s", fileName, strings.Join(allowedFileExts(), " "))
+}
+
+// withAttachments 下载暂存的文件到收件目录，并把文件路径附在问题前
+func (mh *MessageHandler) withAttachments(chatID, openID, inboxDir, question string) string {
+	key := forwardKey(chatID, openID)
+	mh.attachments.mu.Lock()
+	pending := mh.attachments.pending[key]
+	delete(mh.attachments.pending, key)
+	mh.attachments.mu.Unlock()
+	if len(pending) == 0 {
+		return question
+	}
+
+	if err := ensureInboxDir(inboxDir); err != nil {
+		mh.logger.Printf("Failed to create inbox dir %s: %v", inboxDir, err)
+		return question
+	}
+	mh.attachments.mu.Lock()
+	if mh.attachments.dirs == nil {
+		mh.attachments.dirs = make(map[string]bool)
+	}
+	mh.attachments.dirs[inboxDir] = true
+	mh.attachments.mu.Unlock()
+	mh.cleanupInbox(inboxDir)
+
+	var lines []string
+	for _, att := range pending {
+		if time.Since(att.receivedAt) > pendingAttachmentTTL {
+			continue
+		}
+		data, err := mh.feishuClient.DownloadMessageFile(att.messageID, att.fileKey, fileMaxBytes())
+		if err != nil {
+			mh.logger.Printf("Failed to download attachment %s: %v", att.fileName, err)
+			lines = append(lines, fmt.Sprintf("- %s（下载失败: %v）", att.fileName, err))
+			continue
+		}
+
+		path := filepath.Join(inboxDir, time.Now().Format("20060102-150405")+"-"+sanitizeFileName(att.fileName))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			mh.logger.Printf("Failed to save attachment %s: %v", path, err)
+			lines = append(lines, fmt.Sprintf("- %s（保存失败）", att.fileName))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s（%d 字节）", path, len(data)))
+	}
+	if len(lines) == 0 {
+		return question
+	}
+
+	var builder strings.Builder
+	builder.WriteString("<attachments>\n用户上传的文件已保存到以下路径，可以直接读取：\n")
+	builder.WriteString(strings.Join(lines, "\n"))
+	builder.WriteString("\n</attachments>\n\n")
+	builder.WriteString(question)
+	return builder.String()
+}
+
+// groupInboxDir 群聊的文件保存目录（按群绑定的项目）
+func (mh *MessageHandler) groupInboxDir(chatID string) string {
+	binding, _ := mh.getBinding(chatID)
+	return inboxDir(binding.ProjectPath, chatID)
+}
+
+// userProjectDir 单聊用户绑定的项目路径（未绑定或读取失败时为空）
+func (mh *MessageHandler) userProjectDir(openID string) string {
+	binding, _ := mh.getBinding(openID)
+	return binding.ProjectPath
+}
+
+// inboxDir 文件保存目录：绑定了项目时为项目下的 FILE_INBOX_DIR，否则为临时目录
+func inboxDir(projectDir, chatKey string) string {
+	if projectDir != "" {
+		dir := strings.TrimSpace(os.Getenv("FILE_INBOX_DIR"))
+		if dir == "" {
+			dir = defaultFileInboxDir
+		}
+		if filepath.IsAbs(dir) {
+			return dir
+		}
+		return filepath.Join(projectDir, dir)
+	}
+	return filepath.Join(os.TempDir(), "feishu-inbox", sanitizeFileName(chatKey))
+}
+
+// ensureInboxDir 创建收件目录，并在目录内写入忽略全部内容（包括自身）的 .gitignore
+func ensureInboxDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, inboxIgnoreFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, []byte("# 飞书机器人收件目录，文件过期后自动删除\n*\n"), 0644)
+}
+
+// RunInboxJanitor 定期清理收件目录中超过保留时长的文件，直到 ctx 结束
+func (mh *MessageHandler) RunInboxJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mh.prunePendingAttachments(time.Now())
+			for _, dir := range mh.inboxDirs() {
+				mh.cleanupInbox(dir)
+			}
+		}
+	}
+}
+
+// inboxDirs 需要清理的收件目录：本次运行用过的、存储中所有绑定项目下的，以及临时目录下的
+// 每次重新从存储和临时目录查找，重启前写入的文件也能按时清理
+func (mh *MessageHandler) inboxDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	mh.attachments.mu.Lock()
+	for dir := range mh.attachments.dirs {
+		add(dir)
+	}
+	mh.attachments.mu.Unlock()
+
+	if bindings, err := mh.store.ListBindings(); err != nil {
+		mh.logger.Printf("Failed to list bindings for inbox cleanup: %v", err)
+	} else {
+		for _, b := range bindings {
+			if b.ProjectPath != "" {
+				add(inboxDir(b.ProjectPath, b.Key))
+			}
+		}
+	}
+
+	tempRoot := filepath.Join(os.TempDir(), "feishu-inbox")
+	if entries, err := os.ReadDir(tempRoot); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				add(filepath.Join(tempRoot, e.Name()))
+			}
+		}
+	}
+	return dirs
+}
+
+// prunePendingAttachments 丢弃等待超过 pendingAttachmentTTL 的暂存文件（发送者一直没有发下一条消息）
+func (mh *MessageHandler) prunePendingAttachments(now time.Time) {
+	mh.attachments.mu.Lock()
+	defer mh.attachments.mu.Unlock()
+
+	for key, pending := range mh.attachments.pending {
+		kept := pending[:0]
+		for _, att := range pending {
+			if now.Sub(att.receivedAt) <= pendingAttachmentTTL {
+				kept = append(kept, att)
+			}
+		}
+		if len(kept) == 0 {
+			delete(mh.attachments.pending, key)
+		} else {
+			mh.attachments.pending[key] = kept
+		}
+	}
+}
+
+// cleanupInbox 删除目录中超过保留时长的文件
+func (mh *MessageHandler) cleanupInbox(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-fileRetention())
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == inboxIgnoreFile {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if err := os.Remove(path); err != nil {
+			mh.logger.Printf("Failed to remove expired attachment %s: %v", path, err)
+		} else {
+			mh.logger.Printf("[DEBUG] Expired attachment removed: %s", path)
+		}
+	}
+}
+
+// sanitizeFileName 去掉文件名中的路径分隔符等字符，防止写出收件目录
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// allowedFileExts 允许下载的扩展名（FILE_ALLOWED_EXTS，逗号分隔）
+func allowedFileExts() []string {
+	value := os.Getenv("FILE_ALLOWED_EXTS")
+	if strings.TrimSpace(value) == "" {
+		value = defaultFileAllowedExt
+	}
+	var exts []string
+	for _, ext := range strings.Split(value, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// fileExtAllowed 扩展名是否在允许列表中
+func fileExtAllowed(ext string) bool {
+	for _, allowed := range allowedFileExts() {
+		if allowed == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// fileMaxBytes 单个文件大小上限（FILE_MAX_BYTES）
+func fileMaxBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("FILE_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultFileMaxBytes
+}
+
+// fileRetention 下载文件的保留时长（FILE_RETENTION）
+func fileRetention() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("FILE_RETENTION")); err == nil && value > 0 {
+		return value
+	}
+	return defaultFileRetention
+}
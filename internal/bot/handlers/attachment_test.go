@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"feishu-bot/internal/store"
+)
+
+func TestInboxJanitorFindsBoundProjects(t *testing.T) {
+	t.Setenv("FILE_INBOX_DIR", "")
+	t.Setenv("FILE_RETENTION", "1h")
+
+	st, err := store.Open(store.Options{Path: filepath.Join(t.TempDir(), "state.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	project := t.TempDir()
+	if err := st.UpdateBinding("oc_1", func(b *store.Binding) error {
+		b.Bind(false, project, nil, "ou_admin", time.Now())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启前写入的收件目录：本次运行中没有用过
+	dir := inboxDir(project, "oc_1")
+	if err := ensureInboxDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(dir, "old.log")
+	fresh := filepath.Join(dir, "fresh.log")
+	for _, path := range []string{old, fresh} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{old, filepath.Join(dir, inboxIgnoreFile)} {
+		if err := os.Chtimes(path, expired, expired); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mh := &MessageHandler{store: st, logger: log.New(io.Discard, "", 0)}
+	found := false
+	for _, d := range mh.inboxDirs() {
+		if d == dir {
+			found = true
+		}
+		mh.cleanupInbox(d)
+	}
+	if !found {
+		t.Fatalf("inboxDirs() = %v, want %s", mh.inboxDirs(), dir)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expired attachment not removed: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("fresh attachment removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, inboxIgnoreFile)); err != nil {
+		t.Fatalf(".gitignore removed: %v", err)
+	}
+}
+
+func TestEnsureInboxDirIgnoresItself(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), defaultFileInboxDir)
+	if err := ensureInboxDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, inboxIgnoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# 飞书机器人收件目录，文件过期后自动删除\n*\n" {
+		t.Fatalf(".gitignore = %q", data)
+	}
+
+	// 已存在时不覆盖
+	if err := os.WriteFile(filepath.Join(dir, inboxIgnoreFile), []byte("custom\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureInboxDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, inboxIgnoreFile)); string(data) != "custom\n" {
+		t.Fatalf(".gitignore overwritten: %q", data)
+	}
+}
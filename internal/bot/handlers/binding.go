@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"feishu-bot/internal/config"
+	"feishu-bot/internal/store"
+)
+
+// whereHistoryLimit where 命令显示的变更记录条数
+const whereHistoryLimit = 5
+
+// errNotBound 解绑时当前会话未绑定项目（不保存任何修改）
+var errNotBound = errors.New("not bound")
+
+// getBinding 会话（群聊 chat_id 或单聊用户 open_id）的项目绑定，未绑定时 ProjectPath 为空
+func (mh *MessageHandler) getBinding(key string) (store.Binding, error) {
+	binding, _, err := mh.store.GetBinding(key)
+	return binding, err
+}
+
+// bindProject 绑定会话的项目路径和附加目录，并记录修改者
+func (mh *MessageHandler) bindProject(key string, user bool, projectPath string, addDirs []string, by string) error {
+	return mh.store.UpdateBinding(key, func(b *store.Binding) error {
+		b.Bind(user, projectPath, addDirs, by, time.Now())
+		return nil
+	})
+}
+
+// handleBindCommand 处理 bind 命令 - 按序号、名称（支持模糊匹配）、别名或相对路径绑定项目
+// 第一个参数为主项目（Claude 的工作目录），其余为附加目录（--add-dir）
+func (mh *MessageHandler) handleBindCommand(cc *commandContext) error {
+	targets := strings.Fields(cc.args)
+	if len(targets) == 0 {
+		return cc.reply(mh,
+			"❌ 请提供项目序号、名称、别名或相对路径\n使用命令: /bind <项目> [附加项目...]")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 加载配置失败: %v", err))
+	}
+	catalog, err := loadProjectCatalog(cfg)
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
+	}
+
+	var projectPath string
+	var addDirs []string
+	seen := make(map[string]bool)
+	for i, target := range targets {
+		path, err := catalog.resolve(target)
+		if err != nil {
+			return cc.reply(mh, "❌ "+err.Error())
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if i == 0 {
+			projectPath = path
+		} else {
+			addDirs = append(addDirs, path)
+		}
+	}
+
+	if err := mh.bindProject(cc.bindingKey(), !cc.group, projectPath, addDirs, cc.openID); err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 保存绑定失败: %v", err))
+	}
+
+	text := fmt.Sprintf("✅ 已绑定项目路径: %s", projectPath)
+	if len(addDirs) > 0 {
+		text += "\n附加目录:\n• " + strings.Join(addDirs, "\n• ")
+	}
+	// 单聊会话与项目目录相关，换绑后开始新的会话
+	if !cc.group {
+		mh.clearClaudeSession(cc.openID)
+		text += "\n下一条消息将在该项目中开始新的会话"
+	}
+	return cc.reply(mh, text+"\n（绑定已保存）")
+}
+
+// handleUnbindCommand 处理 unbind 命令 - 解除当前会话的项目绑定
+func (mh *MessageHandler) handleUnbindCommand(cc *commandContext) error {
+	var projectPath string
+	err := mh.store.UpdateBinding(cc.bindingKey(), func(b *store.Binding) error {
+		projectPath = b.ProjectPath
+		if !b.Unbind(cc.openID, time.Now()) {
+			return errNotBound
+		}
+		return nil
+	})
+	if errors.Is(err, errNotBound) {
+		return cc.reply(mh, "⚠️ 当前未绑定项目路径")
+	}
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 保存绑定失败: %v", err))
+	}
+
+	if !cc.group {
+		mh.clearClaudeSession(cc.openID)
+	}
+	return cc.reply(mh,
+		fmt.Sprintf("✅ 已解除绑定: %s\n（绑定已保存）", projectPath))
+}
+
+// handleWhereCommand 处理 where 命令 - 显示当前绑定、附加目录和最近的变更记录
+func (mh *MessageHandler) handleWhereCommand(cc *commandContext) error {
+	binding, err := mh.getBinding(cc.bindingKey())
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 读取绑定失败: %v", err))
+	}
+
+	var builder strings.Builder
+	projectPath := binding.ProjectPath
+	if projectPath == "" {
+		builder.WriteString("⚠️ 当前未绑定项目路径\n使用命令: /bind <项目> [附加项目...]")
+	} else {
+		builder.WriteString(fmt.Sprintf("📌 当前绑定: %s%s\n", projectPath, missingMark(projectPath)))
+		for _, dir := range binding.AddDirs {
+			builder.WriteString(fmt.Sprintf("➕ 附加目录: %s%s\n", dir, missingMark(dir)))
+		}
+		if binding.UpdatedBy != "" {
+			builder.WriteString(fmt.Sprintf("🕒 %s 由 %s 修改\n",
+				binding.UpdatedAt.Format("2006-01-02 15:04:05"), mh.userName(binding.UpdatedBy)))
+		}
+	}
+
+	if history := binding.History; len(history) > 0 {
+		if len(history) > whereHistoryLimit {
+			history = history[len(history)-whereHistoryLimit:]
+		}
+		builder.WriteString("\n最近变更：\n")
+		for i := len(history) - 1; i >= 0; i-- {
+			change := history[i]
+			action := "绑定"
+			if change.Action == store.BindingActionUnbind {
+				action = "解绑"
+			}
+			line := fmt.Sprintf("• %s %s %s %s", change.At.Format("01-02 15:04"), mh.userName(change.By), action, change.Path)
+			if len(change.AddDirs) > 0 {
+				line += fmt.Sprintf("（附加 %d 个目录）", len(change.AddDirs))
+			}
+			builder.WriteString(line + "\n")
+		}
+	}
+
+	return cc.reply(mh, strings.TrimRight(builder.String(), "\n"))
+}
+
+// missingMark 目录不存在时的提示
+func missingMark(dir string) string {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "（⚠️ 目录不存在）"
+	}
+	return ""
+}
+
+// existingDirs 过滤掉已不存在的附加目录，避免 Claude CLI 因无效的 --add-dir 启动失败
+func (mh *MessageHandler) existingDirs(dirs []string) []string {
+	var existing []string
+	for _, dir := range dirs {
+		if missingMark(dir) != "" {
+			mh.logger.Printf("Skipping missing add dir: %s", dir)
+			continue
+		}
+		existing = append(existing, dir)
+	}
+	return existing
+}
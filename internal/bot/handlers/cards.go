@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"feishu-bot/internal/bot/card"
+	"feishu-bot/internal/bot/templates"
+	"feishu-bot/internal/claude"
+	"feishu-bot/internal/redact"
+	"feishu-bot/internal/utils"
+)
+
+const (
+	cardSummaryMaxRunes  = 300 // 完成卡片中结果摘要的最大长度
+	cardMaxChangedFiles  = 20  // 完成卡片中最多列出的修改文件数
+	cardDescriptionRunes = 100 // 完成卡片中任务描述的最大长度
+)
+
+// taskCompletedCardEnabled 是否在每次运行结束后发送完成卡片（TASK_COMPLETED_CARD=false 关闭）
+func taskCompletedCardEnabled() bool {
+	return utils.GetEnvBool("TASK_COMPLETED_CARD", true)
+}
+
+// sendTaskCompletedCard 运行结束后发送 task_completed 卡片（失败只记录日志）
+// description 为用户的原始问题（不含发送者标记、聊天记录等附加上下文）；CLI 报告错误时卡片显示失败状态
+func (mh *MessageHandler) sendTaskCompletedCard(receiveID, receiveIDType, projectDir, description, sessionID, runID string, result claude.RunResult) {
+	if !taskCompletedCardEnabled() {
+		return
+	}
+
+	projectName := "（未绑定项目）"
+	if projectDir != "" {
+		projectName = filepath.Base(strings.TrimRight(projectDir, "/"))
+	}
+
+	summary := strings.TrimSpace(redact.String(result.Result))
+	if summary == "" {
+		summary = "（无输出）"
+	}
+
+	card, err := templates.Default().Render(templates.TaskCompleted, map[string]interface{}{
+		"project_name":  projectName,
+		"timestamp":     time.Now().Format("2006-01-02 15:04:05"),
+		"description":   truncateRunes(redact.String(strings.TrimSpace(description)), cardDescriptionRunes),
+		"is_error":      result.IsError,
+		"token":         sessionID,
+		"duration":      formatDuration(time.Duration(result.DurationMs) * time.Millisecond),
+		"cost":          fmt.Sprintf("$%.4f", result.CostUSD),
+		"summary":       truncateRunes(summary, cardSummaryMaxRunes),
+		"changed_files": formatChangedFiles(result.ChangedFiles, projectDir),
+		"redactions":    result.Redactions,
+	})
+	if err != nil {
+		mh.logger.Printf("Failed to render task_completed card: %v", err)
+		return
+	}
+
+	if err := mh.feishuClient.SendCard(receiveID, receiveIDType, card, runID); err != nil {
+		mh.logger.Printf("Failed to send task_completed card: %v", err)
+	}
+}
+
+// ActionCompleteAlarm 告警卡片"处理完成"按钮的 action
+const ActionCompleteAlarm = "complete_alarm"
+
+// AlarmCompletedCard 告警处理完成后替换原卡片的内容（处理人、完成时间、备注）
+func AlarmCompletedCard(openID, notes string, completedAt time.Time) *card.Card {
+	c := card.New().
+		WithHeader("告警已处理", card.HeaderGreen).
+		Add(card.NewFields(
+			fmt.Sprintf("**处理人:** <at id=%s></at>", openID),
+			"**完成时间:** "+completedAt.Format("2006-01-02 15:04:05 (UTC-07:00)"),
+		))
+	if notes = strings.TrimSpace(notes); notes != "" {
+		c.Add(card.NewDiv("**备注:** " + notes))
+	}
+	return c.Add(card.NewNote("✅ 告警已处理"))
+}
+
+// formatChangedFiles 格式化修改文件列表（项目内文件显示相对路径）
+func formatChangedFiles(files []string, projectDir string) string {
+	var builder strings.Builder
+	for i, file := range files {
+		if i >= cardMaxChangedFiles {
+			builder.WriteString(fmt.Sprintf("… 以及另外 %d 个文件", len(files)-cardMaxChangedFiles))
+			break
+		}
+		if projectDir != "" {
+			if rel, err := filepath.Rel(projectDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+		builder.WriteString(fmt.Sprintf("• `%s`\n", file))
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+// formatDuration 将耗时格式化为易读文本
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%.1f 秒", d.Seconds())
+	}
+	return fmt.Sprintf("%d 分 %d 秒", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// truncateRunes 按字符数截断文本
+func truncateRunes(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes]) + "…"
+}
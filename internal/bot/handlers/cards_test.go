@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"feishu-bot/internal/project"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// assertGolden 比较卡片 JSON 与 testdata/<name>.golden.json（go test -update 重新生成）
+func assertGolden(t *testing.T, name, data string) {
+	t.Helper()
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(data), "", "  "); err != nil {
+		t.Fatalf("card is not valid JSON: %v\n%s", err, data)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Errorf("%s mismatch\n--- got\n%s\n--- want\n%s", path, indented.Bytes(), want)
+	}
+}
+
+func TestAlarmCompletedCardGolden(t *testing.T) {
+	completedAt := time.Date(2026, 10, 18, 9, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	tests := []struct {
+		name  string
+		notes string
+	}{
+		{name: "alarm_completed", notes: "已重启服务"},
+		{name: "alarm_completed_no_notes", notes: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := AlarmCompletedCard("ou_operator", tt.notes, completedAt).String()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.name, data)
+		})
+	}
+}
+
+func TestLsCardGolden(t *testing.T) {
+	view := lsView{
+		page:  1,
+		pages: 2,
+		total: 3,
+		entries: []lsEntry{
+			{index: 2, name: "work/api", path: "/srv/work/api", status: project.Status{Git: true, Branch: "main", Dirty: true}, current: true},
+			{index: 1, name: "work/web", path: "/srv/work/web", status: project.Status{Git: true, Branch: "dev"}, boundBy: 2},
+		},
+		aliases: []string{"api → /srv/work/api"},
+		group:   true,
+		chatID:  "oc_test",
+	}
+	data, err := view.card()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "ls", data)
+
+	view.filter, view.entries, view.aliases, view.group = "zzz", nil, nil, false
+	view.errors = []string{"oss（/srv/oss）: permission denied"}
+	data, err = view.card()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "ls_empty", data)
+}
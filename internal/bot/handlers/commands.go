@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"feishu-bot/internal/config"
+	"feishu-bot/internal/utils"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// commandPrefix 命令前缀：带前缀的消息总是按命令解析，避免与以 ls 等开头的提问冲突
+const commandPrefix = "/"
+
+// commandScope 命令可用的会话类型
+type commandScope int
+
+const (
+	scopeP2P   commandScope = 1 << iota // 单聊
+	scopeGroup                          // 群聊
+	scopeAll   = scopeP2P | scopeGroup
+)
+
+// command 机器人命令
+type command struct {
+	name       string   // 命令名（同时是访问控制中的命令名）
+	aliases    []string // 别名（如中文别名）
+	args       string   // 参数说明，如 "<序号>"
+	summary    string   // 一句话说明
+	example    string   // 使用示例（不含前缀）
+	permission string   // 默认所需权限（config.PermissionUser / config.PermissionAdmin），可在访问控制中覆盖
+	// p2pPermission 单聊中的默认所需权限（为空时同 permission）：只影响自己的命令在单聊中可以放宽
+	p2pPermission string
+	scope         commandScope
+	handler       func(mh *MessageHandler, cc *commandContext) error
+}
+
+// requiredPermission 命令在群聊 / 单聊中的默认所需权限
+func (cmd *command) requiredPermission(group bool) string {
+	if !group && cmd.p2pPermission != "" {
+		return cmd.p2pPermission
+	}
+	return cmd.permission
+}
+
+// commandContext 命令执行上下文
+type commandContext struct {
+	event         *larkim.P2MessageReceiveV1
+	chatID        string // 会话 ID（配置按此保存）
+	receiveID     string // 回复目标
+	receiveIDType string
+	openID        string // 命令发起者
+	args          string
+	group         bool
+	access        *config.AccessConfig
+	subject       config.Subject
+}
+
+// reply 回复命令发起的会话
+func (cc *commandContext) reply(mh *MessageHandler, text string) error {
+	return mh.sendTextMessage(cc.receiveID, cc.receiveIDType, text)
+}
+
+// bindingKey 当前会话绑定和设置的键（群聊为 chat_id，单聊为用户 open_id）
+func (cc *commandContext) bindingKey() string {
+	if cc.group {
+		return cc.chatID
+	}
+	return cc.openID
+}
+
+// commands 已注册的命令（按帮助中的显示顺序）
+var commands []*command
+
+// commandIndex 命令名 / 别名 -> 命令
+var commandIndex = make(map[string]*command)
+
+func init() {
+	registerCommand(&command{
+		name:       "ls",
+		aliases:    []string{"列表"},
+		args:       "[关键字] [页码]",
+		summary:    "按最近活动列出可绑定的项目（可翻页、过滤）",
+		example:    "ls api 2",
+		permission: config.PermissionUser,
+		scope:      scopeAll,
+		handler:    (*MessageHandler).handleLsCommand,
+	})
+	registerCommand(&command{
+		name:       "bind",
+		aliases:    []string{"绑定"},
+		args:       "<项目> [附加项目...]",
+		summary:    "绑定当前会话到项目，可附加多个目录（单聊按用户绑定）",
+		example:    "bind 18",
+		permission: config.PermissionAdmin,
+		// 单聊绑定只影响用户自己
+		p2pPermission: config.PermissionUser,
+		scope:         scopeAll,
+		handler:       (*MessageHandler).handleBindCommand,
+	})
+	registerCommand(&command{
+		name:       "unbind",
+		aliases:    []string{"解绑"},
+		summary:    "解除当前会话的项目绑定",
+		example:    "unbind",
+		permission: config.PermissionAdmin,
+		// 单聊解绑只影响用户自己
+		p2pPermission: config.PermissionUser,
+		scope:         scopeAll,
+		handler:       (*MessageHandler).handleUnbindCommand,
+	})
+	registerCommand(&command{
+		name:       "where",
+		aliases:    []string{"当前"},
+		summary:    "查看当前会话绑定的项目、附加目录和最近的变更记录",
+		example:    "where",
+		permission: config.PermissionUser,
+		scope:      scopeAll,
+		handler:    (*MessageHandler).handleWhereCommand,
+	})
+	registerCommand(&command{
+		name:       "alias",
+		aliases:    []string{"别名"},
+		args:       "[<别名> <序号|名称|相对路径|off>]",
+		summary:    "查看/设置/删除项目别名",
+		example:    "alias api work/api-gateway",
+		permission: config.PermissionAdmin,
+		scope:      scopeAll,
+		handler:    (*MessageHandler).handleAliasCommand,
+	})
+	registerCommand(&command{
+		name:       "mode",
+		aliases:    []string{"模式"},
+		args:       "[mention|all|thread]",
+		summary:    "查看/设置群聊响应策略",
+		example:    "mode thread",
+		permission: config.PermissionAdmin,
+		scope:      scopeGroup,
+		handler:    (*MessageHandler).handleModeCommand,
+	})
+	registerCommand(&command{
+		name:       "context",
+		aliases:    []string{"上下文"},
+		args:       "[N|off]",
+		summary:    "提问时附带群里最近 N 条消息作为上下文",
+		example:    "context 20",
+		permission: config.PermissionAdmin,
+		scope:      scopeGroup,
+		handler:    (*MessageHandler).handleContextCommand,
+	})
+	registerCommand(&command{
+		name:       "settings",
+		aliases:    []string{"设置"},
+		args:       "[get [设置项] | set <设置项> <值> | reset [设置项]]",
+		summary:    "查看/修改/重置当前会话的设置（语言、回复格式、模型等；修改需管理员）",
+		example:    "settings set language en",
+		permission: config.PermissionUser,
+		scope:      scopeAll,
+		handler:    (*MessageHandler).handleSettingsCommand,
+	})
+	registerCommand(&command{
+		name:       "help",
+		aliases:    []string{"帮助"},
+		summary:    "显示此帮助信息",
+		example:    "help",
+		permission: config.PermissionUser,
+		scope:      scopeAll,
+		handler:    (*MessageHandler).handleHelpCommand,
+	})
+}
+
+// registerCommand 注册命令；命令名或别名重复时 panic（属于编程错误）
+func registerCommand(cmd *command) {
+	for _, key := range append([]string{cmd.name}, cmd.aliases...) {
+		key = strings.ToLower(key)
+		if _, exists := commandIndex[key]; exists {
+			panic(fmt.Sprintf("duplicate command name: %s", key))
+		}
+		commandIndex[key] = cmd
+	}
+	commands = append(commands, cmd)
+}
+
+// parseCommand 解析消息是否为命令，返回命令和参数
+// 带 / 前缀时总是按命令解析；不带前缀时仅在 allowBare 为 true 时识别
+func parseCommand(content string, allowBare bool) (*command, string, bool) {
+	content = strings.TrimSpace(content)
+	prefixed := strings.HasPrefix(content, commandPrefix)
+	if prefixed {
+		content = strings.TrimPrefix(content, commandPrefix)
+	} else if !allowBare {
+		return nil, "", false
+	}
+
+	parts := strings.Fields(content)
+	if len(parts) == 0 {
+		return nil, "", false
+	}
+	cmd, ok := commandIndex[strings.ToLower(parts[0])]
+	if !ok {
+		return nil, "", false
+	}
+	return cmd, strings.Join(parts[1:], " "), true
+}
+
+// bareCommandsAllowed 群聊中是否识别不带 / 前缀的命令（默认关闭，COMMAND_ALLOW_BARE=true 时开启）
+// 单聊总是需要前缀，避免与普通提问冲突
+func bareCommandsAllowed(group bool) bool {
+	if !group {
+		return false
+	}
+	return utils.GetEnvBool("COMMAND_ALLOW_BARE", false)
+}
+
+// runCommand 检查会话类型与权限后执行命令
+func (mh *MessageHandler) runCommand(cmd *command, cc *commandContext) error {
+	scope := scopeP2P
+	if cc.group {
+		scope = scopeGroup
+	}
+	if cmd.scope&scope == 0 {
+		where := "群聊"
+		if cmd.scope == scopeP2P {
+			where = "单聊"
+		}
+		return cc.reply(mh, fmt.Sprintf("❌ 命令 %s 仅在%s中可用", cmd.name, where))
+	}
+
+	if err := cc.access.CheckCommand(cmd.name, cmd.requiredPermission(cc.group), cc.subject); err != nil {
+		mh.auditDenied(cc.event, cc.subject, cmd.name, err)
+		return cc.reply(mh, "⛔ "+err.Error())
+	}
+
+	mh.logger.Printf("[DEBUG] Running command: name=%s chat_id=%s open_id=%s args=%q", cmd.name, cc.chatID, cc.openID, cc.args)
+	return cmd.handler(mh, cc)
+}
+
+// handleHelpCommand 处理 help 命令 - 按注册表生成当前会话可用的命令说明
+func (mh *MessageHandler) handleHelpCommand(cc *commandContext) error {
+	scope := scopeP2P
+	if cc.group {
+		scope = scopeGroup
+	}
+	invoke := commandPrefix
+	if cc.group {
+		invoke = "@机器人 " + commandPrefix
+	}
+
+	var builder strings.Builder
+	builder.WriteString("🤖 飞书 Claude CLI 机器人命令说明\n\n命令：\n")
+	var examples []string
+	for _, cmd := range commands {
+		if cmd.scope&scope == 0 {
+			continue
+		}
+		builder.WriteString("• " + commandPrefix + cmd.name)
+		if cmd.args != "" {
+			builder.WriteString(" " + cmd.args)
+		}
+		if len(cmd.aliases) > 0 {
+			builder.WriteString("（" + strings.Join(cmd.aliases, " / ") + "）")
+		}
+		builder.WriteString(" - " + cmd.summary)
+		if cc.access.CommandPermission(cmd.name, cmd.requiredPermission(cc.group)) == config.PermissionAdmin {
+			builder.WriteString(" [管理员]")
+		}
+		builder.WriteString("\n")
+		if cmd.example != "" {
+			examples = append(examples, invoke+cmd.example)
+		}
+	}
+
+	builder.WriteString("\n使用示例：\n")
+	builder.WriteString(strings.Join(examples, "\n"))
+
+	builder.WriteString("\n\n注意：\n")
+	if cc.group {
+		if bareCommandsAllowed(true) {
+			builder.WriteString("- 群聊中命令可省略 / 前缀\n")
+		}
+		builder.WriteString("- 响应策略：mention 仅响应 @机器人；all 响应所有消息；thread 还响应对机器人消息的回复\n")
+	} else {
+		builder.WriteString("- 单聊中命令需要以 / 开头，其它消息都会发给 Claude\n")
+	}
+	builder.WriteString("- 绑定后配置会持久化保存")
+
+	// 显示当前绑定
+	currentBinding := ""
+	if binding, err := mh.getBinding(cc.bindingKey()); err == nil {
+		currentBinding = binding.ProjectPath
+	}
+	if currentBinding != "" {
+		builder.WriteString(fmt.Sprintf("\n\n✅ 当前绑定: %s", currentBinding))
+	} else {
+		builder.WriteString("\n\n⚠️ 当前未绑定项目路径")
+	}
+
+	return cc.reply(mh, builder.String())
+}
+
+// p2pCommand 解析单聊中的命令（只识别文本和富文本消息）
+func (mh *MessageHandler) p2pCommand(event *larkim.P2MessageReceiveV1) (*command, string, bool) {
+	message := event.Event.Message
+	if message == nil || message.MessageType == nil || (*message.MessageType != "text" && *message.MessageType != "post") {
+		return nil, "", false
+	}
+	content, err := mh.extractTextContent(message)
+	if err != nil {
+		return nil, "", false
+	}
+	return parseCommand(content, bareCommandsAllowed(false))
+}
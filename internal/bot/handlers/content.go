@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// supportedMessageTypes 可以转换为文本交给 Claude 的消息类型
+var supportedMessageTypes = map[string]bool{
+	"text":          true,
+	"post":          true,
+	"merge_forward": true, // 通过消息接口展开，见 forward.go
+	"file":          true, // 下载到收件目录，见 attachment.go
+}
+
+// mentionPlaceholder 文本消息中 @ 的占位符（如 @_user_1、@_all）
+var mentionPlaceholder = regexp.MustCompile(`@_user_\d+|@_all`)
+
+// mentionInfo 消息中 @ 占位符对应的用户
+type mentionInfo struct {
+	Name   string
+	OpenID string
+}
+
+// eventMentions 将事件中的 mentions 转为 占位符 -> 用户
+func eventMentions(mentions []*larkim.MentionEvent) map[string]mentionInfo {
+	result := make(map[string]mentionInfo, len(mentions))
+	for _, m := range mentions {
+		if m == nil || m.Key == nil {
+			continue
+		}
+		info := mentionInfo{}
+		if m.Name != nil {
+			info.Name = *m.Name
+		}
+		if m.Id != nil && m.Id.OpenId != nil {
+			info.OpenID = *m.Id.OpenId
+		}
+		result[*m.Key] = info
+	}
+	return result
+}
+
+// extractTextContent 将消息内容（text / post）转为纯文本或 markdown
+// 指向机器人的 @ 被移除，其余 @ 占位符替换为"@显示名"
+func (mh *MessageHandler) extractTextContent(message *larkim.EventMessage) (string, error) {
+	if message == nil {
+		return "", fmt.Errorf("message is nil")
+	}
+	if message.Content == nil {
+		return "", fmt.Errorf("no content field found in message")
+	}
+
+	messageType := "text"
+	if message.MessageType != nil && *message.MessageType != "" {
+		messageType = strings.ToLower(*message.MessageType)
+	}
+	mh.logger.Printf("[DEBUG] Raw message content: type=%s len=%d content=%q", messageType, len(*message.Content), *message.Content)
+
+	text, err := mh.renderMessageContent(messageType, *message.Content, eventMentions(message.Mentions))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// renderMessageContent 按消息类型渲染内容
+func (mh *MessageHandler) renderMessageContent(messageType, content string, mentions map[string]mentionInfo) (string, error) {
+	switch messageType {
+	case "text":
+		var textContent struct {
+			Text *string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(content), &textContent); err != nil {
+			// 如果不是JSON格式，直接返回原内容
+			return content, nil
+		}
+		if textContent.Text == nil {
+			return "", fmt.Errorf("no text field found in content")
+		}
+		return mh.replaceMentions(*textContent.Text, mentions), nil
+	case "post":
+		return mh.renderPost(content, mentions)
+	default:
+		return "", fmt.Errorf("unsupported message type: %s", messageType)
+	}
+}
+
+// replaceMentions 替换文本中的 @ 占位符
+func (mh *MessageHandler) replaceMentions(text string, mentions map[string]mentionInfo) string {
+	var builder strings.Builder
+	last := 0
+	for _, loc := range mentionPlaceholder.FindAllStringIndex(text, -1) {
+		builder.WriteString(text[last:loc[0]])
+		key := text[loc[0]:loc[1]]
+		replacement := mh.mentionText(key, mentions)
+		builder.WriteString(replacement)
+		last = loc[1]
+		// 移除 @ 时一并去掉其后的一个空格
+		if replacement == "" && last < len(text) && text[last] == ' ' {
+			last++
+		}
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// mentionText 返回占位符的替换文本；指向机器人时返回空字符串
+func (mh *MessageHandler) mentionText(key string, mentions map[string]mentionInfo) string {
+	if key == "@_all" {
+		return "@所有人"
+	}
+	info, ok := mentions[key]
+	if !ok {
+		return key
+	}
+	if mh.isBotMention(info) {
+		return ""
+	}
+	if info.Name == "" {
+		return key
+	}
+	return "@" + info.Name
+}
+
+// isBotMention 判断 @ 是否指向机器人自己（未知机器人 open_id 时一律不是）
+func (mh *MessageHandler) isBotMention(info mentionInfo) bool {
+	return mh.botOpenID != "" && info.OpenID == mh.botOpenID
+}
+
+// postElement 富文本中的一个元素
+type postElement struct {
+	Tag       string   `json:"tag"`
+	Text      string   `json:"text"`
+	Href      string   `json:"href"`
+	UserID    string   `json:"user_id"`
+	UserName  string   `json:"user_name"`
+	Language  string   `json:"language"`
+	Style     []string `json:"style"`
+	EmojiType string   `json:"emoji_type"`
+}
+
+// postContent 富文本消息内容
+type postContent struct {
+	Title   string          `json:"title"`
+	Content [][]postElement `json:"content"`
+}
+
+// parsePost 解析富文本；兼容直接内容和按语言包装（zh_cn / en_us ...）两种格式
+func parsePost(content string) (postContent, error) {
+	var post postContent
+	if err := json.Unmarshal([]byte(content), &post); err == nil && (post.Title != "" || len(post.Content) > 0) {
+		return post, nil
+	}
+
+	var localized map[string]postContent
+	if err := json.Unmarshal([]byte(content), &localized); err != nil {
+		return postContent{}, fmt.Errorf("failed to parse post content: %w", err)
+	}
+	for _, lang := range []string{"zh_cn", "en_us", "ja_jp"} {
+		if p, ok := localized[lang]; ok {
+			return p, nil
+		}
+	}
+	for _, p := range localized {
+		return p, nil
+	}
+	return postContent{}, nil
+}
+
+// renderPost 将富文本转为 markdown：保留代码块、链接、行内代码和基本样式
+func (mh *MessageHandler) renderPost(content string, mentions map[string]mentionInfo) (string, error) {
+	post, err := parsePost(content)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	if title := strings.TrimSpace(post.Title); title != "" {
+		builder.WriteString("**" + title + "**\n\n")
+	}
+
+	for i, paragraph := range post.Content {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		for j, el := range paragraph {
+			switch el.Tag {
+			case "text":
+				builder.WriteString(styleText(el.Text, el.Style))
+			case "md":
+				builder.WriteString(el.Text)
+			case "a":
+				text := el.Text
+				if text == "" {
+					text = el.Href
+				}
+				builder.WriteString(fmt.Sprintf("[%s](%s)", text, el.Href))
+			case "at":
+				if el.UserID == "all" || el.UserID == "@_all" {
+					builder.WriteString("@所有人")
+				} else if _, ok := mentions[el.UserID]; ok {
+					builder.WriteString(mh.mentionText(el.UserID, mentions))
+				} else if el.UserName != "" {
+					builder.WriteString("@" + el.UserName)
+				}
+			case "code_block":
+				if builder.Len() > 0 && !strings.HasSuffix(builder.String(), "\n") {
+					builder.WriteString("\n")
+				}
+				builder.WriteString("```" + strings.ToLower(el.Language) + "\n")
+				builder.WriteString(strings.TrimRight(el.Text, "\n"))
+				builder.WriteString("\n```")
+				// 同一段落中代码块后还有内容时换行，避免与结束的 ``` 连在一起
+				if j < len(paragraph)-1 {
+					builder.WriteString("\n")
+				}
+			case "hr":
+				builder.WriteString("\n---\n")
+			case "img":
+				builder.WriteString("[图片]")
+			case "media":
+				builder.WriteString("[视频]")
+			case "emotion":
+				builder.WriteString("[" + el.EmojiType + "]")
+			default:
+				builder.WriteString(el.Text)
+			}
+		}
+	}
+	return builder.String(), nil
+}
+
+// styleText 按富文本样式包装文本（行内代码优先，内部不再叠加其它样式）
+func styleText(text string, styles []string) string {
+	if text == "" || len(styles) == 0 {
+		return text
+	}
+	set := make(map[string]bool, len(styles))
+	for _, s := range styles {
+		set[s] = true
+	}
+	if set["codeInline"] || set["code_inline"] {
+		return "`" + text + "`"
+	}
+	if set["bold"] {
+		text = "**" + text + "**"
+	}
+	if set["italic"] {
+		text = "*" + text + "*"
+	}
+	if set["lineThrough"] {
+		text = "~~" + text + "~~"
+	}
+	return text
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"testing"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+func TestRenderPost(t *testing.T) {
+	mentions := map[string]mentionInfo{
+		"@_user_1": {Name: "机器人", OpenID: "ou_bot"},
+		"@_user_2": {Name: "张三", OpenID: "ou_zhang"},
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"code block followed by text",
+			`{"content":[[{"tag":"code_block","language":"Go","text":"fmt.Println()\n"},{"tag":"text","text":"这段为什么报错"}]]}`,
+			"```go\nfmt.Println()\n```\n这段为什么报错",
+		},
+		{
+			"text before code block",
+			`{"content":[[{"tag":"text","text":"看这段："},{"tag":"code_block","language":"","text":"ls -la"}]]}`,
+			"看这段：\n```\nls -la\n```",
+		},
+		{
+			"code block ends paragraph",
+			`{"content":[[{"tag":"code_block","language":"sh","text":"make"}],[{"tag":"text","text":"下一段"}]]}`,
+			"```sh\nmake\n```\n下一段",
+		},
+		{
+			"link",
+			`{"content":[[{"tag":"text","text":"见 "},{"tag":"a","text":"文档","href":"https://example.com/a"}]]}`,
+			"见 [文档](https://example.com/a)",
+		},
+		{
+			"link without text",
+			`{"content":[[{"tag":"a","href":"https://example.com"}]]}`,
+			"[https://example.com](https://example.com)",
+		},
+		{
+			"inline code and styles",
+			`{"content":[[{"tag":"text","text":"go vet","style":["codeInline","bold"]},{"tag":"text","text":" 和 "},{"tag":"text","text":"重点","style":["bold","italic"]},{"tag":"text","text":"旧","style":["lineThrough"]}]]}`,
+			"`go vet` 和 ***重点***~~旧~~",
+		},
+		{
+			"mentions",
+			`{"content":[[{"tag":"at","user_id":"@_user_1"},{"tag":"text","text":" 请 "},{"tag":"at","user_id":"@_user_2"},{"tag":"text","text":" 和 "},{"tag":"at","user_id":"@_all"},{"tag":"text","text":" 以及 "},{"tag":"at","user_id":"ou_x","user_name":"李四"}]]}`,
+			" 请 @张三 和 @所有人 以及 @李四",
+		},
+		{
+			"localized with title",
+			`{"zh_cn":{"title":"标题","content":[[{"tag":"text","text":"正文"}],[{"tag":"hr"}],[{"tag":"img"},{"tag":"emotion","emoji_type":"OK"}]]}}`,
+			"**标题**\n\n正文\n\n---\n\n[图片][OK]",
+		},
+	}
+	mh := &MessageHandler{botOpenID: "ou_bot"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mh.renderPost(tt.content, mentions)
+			if err != nil {
+				t.Fatalf("renderPost() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderPost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTextMentions(t *testing.T) {
+	mentions := map[string]mentionInfo{
+		"@_user_1": {Name: "机器人", OpenID: "ou_bot"},
+		"@_user_2": {Name: "张三", OpenID: "ou_zhang"},
+		"@_user_3": {OpenID: "ou_noname"},
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"bot mention removed", `{"text":"@_user_1 你好"}`, "你好"},
+		{"other user kept", `{"text":"@_user_1 问 @_user_2 吧"}`, "问 @张三 吧"},
+		{"bot mention in the middle", `{"text":"问一下 @_user_1 这个"}`, "问一下 这个"},
+		{"all", `{"text":"@_all 通知"}`, "@所有人 通知"},
+		{"unknown placeholder", `{"text":"@_user_9 x"}`, "@_user_9 x"},
+		{"user without name", `{"text":"@_user_3 x"}`, "@_user_3 x"},
+		{"not JSON", `plain text`, "plain text"},
+	}
+	mh := &MessageHandler{botOpenID: "ou_bot"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mh.renderMessageContent("text", tt.content, mentions)
+			if err != nil {
+				t.Fatalf("renderMessageContent() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderMessageContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMessageContentErrors(t *testing.T) {
+	mh := &MessageHandler{botOpenID: "ou_bot"}
+	tests := []struct {
+		name        string
+		messageType string
+		content     string
+	}{
+		{"text without text field", "text", `{"foo":"bar"}`},
+		{"invalid post", "post", `not json`},
+		{"unsupported type", "image", `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := mh.renderMessageContent(tt.messageType, tt.content, nil); err == nil {
+				t.Fatalf("renderMessageContent() = %q, want error", got)
+			}
+		})
+	}
+}
+
+func TestIsMentioned(t *testing.T) {
+	mention := func(openID string) *larkim.MentionEvent {
+		return &larkim.MentionEvent{Key: larkcore.StringPtr("@_user_1"), Id: &larkim.UserId{OpenId: larkcore.StringPtr(openID)}}
+	}
+	tests := []struct {
+		name      string
+		botOpenID string
+		mentions  []*larkim.MentionEvent
+		want      bool
+	}{
+		{"bot mentioned", "ou_bot", []*larkim.MentionEvent{mention("ou_zhang"), mention("ou_bot")}, true},
+		{"other user mentioned", "ou_bot", []*larkim.MentionEvent{mention("ou_zhang")}, false},
+		{"no mentions", "ou_bot", nil, false},
+		{"unknown bot open_id", "", []*larkim.MentionEvent{mention("ou_zhang")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mh := &MessageHandler{botOpenID: tt.botOpenID}
+			if got := mh.isMentioned(&larkim.EventMessage{Mentions: tt.mentions}); got != tt.want {
+				t.Fatalf("isMentioned() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 未知机器人 open_id 时，开头的 @ 也不会被当作 @机器人 移除
+	mh := &MessageHandler{}
+	got := mh.replaceMentions("@_user_1 你好", map[string]mentionInfo{"@_user_1": {Name: "张三", OpenID: "ou_zhang"}})
+	if got != "@张三 你好" {
+		t.Fatalf("replaceMentions() = %q, want %q", got, "@张三 你好")
+	}
+}
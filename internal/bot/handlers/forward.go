@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+const (
+	defaultForwardWait = 30 * time.Second // 等待用户补充说明的默认时长
+	forwardMaxDepth    = 5                // 嵌套合并转发的最大展开层数
+
+	// forwardDefaultInstruction 转发后没有补充说明时使用的默认指令
+	forwardDefaultInstruction = "请阅读以上转发的聊天记录，总结讨论的问题并给出你的分析和建议。"
+	// forwardDescription 没有补充说明时完成卡片中显示的任务描述
+	forwardDescription = "分析转发的聊天记录"
+)
+
+// pendingForward 等待补充说明的合并转发
+type pendingForward struct {
+	transcript string
+	timer      *time.Timer
+}
+
+// forwardHolder 暂存合并转发的聊天记录，等待同一用户的下一条消息作为指令
+type forwardHolder struct {
+	mu      sync.Mutex
+	pending map[string]*pendingForward
+}
+
+// forwardKey 按会话和发送者区分暂存的转发
+func forwardKey(chatID, openID string) string {
+	return chatID + ":" + openID
+}
+
+// hold 暂存转发内容；wait 内没有后续消息时调用 onTimeout（同一 key 的旧转发会被合并）
+func (h *forwardHolder) hold(key, transcript string, wait time.Duration, onTimeout func(transcript string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pending == nil {
+		h.pending = make(map[string]*pendingForward)
+	}
+	if prev, ok := h.pending[key]; ok {
+		prev.timer.Stop()
+		transcript = prev.transcript + "\n\n" + transcript
+	}
+
+	p := &pendingForward{transcript: transcript}
+	p.timer = time.AfterFunc(wait, func() {
+		h.mu.Lock()
+		current, ok := h.pending[key]
+		if ok && current == p {
+			delete(h.pending, key)
+		}
+		h.mu.Unlock()
+		if ok && current == p {
+			onTimeout(p.transcript)
+		}
+	})
+	h.pending[key] = p
+}
+
+// take 取出暂存的转发内容（没有时返回空字符串）
+func (h *forwardHolder) take(key string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.pending[key]
+	if !ok {
+		return ""
+	}
+	p.timer.Stop()
+	delete(h.pending, key)
+	return p.transcript
+}
+
+// withForwardedMessages 把暂存的转发聊天记录拼接在用户指令前
+func (mh *MessageHandler) withForwardedMessages(chatID, openID, question string) string {
+	transcript := mh.forwards.take(forwardKey(chatID, openID))
+	if transcript == "" {
+		return question
+	}
+	mh.logger.Printf("[DEBUG] Forwarded messages attached: chat_id=%s open_id=%s len=%d", chatID, openID, len(transcript))
+	return transcript + "\n\n" + question
+}
+
+// handleP2PForward 单聊收到合并转发：展开并暂存，超时未收到补充说明时直接分析
+func (mh *MessageHandler) handleP2PForward(event *larkim.P2MessageReceiveV1) error {
+	message := event.Event.Message
+	openID := *event.Event.Sender.SenderId.OpenId
+	userID := openID
+	if event.Event.Sender.SenderId.UnionId != nil {
+		userID = *event.Event.Sender.SenderId.UnionId
+	}
+	messageID, chatID := "", ""
+	if message.MessageId != nil {
+		messageID = *message.MessageId
+	}
+	if message.ChatId != nil {
+		chatID = *message.ChatId
+	}
+
+	transcript, err := mh.expandMergeForward(messageID)
+	if err != nil {
+		mh.logger.Printf("Failed to expand merge_forward %s: %v", messageID, err)
+		return mh.sendTextMessage(openID, "open_id", "❌ 无法读取转发的聊天记录: "+err.Error())
+	}
+
+	wait := forwardWait()
+	mh.forwards.hold(forwardKey(chatID, openID), transcript, wait, func(transcript string) {
+		status := mh.newStatusReaction(messageID)
+		status.Set(reactionReceived)
+		if err := mh.processMessage(openID, userID, openID, "open_id", forwardPrompt(transcript), forwardDescription, messageID, "", status); err != nil {
+			mh.logger.Printf("Failed to process forwarded messages: %v", err)
+		}
+	})
+	return mh.sendTextMessage(openID, "open_id",
+		fmt.Sprintf("📎 已收到转发的聊天记录，请在 %s 内发送你的问题；否则将直接分析这段记录", wait))
+}
+
+// holdGroupForward 群聊收到合并转发：展开并暂存；超时未收到补充说明时，按群聊响应策略决定是否直接分析
+func (mh *MessageHandler) holdGroupForward(message *larkim.EventMessage, groupSessionID, chatID, openID, userID, messageID string) error {
+	transcript, err := mh.expandMergeForward(messageID)
+	if err != nil {
+		mh.logger.Printf("Failed to expand merge_forward %s: %v", messageID, err)
+		return err
+	}
+
+	mh.forwards.hold(forwardKey(chatID, openID), transcript, forwardWait(), func(transcript string) {
+		if !mh.shouldRespondUnmentioned(chatID, message) {
+			mh.logger.Printf("[DEBUG] Dropping forwarded messages without follow-up: chat_id=%s message_id=%s", chatID, messageID)
+			return
+		}
+		status := mh.newStatusReaction(messageID)
+		status.Set(reactionReceived)
+		question := mh.withChatHistory(chatID, messageID, mh.withSenderTag(openID, forwardPrompt(transcript)))
+		if err := mh.processGroupMessage(groupSessionID, userID, openID, chatID, "chat_id", question, forwardDescription, messageID, "", status); err != nil {
+			mh.logger.Printf("Failed to process forwarded messages: %v", err)
+		}
+	})
+	return nil
+}
+
+// forwardPrompt 没有补充说明时的完整提示
+func forwardPrompt(transcript string) string {
+	return transcript + "\n\n" + forwardDefaultInstruction
+}
+
+// isMergeForward 是否为合并转发消息
+func isMergeForward(message *larkim.EventMessage) bool {
+	return message != nil && message.MessageType != nil && *message.MessageType == "merge_forward"
+}
+
+// expandMergeForward 展开合并转发消息为带发送者和时间的有序聊天记录
+func (mh *MessageHandler) expandMergeForward(messageID string) (string, error) {
+	items, err := mh.feishuClient.GetMessageItems(messageID)
+	if err != nil {
+		return "", err
+	}
+
+	children := make(map[string][]*larkim.Message)
+	for _, item := range items {
+		if item == nil || item.UpperMessageId == nil || *item.UpperMessageId == "" {
+			continue
+		}
+		children[*item.UpperMessageId] = append(children[*item.UpperMessageId], item)
+	}
+	if len(children[messageID]) == 0 {
+		return "", fmt.Errorf("merge_forward %s has no messages", messageID)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("<forwarded_messages>\n")
+	mh.writeForwardItems(&builder, children, messageID, 0)
+	builder.WriteString("</forwarded_messages>")
+	return builder.String(), nil
+}
+
+// writeForwardItems 按层级写出转发的消息，嵌套的合并转发缩进展开
+func (mh *MessageHandler) writeForwardItems(builder *strings.Builder, children map[string][]*larkim.Message, parentID string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, item := range children[parentID] {
+		if item.Deleted != nil && *item.Deleted {
+			continue
+		}
+		header := fmt.Sprintf("%s[%s] %s: ", indent, formatMessageTime(item.CreateTime), mh.senderName(item))
+
+		if item.MsgType != nil && *item.MsgType == "merge_forward" && item.MessageId != nil {
+			builder.WriteString(header + "[合并转发]\n")
+			if depth+1 < forwardMaxDepth {
+				mh.writeForwardItems(builder, children, *item.MessageId, depth+1)
+			}
+			continue
+		}
+
+		text := strings.TrimSpace(mh.renderStoredMessage(item))
+		text = strings.ReplaceAll(text, "\n", "\n"+indent+"  ")
+		builder.WriteString(header + text + "\n")
+	}
+}
+
+// forwardWait 等待补充说明的时长（FORWARD_FOLLOWUP_WAIT）
+func forwardWait() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("FORWARD_FOLLOWUP_WAIT")); err == nil && value > 0 {
+		return value
+	}
+	return defaultForwardWait
+}
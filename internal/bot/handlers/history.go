@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"feishu-bot/internal/config"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// defaultHistoryMaxTokens 聊天记录上下文的默认 token 上限
+const defaultHistoryMaxTokens = 4000
+
+// withChatHistory 群聊开启 context N 时，获取最近 N 条消息作为上下文拼接在问题前
+// 获取失败时只记录日志，原样返回问题
+func (mh *MessageHandler) withChatHistory(chatID, triggerMessageID, question string) string {
+	size := 0
+	if cfg, err := config.Load(); err == nil {
+		size = cfg.GetContextSize(chatID)
+	}
+	if size <= 0 {
+		return question
+	}
+
+	// 多取一条，排除触发消息本身
+	messages, err := mh.feishuClient.ListRecentMessages(chatID, size+1)
+	if err != nil {
+		mh.logger.Printf("Failed to list chat history for %s: %v", chatID, err)
+		return question
+	}
+
+	// 接口按时间倒序返回；从新到旧累加，超过 token 上限时丢弃更早的消息
+	budget := historyMaxTokens()
+	var lines []string
+	for _, msg := range messages {
+		if len(lines) >= size {
+			break
+		}
+		if msg == nil || (msg.MessageId != nil && *msg.MessageId == triggerMessageID) {
+			continue
+		}
+		if msg.Deleted != nil && *msg.Deleted {
+			continue
+		}
+		text := strings.TrimSpace(mh.renderStoredMessage(msg))
+		if text == "" {
+			continue
+		}
+
+		line := fmt.Sprintf("[%s] %s: %s", formatMessageTime(msg.CreateTime), mh.senderName(msg), text)
+		cost := estimateTokens(line)
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return question
+	}
+
+	// 恢复为时间正序
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	mh.logger.Printf("[DEBUG] Chat history attached: chat_id=%s messages=%d", chatID, len(lines))
+
+	var builder strings.Builder
+	builder.WriteString("<chat_history>\n")
+	builder.WriteString(strings.Join(lines, "\n"))
+	builder.WriteString("\n</chat_history>\n\n")
+	builder.WriteString(question)
+	return builder.String()
+}
+
+// senderName 返回消息发送者的显示名
+func (mh *MessageHandler) senderName(msg *larkim.Message) string {
+	if mh.feishuClient.IsBotMessage(msg) {
+		return "机器人"
+	}
+	if msg.Sender == nil || msg.Sender.Id == nil {
+		return "未知用户"
+	}
+	if msg.Sender.IdType != nil && *msg.Sender.IdType != "open_id" {
+		return *msg.Sender.Id
+	}
+	return mh.userName(*msg.Sender.Id)
+}
+
+// userName 查询用户显示名（缓存在 FeishuClient 中），查询失败时返回 open_id
+func (mh *MessageHandler) userName(openID string) string {
+	name, err := mh.feishuClient.GetUserName(openID)
+	if err != nil {
+		mh.logger.Printf("[DEBUG] Failed to get user name for %s: %v", openID, err)
+		return openID
+	}
+	return name
+}
+
+// estimateTokens 粗略估算 token 数：ASCII 约 4 字符一个 token，其它字符（中文等）约一个字符一个 token
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return ascii/4 + other + 1
+}
+
+// historyMaxTokens 聊天记录上下文 token 上限（CHAT_CONTEXT_MAX_TOKENS）
+func historyMaxTokens() int {
+	if value, err := strconv.Atoi(os.Getenv("CHAT_CONTEXT_MAX_TOKENS")); err == nil && value > 0 {
+		return value
+	}
+	return defaultHistoryMaxTokens
+}
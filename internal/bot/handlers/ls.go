@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bot/internal/bot/card"
+	"feishu-bot/internal/config"
+	"feishu-bot/internal/project"
+)
+
+// defaultLsPageSize ls 每页显示的项目数
+const defaultLsPageSize = 10
+
+// ActionBindProject 卡片中"绑定"按钮的 action
+const ActionBindProject = "bind_project"
+
+// bindButtonValue "绑定"按钮回传的值
+type bindButtonValue struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Scope  string `json:"scope"`   // group / p2p
+	ChatID string `json:"chat_id"` // 卡片发出的会话，点击时须与回调中的会话一致
+}
+
+// lsEntry ls 中的一个项目
+type lsEntry struct {
+	index   int    // 与 bind 序号一致
+	name    string // 多个根目录时带根目录名
+	path    string
+	status  project.Status
+	boundBy int // 绑定了该项目的其它会话数
+	current bool
+}
+
+// lsPageSize 每页项目数（LS_PAGE_SIZE）
+func lsPageSize() int {
+	if value, err := strconv.Atoi(os.Getenv("LS_PAGE_SIZE")); err == nil && value > 0 {
+		return value
+	}
+	return defaultLsPageSize
+}
+
+// parseLsArgs 解析 ls 参数：末尾的数字为页码，其余为过滤关键字
+func parseLsArgs(args string) (filter string, page int) {
+	fields := strings.Fields(args)
+	page = 1
+	if n := len(fields); n > 0 {
+		if p, err := strconv.Atoi(fields[n-1]); err == nil {
+			page = p
+			fields = fields[:n-1]
+		}
+	}
+	return strings.Join(fields, " "), page
+}
+
+// handleLsCommand 处理 ls 命令 - 按最近活动排序列出可绑定的项目，支持分页（ls 2）和过滤（ls api）
+func (mh *MessageHandler) handleLsCommand(cc *commandContext) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 加载配置失败: %v", err))
+	}
+	catalog, err := loadProjectCatalog(cfg)
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
+	}
+
+	filter, page := parseLsArgs(cc.args)
+	entries := catalog.entries(filter)
+	total := len(entries)
+
+	pageSize := lsPageSize()
+	pages := (len(entries) + pageSize - 1) / pageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page < 1 || page > pages {
+		return cc.reply(mh, fmt.Sprintf("❌ 页码超出范围，共 %d 页", pages))
+	}
+	start := (page - 1) * pageSize
+	end := start + pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	entries = entries[start:end]
+
+	// 只检查当前页的项目，避免项目很多时逐个执行 git；最近活动时间沿用排序时的结果
+	paths := make([]string, len(entries))
+	activity := make(map[string]time.Time, len(entries))
+	for i, e := range entries {
+		paths[i] = e.path
+		activity[e.path] = e.status.LastActivity
+	}
+	bindings, err := mh.store.ListBindings()
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 读取项目绑定失败: %v", err))
+	}
+	current := ""
+	boundBy := make(map[string]int) // 项目路径 -> 绑定该项目的其它会话数
+	for _, b := range bindings {
+		switch {
+		case b.ProjectPath == "":
+		case b.Key == cc.bindingKey():
+			current = b.ProjectPath
+		default:
+			boundBy[b.ProjectPath]++
+		}
+	}
+	statuses := project.InspectAll(paths, activity)
+	for i := range entries {
+		e := &entries[i]
+		e.status = statuses[e.path]
+		e.current = e.path == current
+		e.boundBy = boundBy[e.path]
+	}
+
+	view := lsView{
+		filter:  filter,
+		page:    page,
+		pages:   pages,
+		total:   total,
+		entries: entries,
+		current: current,
+		group:   cc.group,
+		chatID:  cc.chatID,
+	}
+	for _, g := range catalog.groups {
+		if g.err != nil {
+			view.errors = append(view.errors, fmt.Sprintf("%s（%s）: %v", g.root.Name, g.root.Path, g.err))
+		}
+	}
+	if filter == "" && page == 1 {
+		for _, alias := range catalog.aliasNames() {
+			view.aliases = append(view.aliases, fmt.Sprintf("%s → %s", alias, catalog.aliases[alias]))
+		}
+	}
+
+	cardJSON, err := view.card()
+	if err == nil {
+		err = mh.feishuClient.SendCard(cc.receiveID, cc.receiveIDType, cardJSON, "")
+	}
+	if err != nil {
+		mh.logger.Printf("Failed to send ls card, falling back to text: %v", err)
+		return cc.reply(mh, view.text())
+	}
+	return nil
+}
+
+// entries 匹配 filter 的项目（filter 为空时为全部），按最近活动倒序排列
+// 过滤先按名称包含关键字（不区分大小写），没有结果时再使用模糊匹配
+func (c *projectCatalog) entries(filter string) []lsEntry {
+	var all []lsEntry
+	index := 0
+	for _, g := range c.groups {
+		for _, p := range g.projects {
+			index++
+			all = append(all, lsEntry{index: index, name: c.qualifiedName(g.root.Name, p.Name), path: p.Path})
+		}
+	}
+	if filter == "" {
+		return sortByActivity(all)
+	}
+
+	query := strings.ToLower(filter)
+	var matched []lsEntry
+	for _, e := range all {
+		if strings.Contains(strings.ToLower(e.name), query) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		candidates := make([]project.Project, len(all))
+		for i, e := range all {
+			candidates[i] = project.Project{Name: e.name, Path: e.path}
+		}
+		fuzzy := make(map[string]bool)
+		for _, p := range project.Match(candidates, filter) {
+			fuzzy[p.Path] = true
+		}
+		for _, e := range all {
+			if fuzzy[e.path] {
+				matched = append(matched, e)
+			}
+		}
+	}
+	return sortByActivity(matched)
+}
+
+// sortByActivity 按最近活动时间倒序排序，并记录到 status.LastActivity
+// 排序需要全部项目的活动时间，这里只读取最后一次提交时间，开销远小于完整的 git status
+func sortByActivity(entries []lsEntry) []lsEntry {
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.path
+	}
+	activity := project.LastActivities(paths)
+	for i := range entries {
+		entries[i].status.LastActivity = activity[entries[i].path]
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return activity[entries[i].path].After(activity[entries[j].path])
+	})
+	return entries
+}
+
+// lsView ls 的一页结果
+type lsView struct {
+	filter  string
+	page    int
+	pages   int
+	total   int
+	entries []lsEntry
+	aliases []string
+	errors  []string
+	current string
+	group   bool
+	chatID  string // 卡片发出的会话
+}
+
+// title 标题
+func (v lsView) title() string {
+	title := fmt.Sprintf("📂 项目列表（第 %d/%d 页，共 %d 个）", v.page, v.pages, v.total)
+	if v.filter != "" {
+		title = fmt.Sprintf("📂 匹配 %q 的项目（第 %d/%d 页，共 %d 个）", v.filter, v.page, v.pages, v.total)
+	}
+	return title
+}
+
+// describe 项目的状态描述：分支、是否有改动、最近活动、绑定情况
+func (e lsEntry) describe() string {
+	var parts []string
+	if e.status.Git {
+		branch := e.status.Branch
+		if branch == "" {
+			branch = "?"
+		}
+		state := "干净"
+		if e.status.Dirty {
+			state = "有未提交改动"
+		}
+		parts = append(parts, fmt.Sprintf("`%s` %s", branch, state))
+	} else {
+		parts = append(parts, "非 git 仓库")
+	}
+	if !e.status.LastActivity.IsZero() {
+		parts = append(parts, formatAgo(e.status.LastActivity))
+	}
+	if e.boundBy > 0 {
+		parts = append(parts, fmt.Sprintf("另有 %d 个会话绑定", e.boundBy))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// hint 翻页和使用提示
+func (v lsView) hint() string {
+	var hints []string
+	if v.page < v.pages {
+		next := fmt.Sprintf("/ls %d", v.page+1)
+		if v.filter != "" {
+			next = fmt.Sprintf("/ls %s %d", v.filter, v.page+1)
+		}
+		hints = append(hints, "下一页: "+next)
+	}
+	hints = append(hints, "过滤: /ls <关键字>", "绑定: /bind <项目> [附加项目...]")
+	return strings.Join(hints, "  |  ")
+}
+
+// card 构建带"绑定"按钮的卡片
+// 按钮只对发出卡片的会话有效，卡片禁止转发（转发到其它会话后按钮也会被拒绝）
+func (v lsView) card() (string, error) {
+	scope := "p2p"
+	if v.group {
+		scope = "group"
+	}
+
+	c := card.New().
+		WithConfig(&card.Config{WideScreenMode: true, EnableForward: false}).
+		WithHeader(v.title(), card.HeaderBlue)
+	if len(v.entries) == 0 {
+		c.Add(card.NewMarkdown("没有找到项目"))
+	}
+	for _, e := range v.entries {
+		line := fmt.Sprintf("**#%d %s**", e.index, e.name)
+		if e.current {
+			line += " ✅ 当前绑定"
+		}
+		div := card.NewDiv(line + "\n" + e.describe())
+		if !e.current {
+			div.Extra = card.NewButton("绑定", card.ButtonPrimary, bindButtonValue{Action: ActionBindProject, Path: e.path, Scope: scope, ChatID: v.chatID})
+		}
+		c.Add(div)
+	}
+	if len(v.aliases) > 0 {
+		c.Add(card.NewHr(), card.NewMarkdown("**🔖 项目别名**\n"+strings.Join(v.aliases, "\n")))
+	}
+	if len(v.errors) > 0 {
+		c.Add(card.NewHr(), card.NewMarkdown("❌ 无法读取的根目录：\n"+strings.Join(v.errors, "\n")))
+	}
+	c.Add(card.NewHr(), card.NewNote(v.hint()))
+	return c.String()
+}
+
+// text 卡片发送失败时的文本回复
+func (v lsView) text() string {
+	var builder strings.Builder
+	builder.WriteString(v.title() + "\n\n")
+	if len(v.entries) == 0 {
+		builder.WriteString("没有找到项目\n")
+	}
+	for _, e := range v.entries {
+		builder.WriteString(fmt.Sprintf("%d. %s\n   %s\n", e.index, e.name, strings.ReplaceAll(e.describe(), "`", "")))
+	}
+	if len(v.aliases) > 0 {
+		builder.WriteString("\n🔖 项目别名：\n• " + strings.Join(v.aliases, "\n• ") + "\n")
+	}
+	if len(v.errors) > 0 {
+		builder.WriteString("\n❌ 无法读取的根目录：\n" + strings.Join(v.errors, "\n") + "\n")
+	}
+	builder.WriteString("\n" + v.hint())
+	if v.current != "" {
+		builder.WriteString(fmt.Sprintf("\n\n✅ 当前绑定: %s", v.current))
+	}
+	return builder.String()
+}
+
+// formatAgo 将时间格式化为"N 分钟前"等相对时间
+func formatAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "刚刚"
+	case d < time.Hour:
+		return fmt.Sprintf("%d 分钟前", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d 小时前", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%d 天前", int(d.Hours()/24))
+	}
+	return t.Format("2006-01-02")
+}
+
+// HandleBindAction 处理 ls 卡片中的"绑定"按钮，返回提示文本
+// chatID 为回调上下文中的会话，cardChatID 为卡片发出的会话：两者不一致（卡片被转发到其它会话）时拒绝，
+// 保证按钮中的 scope 属于当前会话
+// 群聊按 chatID 绑定，单聊按点击者绑定；与 bind 命令一样检查访问控制，并重新确认路径位于项目根目录内
+func (mh *MessageHandler) HandleBindAction(openID, userID, chatID, cardChatID, scope, path string) (string, error) {
+	group := scope == "group"
+	if openID == "" || chatID == "" {
+		return "", fmt.Errorf("无法识别操作者或会话")
+	}
+	if cardChatID != chatID {
+		return "", fmt.Errorf("卡片不属于当前会话，请重新发送 /ls")
+	}
+	subject := config.Subject{OpenID: openID, UserID: userID}
+	checkChat := ""
+	if group {
+		checkChat = chatID
+	}
+
+	access, subject, err := mh.authorizeSubject(subject, checkChat)
+	if err == nil {
+		bind := commandIndex["bind"]
+		err = access.CheckCommand(bind.name, bind.requiredPermission(group), subject)
+	}
+	if err != nil {
+		mh.writeAudit(auditRecord{
+			Time:     time.Now().Format(time.RFC3339),
+			OpenID:   subject.OpenID,
+			UserID:   subject.UserID,
+			ChatID:   chatID,
+			ChatType: scope,
+			Command:  "bind",
+			Reason:   err.Error(),
+		})
+		return "", err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("加载配置失败: %w", err)
+	}
+	catalog, err := loadProjectCatalog(cfg)
+	if err != nil {
+		return "", err
+	}
+	projectPath, tried, err := catalog.resolvePath(path)
+	if !tried || err != nil {
+		return "", fmt.Errorf("项目路径无效: %s", path)
+	}
+
+	// 按钮只绑定主项目，同时清除原有的附加目录
+	key := openID
+	if group {
+		key = chatID
+	}
+	if err := mh.bindProject(key, !group, projectPath, nil, openID); err != nil {
+		return "", fmt.Errorf("保存绑定失败: %w", err)
+	}
+	if !group {
+		mh.clearClaudeSession(openID)
+	}
+
+	mh.logger.Printf("[DEBUG] Bound project via card: scope=%s chat_id=%s open_id=%s path=%s", scope, chatID, openID, projectPath)
+	return "已绑定: " + projectPath, nil
+}
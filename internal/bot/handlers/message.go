@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"feishu-bot/internal/bot/client"
 	"feishu-bot/internal/claude"
 	"feishu-bot/internal/config"
+	"feishu-bot/internal/store"
 	"feishu-bot/internal/utils"
 	"fmt"
 	"log"
@@ -23,19 +24,22 @@ import (
 type MessageHandler struct {
 	logger           *log.Logger
 	feishuClient     *client.FeishuClient
-	recentMessageIDs map[string]time.Time
-	recentMessageMu  sync.Mutex
-	claudeSessions   map[string]string
-	claudeSessionMu  sync.Mutex
+	store            store.Store // Claude 会话、消息去重、运行记录与用量
+	botOpenID        string // 机器人自己的 open_id（用于识别 @机器人）
+	botThreads       map[string]botThreadEntry // 消息 ID -> 是否为机器人发送（thread 策略缓存，带有效期）
+	botThreadMu      sync.Mutex
+	forwards         forwardHolder   // 等待补充说明的合并转发
+	attachments      attachmentStore // 等待随下一条消息交给 Claude 的文件
 }
 
 // NewMessageHandler 创建消息处理器
-func NewMessageHandler(feishuClient *client.FeishuClient) *MessageHandler {
+func NewMessageHandler(feishuClient *client.FeishuClient, st store.Store) *MessageHandler {
 	return &MessageHandler{
 		feishuClient:     feishuClient,
 		logger:           log.New(log.Writer(), "[MessageHandler] ", log.LstdFlags),
-		recentMessageIDs: make(map[string]time.Time),
-		claudeSessions:   make(map[string]string),
+		store:            st,
+		botOpenID:        strings.TrimSpace(os.Getenv("FEISHU_BOT_OPEN_ID")),
+		botThreads:       make(map[string]botThreadEntry),
 	}
 }
 
@@ -56,6 +60,46 @@ func (mh *MessageHandler) HandleP2PMessage(ctx context.Context, event *larkim.P2
 		return nil
 	}
 
+	// 访问控制：用户白名单（对话与命令的权限在下面分别检查）
+	access, subject, err := mh.authorize(event)
+	if err != nil {
+		mh.auditDenied(event, subject, config.CommandChat, err)
+		return mh.sendTextMessage(*event.Event.Sender.SenderId.OpenId, "open_id", "⛔ 无权使用机器人: "+err.Error())
+	}
+
+	// 命令需要 / 前缀，其它消息都交给 Claude
+	if cmd, cmdArgs, isCmd := mh.p2pCommand(event); isCmd {
+		openID := *event.Event.Sender.SenderId.OpenId
+		chatID := ""
+		if event.Event.Message.ChatId != nil {
+			chatID = *event.Event.Message.ChatId
+		}
+		return mh.runCommand(cmd, &commandContext{
+			event:         event,
+			chatID:        chatID,
+			receiveID:     openID,
+			receiveIDType: "open_id",
+			openID:        openID,
+			args:          cmdArgs,
+			access:        access,
+			subject:       subject,
+		})
+	}
+	if err := access.CheckCommand(config.CommandChat, config.PermissionUser, subject); err != nil {
+		mh.auditDenied(event, subject, config.CommandChat, err)
+		return mh.sendTextMessage(*event.Event.Sender.SenderId.OpenId, "open_id", "⛔ "+err.Error())
+	}
+
+	// 合并转发：展开后暂存，等待用户的补充说明
+	if isMergeForward(event.Event.Message) {
+		return mh.handleP2PForward(event)
+	}
+
+	// 文件：暂存，随用户的下一条消息下载到收件目录
+	if isFileMessage(event.Event.Message) {
+		return mh.handleP2PFile(event)
+	}
+
 	// 获取消息内容
 	content, err := mh.extractTextContent(event.Event.Message)
 	if err != nil {
@@ -72,6 +116,10 @@ func (mh *MessageHandler) HandleP2PMessage(ctx context.Context, event *larkim.P2
 	}
 	mh.logger.Printf("[DEBUG] P2P content extracted: message_id=%s chat_id=%s len=%d content=%q", messageID, chatID, len(content), content)
 
+	// 先在用户消息上标记"已收到"，让用户在首次输出前也能看到进度
+	status := mh.newStatusReaction(messageID)
+	status.Set(reactionReceived)
+
 	openID := *event.Event.Sender.SenderId.OpenId
 	// 使用UnionId作为用户标识符，如果不存在则使用OpenId
 	var userID string
@@ -86,7 +134,11 @@ func (mh *MessageHandler) HandleP2PMessage(ctx context.Context, event *larkim.P2
 	receiveID := openID
 	receiveIDType := "open_id"
 	mh.logger.Printf("✅✅✅ P2P MODE: Using open_id=%s", openID) // 明确的标记
-	return mh.processMessage(openID, userID, receiveID, receiveIDType, content)
+	replySessionID := mh.replySession(event.Event.Message)
+	question := mh.withForwardedMessages(chatID, openID, content)
+	question = mh.withAttachments(chatID, openID, inboxDir(mh.userProjectDir(openID), openID), question)
+	question = mh.withQuotedContext(event.Event.Message, question)
+	return mh.processMessage(openID, userID, receiveID, receiveIDType, question, content, messageID, replySessionID, status)
 }
 
 // HandleGroupMessage 处理群聊消息
@@ -104,11 +156,15 @@ func (mh *MessageHandler) HandleGroupMessage(ctx context.Context, event *larkim.
 		return nil
 	}
 
-	// 获取消息内容
-	content, err := mh.extractTextContent(event.Event.Message)
-	if err != nil {
-		mh.logger.Printf("Failed to extract group message content: %v", err)
-		return err
+	// 获取消息内容（合并转发、文件在下面单独处理）
+	content := ""
+	if !isMergeForward(event.Event.Message) && !isFileMessage(event.Event.Message) {
+		var err error
+		content, err = mh.extractTextContent(event.Event.Message)
+		if err != nil {
+			mh.logger.Printf("Failed to extract group message content: %v", err)
+			return err
+		}
 	}
 
 	chatID := *event.Event.Message.ChatId
@@ -139,102 +195,256 @@ func (mh *MessageHandler) HandleGroupMessage(ctx context.Context, event *larkim.
 	receiveIDType := "chat_id"
 	mh.logger.Printf("✅✅✅ GROUP MODE: Using chat_id=%s global_session=%s sender=%s", chatID, groupSessionID, openID)
 
+	// 访问控制：群聊白名单与用户白名单（拒绝时只在需要响应的消息上提示）
+	access, subject, denied := mh.authorize(event)
+
+	if denied != nil && (isMergeForward(event.Event.Message) || isFileMessage(event.Event.Message)) {
+		mh.logger.Printf("[DEBUG] Dropping %s from unauthorized sender: chat_id=%s open_id=%s", *event.Event.Message.MessageType, chatID, openID)
+		return nil
+	}
+
+	// 合并转发：展开后暂存，等待同一用户的下一条消息作为指令
+	// 超时后会直接作为对话运行，暂存前先检查对话权限（无权对话时转发内容无从使用，直接丢弃）
+	if isMergeForward(event.Event.Message) {
+		if err := access.CheckCommand(config.CommandChat, config.PermissionUser, subject); err != nil {
+			mh.auditDenied(event, subject, config.CommandChat, err)
+			mh.logger.Printf("[DEBUG] Dropping merge_forward from sender without chat permission: chat_id=%s open_id=%s", chatID, openID)
+			return nil
+		}
+		return mh.holdGroupForward(event.Event.Message, groupSessionID, chatID, openID, userID, messageID)
+	}
+
+	// 文件：暂存，随同一用户的下一条消息下载到项目收件目录
+	// 文件消息无法 @机器人，此时尚未经过响应策略检查，群聊中不做任何回复（包括类型不允许的提示）
+	if isFileMessage(event.Event.Message) {
+		fileName, err := mh.holdAttachment(event.Event.Message, chatID, openID)
+		if errors.Is(err, errFileTypeNotAllowed) {
+			mh.logger.Printf("[DEBUG] Ignoring group attachment with disallowed type: chat_id=%s file=%s", chatID, fileName)
+			return nil
+		}
+		if err != nil {
+			mh.logger.Printf("Failed to hold group attachment: %v", err)
+		}
+		return err
+	}
+
 	// 检查是否 @机器人
 	isMentioned := mh.isMentioned(event.Event.Message)
 	mh.logger.Printf("[DEBUG] GROUP message: chat_id=%s is_mentioned=%t content=%q", chatID, isMentioned, content)
 
+	// 未 @机器人 时按群聊响应策略决定是否处理
+	if !isMentioned && !mh.shouldRespondUnmentioned(chatID, event.Event.Message) {
+		mh.logger.Printf("[DEBUG] Ignoring unmentioned group message: chat_id=%s message_id=%s", chatID, messageID)
+		return nil
+	}
+
+	if denied == nil && !isMentioned {
+		// 未 @机器人 的消息不会是命令，直接检查对话权限
+		denied = access.CheckCommand(config.CommandChat, config.PermissionUser, subject)
+	}
+	if denied != nil {
+		mh.auditDenied(event, subject, config.CommandChat, denied)
+		if !isMentioned {
+			return nil
+		}
+		return mh.sendTextMessage(receiveID, receiveIDType, "⛔ 无权使用机器人: "+denied.Error())
+	}
+
+	status := mh.newStatusReaction(messageID)
+	status.Set(reactionReceived)
+
 	// 如果 @机器人，检查是否为特殊命令
 	if isMentioned {
+		// @机器人 的占位符已在提取内容时移除
 		trimmedContent := strings.TrimSpace(content)
 
-		// 移除 @提及部分（@xxx 开头的都会被移除）
-		// 简单处理：按空格分割，取第一个非空部分之后的内容
-		parts := strings.Fields(trimmedContent)
-		if len(parts) > 0 && strings.HasPrefix(parts[0], "@") {
-			// 第一个部分是 @xxx，跳过它
-			trimmedContent = strings.Join(parts[1:], " ")
-		}
-		trimmedContent = strings.TrimSpace(trimmedContent)
-
 		// 空消息，提示使用
 		if trimmedContent == "" {
-			return mh.sendTextMessage(receiveID, receiveIDType,
-				"💡 提及机器人后输入问题即可对话\n发送 'help' 查看命令列表")
+			err := mh.sendTextMessage(receiveID, receiveIDType,
+				"💡 提及机器人后输入问题即可对话\n发送 /help 查看命令列表")
+			status.Finish(err)
+			return err
 		}
 
-		// 解析是否为特殊命令
-		cmdType, cmdArgs, isCmd := parseCommand(trimmedContent)
-		if isCmd {
-			// 处理特殊命令（不转发给 Claude）
-			switch cmdType {
-			case "ls":
-				return mh.handleLsCommand(receiveID)
-			case "bind":
-				return mh.handleBindCommand(receiveID, cmdArgs)
-			case "help":
-				return mh.handleHelpCommand(receiveID)
-			}
+		// 解析是否为命令
+		if cmd, cmdArgs, isCmd := parseCommand(trimmedContent, bareCommandsAllowed(true)); isCmd {
+			// 处理命令（不转发给 Claude）
+			cmdErr := mh.runCommand(cmd, &commandContext{
+				event:         event,
+				chatID:        chatID,
+				receiveID:     receiveID,
+				receiveIDType: receiveIDType,
+				openID:        openID,
+				args:          cmdArgs,
+				group:         true,
+				access:        access,
+				subject:       subject,
+			})
+			status.Finish(cmdErr)
+			return cmdErr
 		}
 
 		// 不是特殊命令，正常转发给 Claude CLI
-		return mh.processGroupMessage(groupSessionID, userID, receiveID, receiveIDType, trimmedContent)
-	}
-
-	// 不是 @机器人，正常处理对话
-	return mh.processGroupMessage(groupSessionID, userID, receiveID, receiveIDType, content)
+		if err := access.CheckCommand(config.CommandChat, config.PermissionUser, subject); err != nil {
+			mh.auditDenied(event, subject, config.CommandChat, err)
+			err = mh.sendTextMessage(receiveID, receiveIDType, "⛔ "+err.Error())
+			status.Finish(err)
+			return err
+		}
+		question := mh.withSenderTag(openID, trimmedContent)
+		question = mh.withForwardedMessages(chatID, openID, question)
+		question = mh.withAttachments(chatID, openID, mh.groupInboxDir(chatID), question)
+		question = mh.withQuotedContext(event.Event.Message, question)
+		question = mh.withChatHistory(chatID, messageID, question)
+		return mh.processGroupMessage(groupSessionID, userID, openID, receiveID, receiveIDType, question, trimmedContent, messageID, mh.replySession(event.Event.Message), status)
+	}
+
+	// 不是 @机器人（响应策略允许），正常处理对话
+	question := mh.withSenderTag(openID, content)
+	question = mh.withForwardedMessages(chatID, openID, question)
+	question = mh.withAttachments(chatID, openID, mh.groupInboxDir(chatID), question)
+	question = mh.withQuotedContext(event.Event.Message, question)
+	question = mh.withChatHistory(chatID, messageID, question)
+	return mh.processGroupMessage(groupSessionID, userID, openID, receiveID, receiveIDType, question, content, messageID, mh.replySession(event.Event.Message), status)
 }
 
-// processGroupMessage 处理群聊消息（使用全局共享会话）
-func (mh *MessageHandler) processGroupMessage(sessionID, userID, receiveID, receiveIDType, content string) error {
+// processGroupMessage 处理群聊消息（sessionID 为全局共享会话，按会话设置 session_scope 可改为按群/按用户；回复机器人消息时使用 replySessionID）
+// openID 为提问者，开启 GROUP_REPLY_MENTION 时回复会 @提问者；content 为发给 Claude 的完整提示，description 为用户的原始问题（显示在完成卡片中）
+func (mh *MessageHandler) processGroupMessage(sessionID, userID, openID, receiveID, receiveIDType, content, description, runID, replySessionID string, status *statusReaction) error {
 	mh.logger.Printf("[DEBUG] processGroupMessage: session_id=%s user_id=%s receive_id=%s receive_id_type=%s len=%d", sessionID, userID, receiveID, receiveIDType, len(content))
 
 	// 获取 tenant_access_token
 	token, err := mh.feishuClient.GetTenantAccessToken()
 	if err != nil {
 		mh.logger.Printf("Failed to get tenant access token: %v", err)
+		status.Finish(err)
 		return fmt.Errorf("failed to get tenant access token: %w", err)
 	}
 
 	// 验证 receive_id 不为空
 	if receiveID == "" {
 		mh.logger.Printf("ERROR: receiveID is empty! receiveIDType=%s", receiveIDType)
+		status.Set(reactionFailed)
 		return fmt.Errorf("cannot send card: missing valid receive ID")
 	}
 
-	// 读取绑定的项目路径
-	projectDir := ""
+	// 读取绑定的项目路径和会话设置；配置无法加载时不运行（否则会以内置默认值、无项目目录运行）
 	cfg, err := config.Load()
-	if err == nil {
-		projectDir = cfg.GetProjectPath(receiveID)
-		if projectDir != "" {
-			mh.logger.Printf("[DEBUG] Using bound project path: %s", projectDir)
-		}
+	if err != nil {
+		mh.logger.Printf("Failed to load chat config: %v", err)
+		status.Finish(err)
+		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 加载配置失败，本次消息未处理: "+err.Error())
 	}
+	binding, err := mh.getBinding(receiveID)
+	if err != nil {
+		mh.logger.Printf("Failed to read project binding: %v", err)
+		status.Finish(err)
+		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 读取项目绑定失败，本次消息未处理: "+err.Error())
+	}
+	var addDirs []string
+	projectDir := binding.ProjectPath
+	if projectDir != "" {
+		addDirs = mh.existingDirs(binding.AddDirs)
+		mh.logger.Printf("[DEBUG] Using bound project path: %s (add dirs: %v)", projectDir, addDirs)
+	}
+	settings, _ := cfg.GetSettings(receiveID)
 
 	// 创建 Claude 流式文本处理器（不使用 CardKit，节省 API 调用）
 	streamingTextHandler := claude.NewStreamingTextHandler(mh.feishuClient)
+	streamingTextHandler.SetRunID(runID)
+	streamingTextHandler.SetAddDirs(addDirs)
+	applySettings(streamingTextHandler, settings)
+	if replyMentionEnabled() {
+		streamingTextHandler.SetMentionUser(openID)
+	}
 
-	// 群聊使用固定的全局会话ID，实现所有群聊共享会话
-	resumeSessionID := mh.getClaudeSession(sessionID)
-	mh.logger.Printf("[DEBUG] Group chat using global session: %s (resume=%s)", sessionID, resumeSessionID)
+	// 按会话范围选择会话（默认所有群聊共享全局会话）
+	sessionID = groupSessionKey(settings.SessionScope, sessionID, receiveID, openID)
+	currentSessionID := mh.getClaudeSession(sessionID)
+	resumeSessionID := currentSessionID
+	if replySessionID != "" {
+		resumeSessionID = replySessionID
+	}
+	mh.logger.Printf("[DEBUG] Group chat using session: %s (resume=%s reply=%t)", sessionID, resumeSessionID, replySessionID != "")
 
 	// 处理消息（流式分段发送，同步 CLI 输出节奏）
+	status.Set(reactionRunning)
 	ctx := context.Background()
 	if err := streamingTextHandler.HandleMessage(ctx, token, receiveID, receiveIDType, content, resumeSessionID, projectDir); err != nil {
 		mh.logger.Printf("Failed to handle group streaming text chat: %v", err)
+		status.Finish(err)
 		return fmt.Errorf("failed to handle group streaming text chat: %w", err)
 	}
+	status.Set(reactionDone)
 
-	// 保存全局会话ID
-	if newSessionID := streamingTextHandler.SessionID(); newSessionID != "" {
+	// 保存全局会话ID（回复旧消息时不切换群的当前会话）
+	newSessionID := streamingTextHandler.SessionID()
+	if newSessionID != "" && (replySessionID == "" || replySessionID == currentSessionID) {
 		mh.setClaudeSession(sessionID, newSessionID)
 		mh.logger.Printf("[DEBUG] Group chat session saved: %s -> %s", sessionID, newSessionID)
 	}
+	mh.recordRun(runID, receiveID, openID, projectDir, newSessionID, streamingTextHandler.RunResult())
+	mh.sendTaskCompletedCard(receiveID, receiveIDType, projectDir, description, newSessionID, runID, streamingTextHandler.RunResult())
 
 	mh.logger.Printf("Group chat streaming text completed successfully for session %s", sessionID)
 	return nil
 }
 
+// replySession 消息回复的是机器人发出的消息时，返回产生该消息的 Claude 会话
+func (mh *MessageHandler) replySession(message *larkim.EventMessage) string {
+	if message == nil {
+		return ""
+	}
+	for _, id := range []*string{message.ParentId, message.RootId} {
+		if id == nil || *id == "" {
+			continue
+		}
+		link, ok, err := mh.store.LookupMessage(*id)
+		if err != nil {
+			mh.logger.Printf("Failed to look up message %s: %v", *id, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		run, ok, err := mh.store.GetRun(link.RunID)
+		if err != nil {
+			mh.logger.Printf("Failed to get run %s: %v", link.RunID, err)
+			continue
+		}
+		if ok && run.SessionID != "" {
+			mh.logger.Printf("[DEBUG] Reply to bot message %s: run_id=%s session_id=%s", *id, run.ID, run.SessionID)
+			return run.SessionID
+		}
+	}
+	return ""
+}
+
+// recordRun 保存运行记录（产生的会话，供之后回复该运行的消息时恢复）并累计当天用量
+func (mh *MessageHandler) recordRun(runID, chatID, openID, projectDir, sessionID string, result claude.RunResult) {
+	if runID == "" {
+		return
+	}
+	run := store.Run{
+		ID:         runID,
+		ChatID:     chatID,
+		OpenID:     openID,
+		SessionID:  sessionID,
+		ProjectDir: projectDir,
+		IsError:    result.IsError,
+		CostUSD:    result.CostUSD,
+		DurationMs: result.DurationMs,
+		NumTurns:   result.NumTurns,
+		UpdatedAt:  time.Now(),
+	}
+	if err := mh.store.SaveRun(run); err != nil {
+		mh.logger.Printf("Failed to save run %s: %v", runID, err)
+	}
+	if err := mh.store.AddUsage(store.UsageFromRun(run)); err != nil {
+		mh.logger.Printf("Failed to add usage for run %s: %v", runID, err)
+	}
+}
+
 func appendP2PTrace(event *larkim.P2MessageReceiveV1, tag string) {
 	eventID := ""
 	messageID := ""
@@ -276,18 +486,27 @@ func (mh *MessageHandler) getClaudeSession(openID string) string {
 	if openID == "" {
 		return ""
 	}
-	mh.claudeSessionMu.Lock()
-	defer mh.claudeSessionMu.Unlock()
-	return mh.claudeSessions[openID]
+	sessionID, err := mh.store.GetSession(openID)
+	if err != nil {
+		mh.logger.Printf("Failed to get session %s: %v", openID, err)
+	}
+	return sessionID
 }
 
 func (mh *MessageHandler) setClaudeSession(openID, sessionID string) {
 	if openID == "" || sessionID == "" {
 		return
 	}
-	mh.claudeSessionMu.Lock()
-	mh.claudeSessions[openID] = sessionID
-	mh.claudeSessionMu.Unlock()
+	if err := mh.store.SetSession(openID, sessionID); err != nil {
+		mh.logger.Printf("Failed to save session %s: %v", openID, err)
+	}
+}
+
+// clearClaudeSession 清除会话，下一条消息开始新的 Claude 会话
+func (mh *MessageHandler) clearClaudeSession(openID string) {
+	if err := mh.store.DeleteSession(openID); err != nil {
+		mh.logger.Printf("Failed to delete session %s: %v", openID, err)
+	}
 }
 
 func (mh *MessageHandler) shouldIgnoreMessage(event *larkim.P2MessageReceiveV1) bool {
@@ -309,8 +528,8 @@ func (mh *MessageHandler) shouldIgnoreMessage(event *larkim.P2MessageReceiveV1)
 
 	if event.Event.Message.MessageType != nil {
 		messageType := strings.ToLower(strings.TrimSpace(*event.Event.Message.MessageType))
-		if messageType != "" && messageType != "text" {
-			mh.logger.Printf("[DEBUG] Ignoring non-text message: message_type=%s", messageType)
+		if messageType != "" && !supportedMessageTypes[messageType] {
+			mh.logger.Printf("[DEBUG] Ignoring unsupported message: message_type=%s", messageType)
 			return true
 		}
 	}
@@ -325,106 +544,125 @@ func (mh *MessageHandler) shouldIgnoreMessage(event *larkim.P2MessageReceiveV1)
 	return false
 }
 
+// DedupWindow 消息去重窗口（飞书在未及时收到确认时会重推同一条消息）
+const DedupWindow = 30 * time.Minute
+
 func (mh *MessageHandler) isDuplicateMessage(messageID string) bool {
-	const dedupWindow = 30 * time.Minute
-	now := time.Now()
+	duplicate, err := mh.store.MarkSeen(messageID, time.Now(), DedupWindow)
+	if err != nil {
+		// 存储不可用时宁可重复处理，也不丢消息
+		mh.logger.Printf("Failed to record message %s for dedup: %v", messageID, err)
+		return false
+	}
+	if duplicate {
+		mh.logger.Printf("[DEBUG] Duplicate detected: message_id=%s", messageID)
+		return true
+	}
+	mh.logger.Printf("[DEBUG] Dedup record added: message_id=%s", messageID)
+	return false
+}
 
-	mh.recentMessageMu.Lock()
-	defer mh.recentMessageMu.Unlock()
+// processMessage 处理消息的通用逻辑（content 为发给 Claude 的完整提示，description 为用户的原始问题）
+func (mh *MessageHandler) processMessage(openID, userID, receiveID, receiveIDType, content, description, runID, replySessionID string, status *statusReaction) error {
+	mh.logger.Printf("[DEBUG] processMessage: open_id=%s user_id=%s receive_id=%s receive_id_type=%s len=%d", openID, userID, receiveID, receiveIDType, len(content))
+	return mh.handleStreamingChat(openID, userID, receiveID, receiveIDType, content, description, runID, replySessionID, status)
+}
 
-	if lastSeen, ok := mh.recentMessageIDs[messageID]; ok {
-		if now.Sub(lastSeen) < dedupWindow {
-			mh.logger.Printf("[DEBUG] Duplicate detected: message_id=%s last_seen=%s", messageID, lastSeen.Format(time.RFC3339))
+// isMentioned 检查是否@了机器人；未知机器人 open_id 时视为未 @（启动时已确保 open_id 已知）
+func (mh *MessageHandler) isMentioned(message *larkim.EventMessage) bool {
+	if message == nil || mh.botOpenID == "" {
+		return false
+	}
+	for _, m := range message.Mentions {
+		if m != nil && m.Id != nil && m.Id.OpenId != nil && *m.Id.OpenId == mh.botOpenID {
 			return true
 		}
 	}
+	return false
+}
 
-	mh.recentMessageIDs[messageID] = now
-	mh.logger.Printf("[DEBUG] Dedup record added: message_id=%s", messageID)
-
-	for id, ts := range mh.recentMessageIDs {
-		if now.Sub(ts) >= dedupWindow {
-			delete(mh.recentMessageIDs, id)
-		}
+// SetBotOpenID 设置机器人自己的 open_id（启动时通过机器人信息接口获取）
+func (mh *MessageHandler) SetBotOpenID(openID string) {
+	if openID != "" {
+		mh.botOpenID = openID
 	}
-
-	return false
 }
 
-// processMessage 处理消息的通用逻辑
-func (mh *MessageHandler) processMessage(openID, userID, receiveID, receiveIDType, content string) error {
-	mh.logger.Printf("[DEBUG] processMessage: open_id=%s user_id=%s receive_id=%s receive_id_type=%s len=%d", openID, userID, receiveID, receiveIDType, len(content))
-	return mh.handleStreamingChat(openID, userID, receiveID, receiveIDType, content)
+// BotOpenID 返回机器人自己的 open_id（未知时为空）
+func (mh *MessageHandler) BotOpenID() string {
+	return mh.botOpenID
 }
 
-// extractTextContent 提取文本内容
-func (mh *MessageHandler) extractTextContent(message interface{}) (string, error) {
-	if message == nil {
-		return "", fmt.Errorf("message is nil")
+// shouldRespondUnmentioned 按群聊响应策略判断未 @机器人 的消息是否需要处理
+func (mh *MessageHandler) shouldRespondUnmentioned(chatID string, message *larkim.EventMessage) bool {
+	policy := config.DefaultMentionPolicy()
+	if cfg, err := config.Load(); err == nil {
+		policy = cfg.GetMentionPolicy(chatID)
 	}
 
-	// 尝试从消息中提取Content字段
-	var messageMap map[string]interface{}
+	switch policy {
+	case config.MentionPolicyAll:
+		return true
+	case config.MentionPolicyThread:
+		return mh.isBotThread(message)
+	default:
+		return false
+	}
+}
 
-	// 尝试直接传递的map
-	if m, ok := message.(map[string]interface{}); ok {
-		messageMap = m
-	} else {
-		// 尝试JSON转换
-		messageBytes, err := json.Marshal(message)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal message: %w", err)
-		}
+// botThreadTTL thread 策略缓存的有效期
+const botThreadTTL = time.Hour
 
-		if err := json.Unmarshal(messageBytes, &messageMap); err != nil {
-			return "", fmt.Errorf("failed to unmarshal message: %w", err)
-		}
-	}
+// botThreadEntry thread 策略缓存项
+type botThreadEntry struct {
+	isBot     bool
+	expiresAt time.Time
+}
 
-	// 提取Content字段
-	content, exists := messageMap["Content"]
-	if !exists {
-		// 尝试content字段（小写）
-		content, exists = messageMap["content"]
-		if !exists {
-			return "", fmt.Errorf("no content field found in message")
+// isBotThread 判断消息是否回复了机器人的消息（或位于机器人消息开启的话题中）
+func (mh *MessageHandler) isBotThread(message *larkim.EventMessage) bool {
+	for _, id := range []*string{message.ParentId, message.RootId} {
+		if id == nil || *id == "" {
+			continue
+		}
+		if mh.isBotMessage(*id) {
+			return true
 		}
 	}
+	return false
+}
 
-	// 将content转换为字符串
-	contentStr, ok := content.(string)
-	if !ok {
-		return "", fmt.Errorf("content is not a string")
-	}
-	mh.logger.Printf("[DEBUG] Raw message content: len=%d content=%q", len(contentStr), contentStr)
-
-	// 解析JSON格式的文本内容
-	var textContent map[string]interface{}
-	if err := json.Unmarshal([]byte(contentStr), &textContent); err != nil {
-		// 如果不是JSON格式，直接返回原内容
-		return contentStr, nil
+// isBotMessage 判断消息是否由机器人发送：先查运行记录中的出站消息，再查缓存，最后调用接口
+func (mh *MessageHandler) isBotMessage(messageID string) bool {
+	if _, ok, err := mh.store.LookupMessage(messageID); err == nil && ok {
+		return true
 	}
 
-	// 提取text字段
-	text, exists := textContent["text"]
-	if !exists {
-		return "", fmt.Errorf("no text field found in content")
+	now := time.Now()
+	mh.botThreadMu.Lock()
+	entry, cached := mh.botThreads[messageID]
+	mh.botThreadMu.Unlock()
+	if cached && now.Before(entry.expiresAt) {
+		return entry.isBot
 	}
 
-	textStr, ok := text.(string)
-	if !ok {
-		return "", fmt.Errorf("text is not a string")
+	parent, err := mh.feishuClient.GetMessage(messageID)
+	if err != nil {
+		mh.logger.Printf("Failed to get parent message %s: %v", messageID, err)
+		return false
 	}
+	isBot := mh.feishuClient.IsBotMessage(parent)
 
-	return textStr, nil
-}
-
-// isMentioned 检查是否@了机器人
-func (mh *MessageHandler) isMentioned(message *larkim.EventMessage) bool {
-	if message == nil {
-		return false
+	mh.botThreadMu.Lock()
+	// 顺带清理过期项，避免缓存无限增长
+	for id, e := range mh.botThreads {
+		if now.After(e.expiresAt) {
+			delete(mh.botThreads, id)
+		}
 	}
-	return len(message.Mentions) > 0
+	mh.botThreads[messageID] = botThreadEntry{isBot: isBot, expiresAt: now.Add(botThreadTTL)}
+	mh.botThreadMu.Unlock()
+	return isBot
 }
 
 
@@ -434,7 +672,7 @@ func (mh *MessageHandler) sendTextMessage(receiveID, receiveIDType, text string)
 }
 
 // handleStreamingChat 处理流式对话请求
-func (mh *MessageHandler) handleStreamingChat(openID, userID, receiveID, receiveIDType, question string) error {
+func (mh *MessageHandler) handleStreamingChat(openID, userID, receiveID, receiveIDType, question, description, runID, replySessionID string, status *statusReaction) error {
 	mh.logger.Printf("[DEBUG] handleStreamingChat called with: openID=%s userID=%s receiveID=%s receiveIDType=%s question=%s", openID, userID, receiveID, receiveIDType, question)
 	_ = os.WriteFile(utils.GetTempFilePath("feishu-last-streaming.txt"), []byte(fmt.Sprintf("receive_id_type=%s receive_id=%s", receiveIDType, receiveID)), 0644)
 
@@ -442,224 +680,131 @@ func (mh *MessageHandler) handleStreamingChat(openID, userID, receiveID, receive
 	token, err := mh.feishuClient.GetTenantAccessToken()
 	if err != nil {
 		mh.logger.Printf("Failed to get tenant access token: %v", err)
+		status.Finish(err)
 		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 获取访问令牌失败")
 	}
 
 	// 验证 receive_id 不为空
 	if receiveID == "" {
 		mh.logger.Printf("ERROR: receiveID is empty! receiveIDType=%s", receiveIDType)
+		status.Set(reactionFailed)
 		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 无法发送卡片：缺少有效的会话ID")
 	}
 
+	// 读取绑定的项目路径和会话设置；配置无法加载时不运行（否则会以内置默认值、无项目目录运行）
+	cfg, err := config.Load()
+	if err != nil {
+		mh.logger.Printf("Failed to load chat config: %v", err)
+		status.Finish(err)
+		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 加载配置失败，本次消息未处理: "+err.Error())
+	}
+	binding, err := mh.getBinding(openID)
+	if err != nil {
+		mh.logger.Printf("Failed to read project binding: %v", err)
+		status.Finish(err)
+		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 读取项目绑定失败，本次消息未处理: "+err.Error())
+	}
+
 	// 创建 Claude 流式文本处理器（不使用 CardKit，节省 API 调用）
 	streamingTextHandler := claude.NewStreamingTextHandler(mh.feishuClient)
-	resumeSessionID := mh.getClaudeSession(openID)
+	streamingTextHandler.SetRunID(runID)
+	projectDir := binding.ProjectPath
+	if projectDir != "" {
+		addDirs := mh.existingDirs(binding.AddDirs)
+		streamingTextHandler.SetAddDirs(addDirs)
+		mh.logger.Printf("[DEBUG] Using bound project path: %s (add dirs: %v)", projectDir, addDirs)
+	}
+	settings, _ := cfg.GetSettings(openID)
+	applySettings(streamingTextHandler, settings)
+	sessionKey := p2pSessionKey(settings.SessionScope, openID)
+	currentSessionID := mh.getClaudeSession(sessionKey)
+	resumeSessionID := currentSessionID
+	if replySessionID != "" {
+		resumeSessionID = replySessionID
+	}
 
 	// 处理消息（流式分段发送，同步 CLI 输出节奏）
+	status.Set(reactionRunning)
 	ctx := context.Background()
-	if err := streamingTextHandler.HandleMessage(ctx, token, receiveID, receiveIDType, question, resumeSessionID, ""); err != nil {
+	if err := streamingTextHandler.HandleMessage(ctx, token, receiveID, receiveIDType, question, resumeSessionID, projectDir); err != nil {
 		mh.logger.Printf("Failed to handle streaming text chat: %v", err)
+		status.Finish(err)
 		return mh.sendTextMessage(receiveID, receiveIDType, "❌ 对话处理失败: "+err.Error())
 	}
-	if sessionID := streamingTextHandler.SessionID(); sessionID != "" {
-		mh.setClaudeSession(openID, sessionID)
+	status.Set(reactionDone)
+	// 回复旧消息时不切换用户的当前会话
+	sessionID := streamingTextHandler.SessionID()
+	if sessionID != "" && (replySessionID == "" || replySessionID == currentSessionID) {
+		mh.setClaudeSession(sessionKey, sessionID)
 	}
+	mh.recordRun(runID, openID, openID, projectDir, sessionID, streamingTextHandler.RunResult())
+	mh.sendTaskCompletedCard(receiveID, receiveIDType, projectDir, description, sessionID, runID, streamingTextHandler.RunResult())
 
 	mh.logger.Printf("Streaming text chat completed successfully for user %s", userID)
 	return nil
 }
 
-// parseCommand 解析用户消息是否为特殊命令
-// 返回：命令类型、参数、是否为命令
-func parseCommand(content string) (cmdType string, args string, isCmd bool) {
-	content = strings.TrimSpace(content)
-	if content == "" {
-		return "", "", false
-	}
-
-	// 提取第一个单词作为命令
-	parts := strings.Fields(content)
-	if len(parts) == 0 {
-		return "", "", false
-	}
-
-	command := strings.ToLower(parts[0])
-	switch command {
-	case "ls", "bind", "help":
-		args = strings.Join(parts[1:], " ")
-		return command, args, true
-	default:
-		return "", "", false
-	}
-}
-
-// handleLsCommand 处理 ls 命令 - 列出基础目录下的所有项目
-func (mh *MessageHandler) handleLsCommand(chatID string) error {
-	baseDir := getBaseDir()
-
-	// 列出目录内容
-	entries, err := os.ReadDir(baseDir)
-	if err != nil {
-		return mh.sendTextMessage(chatID, "chat_id",
-			fmt.Sprintf("❌ 无法读取目录: %v", err))
-	}
-
-	// 过滤目录并编号
-	var dirs []string
-	for _, entry := range entries {
-		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
-			dirs = append(dirs, entry.Name())
-		}
-	}
-
-	// 读取当前绑定的项目路径
-	currentBinding := ""
+// handleModeCommand 处理 mode 命令 - 查看或设置群聊响应策略
+func (mh *MessageHandler) handleModeCommand(cc *commandContext) error {
 	cfg, err := config.Load()
-	if err == nil {
-		if path := cfg.GetProjectPath(chatID); path != "" {
-			currentBinding = path
-		}
-	}
-
-	// 构建回复消息
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("📂 基础目录: %s\n\n", baseDir))
-	builder.WriteString("可绑定项目目录：\n")
-	for i, dir := range dirs {
-		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, dir))
-	}
-	builder.WriteString(fmt.Sprintf("\n共 %d 个目录\n", len(dirs)))
-	builder.WriteString("使用命令: bind <序号>")
-
-	// 显示当前绑定
-	if currentBinding != "" {
-		builder.WriteString(fmt.Sprintf("\n\n✅ 当前绑定: %s", currentBinding))
-	}
-
-	return mh.sendTextMessage(chatID, "chat_id", builder.String())
-}
-
-// handleBindCommand 处理 bind 命令 - 绑定群聊到指定项目路径
-func (mh *MessageHandler) handleBindCommand(chatID, args string) error {
-	// 解析序号
-	args = strings.TrimSpace(args)
-	if args == "" {
-		return mh.sendTextMessage(chatID, "chat_id",
-			"❌ 请提供项目序号\n使用命令: bind <序号>")
-	}
-
-	// 尝试解析为数字
-	index, err := strconv.Atoi(args)
-	if err != nil || index < 1 {
-		return mh.sendTextMessage(chatID, "chat_id",
-			"❌ 无效的序号，请输入数字")
-	}
-
-	// 读取项目列表
-	baseDir := getBaseDir()
-	entries, err := os.ReadDir(baseDir)
 	if err != nil {
-		return mh.sendTextMessage(chatID, "chat_id",
-			fmt.Sprintf("❌ 无法读取目录: %v", err))
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 加载配置失败: %v", err))
 	}
 
-	// 过滤并查找指定目录
-	var dirs []string
-	for _, entry := range entries {
-		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
-			dirs = append(dirs, entry.Name())
-		}
+	policy := strings.ToLower(strings.TrimSpace(cc.args))
+	if policy == "" {
+		return cc.reply(mh,
+			fmt.Sprintf("📋 当前响应策略: %s\n使用命令: /mode <mention|all|thread>", cfg.GetMentionPolicy(cc.chatID)))
 	}
 
-	if index > len(dirs) {
-		return mh.sendTextMessage(chatID, "chat_id",
-			fmt.Sprintf("❌ 序号超出范围，最大序号: %d", len(dirs)))
+	err = config.Update(func(cfg *config.ChatConfig) error {
+		return cfg.SetMentionPolicy(cc.chatID, policy)
+	})
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
 	}
 
-	// 绑定路径
-	selectedDir := dirs[index-1]
-	projectPath := baseDir + selectedDir
+	return cc.reply(mh,
+		fmt.Sprintf("✅ 响应策略已设置为: %s\n（配置已保存）", policy))
+}
 
-	// 保存到配置文件
+// handleContextCommand 处理 context 命令 - 查看或设置附带的最近消息条数
+func (mh *MessageHandler) handleContextCommand(cc *commandContext) error {
 	cfg, err := config.Load()
 	if err != nil {
-		return mh.sendTextMessage(chatID, "chat_id",
+		return cc.reply(mh,
 			fmt.Sprintf("❌ 加载配置失败: %v", err))
 	}
-	if err := cfg.SetProjectPath(chatID, projectPath); err != nil {
-		return mh.sendTextMessage(chatID, "chat_id",
-			fmt.Sprintf("❌ 保存配置失败: %v", err))
-	}
-	if err := cfg.Save(); err != nil {
-		return mh.sendTextMessage(chatID, "chat_id",
-			fmt.Sprintf("❌ 保存配置文件失败: %v", err))
-	}
 
-	return mh.sendTextMessage(chatID, "chat_id",
-		fmt.Sprintf("✅ 已绑定项目路径: %s\n（配置已保存）", projectPath))
-}
-
-// handleHelpCommand 处理 help 命令 - 显示帮助信息
-func (mh *MessageHandler) handleHelpCommand(chatID string) error {
-	// 读取当前绑定的项目路径
-	currentBinding := ""
-	cfg, err := config.Load()
-	if err == nil {
-		if path := cfg.GetProjectPath(chatID); path != "" {
-			currentBinding = path
+	args := strings.ToLower(strings.TrimSpace(cc.args))
+	if args == "" {
+		if size := cfg.GetContextSize(cc.chatID); size > 0 {
+			return cc.reply(mh,
+				fmt.Sprintf("📋 提问时附带最近 %d 条消息\n使用命令: /context <条数|off>", size))
 		}
+		return cc.reply(mh,
+			"📋 未开启聊天记录上下文\n使用命令: /context <条数|off>")
 	}
 
-	// 构建帮助信息
-	var builder strings.Builder
-	builder.WriteString(`🤖 飞书 Claude CLI 机器人命令说明
-
-特殊命令：
-• ls - 列出可绑定的项目目录
-• bind <序号> - 绑定群聊到指定项目路径
-• help - 显示此帮助信息
-
-使用示例：
-@机器人 ls
-@机器人 bind 18
-@机器人 help
-
-注意：
-- 特殊命令仅在群聊中有效
-- 绑定后配置会持久化保存
-- 其他消息将转发给 Claude 处理`)
-
-	// 显示当前绑定
-	if currentBinding != "" {
-		builder.WriteString(fmt.Sprintf("\n\n✅ 当前绑定: %s", currentBinding))
-	} else {
-		builder.WriteString("\n\n⚠️ 当前未绑定项目路径")
-	}
-
-	return mh.sendTextMessage(chatID, "chat_id", builder.String())
-}
-
-// getBaseDir 获取基础目录配置
-func getBaseDir() string {
-	// 优先从环境变量读取
-	if dir := os.Getenv("BASE_DIR"); dir != "" {
-		// 确保路径末尾有斜杠
-		if !strings.HasSuffix(dir, "/") {
-			dir = dir + "/"
+	size := 0
+	if args != "off" {
+		size, err = strconv.Atoi(args)
+		if err != nil {
+			return cc.reply(mh,
+				"❌ 无效的条数，请输入数字或 off")
 		}
-		return dir
 	}
-
-	// 从配置文件读取
-	cfg, err := config.Load()
-	if err == nil && cfg.BaseDir != "" {
-		baseDir := cfg.BaseDir
-		if !strings.HasSuffix(baseDir, "/") {
-			baseDir = baseDir + "/"
-		}
-		return baseDir
+	err = config.Update(func(cfg *config.ChatConfig) error {
+		return cfg.SetContextSize(cc.chatID, size)
+	})
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
 	}
 
-	// 默认值
-	return "/Users/wen/Desktop/code/"
+	if size == 0 {
+		return cc.reply(mh, "✅ 已关闭聊天记录上下文\n（配置已保存）")
+	}
+	return cc.reply(mh,
+		fmt.Sprintf("✅ 提问时将附带最近 %d 条消息\n（配置已保存）", size))
 }
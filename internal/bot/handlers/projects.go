@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"feishu-bot/internal/config"
+	"feishu-bot/internal/project"
+)
+
+// rootProjects 一个根目录及其下发现的项目
+type rootProjects struct {
+	root     config.Root
+	projects []project.Project
+	err      error // 根目录无法读取时的错误
+}
+
+// projectCatalog 全部根目录下的可绑定项目与项目别名
+type projectCatalog struct {
+	groups  []rootProjects
+	aliases map[string]string
+}
+
+// loadProjectCatalog 扫描所有根目录，构建项目目录
+func loadProjectCatalog(cfg *config.ChatConfig) (*projectCatalog, error) {
+	roots := cfg.GetRoots()
+	aliases := cfg.GetProjectAliases()
+	if len(roots) == 0 && len(aliases) == 0 {
+		return nil, fmt.Errorf("未配置项目根目录，请在 configs/chat_config.json 的 roots 或环境变量 PROJECT_ROOTS 中配置")
+	}
+
+	catalog := &projectCatalog{aliases: aliases}
+	depth := project.ScanDepth()
+	for _, root := range roots {
+		projects, err := project.Discover(root.Path, depth)
+		catalog.groups = append(catalog.groups, rootProjects{root: root, projects: projects, err: err})
+	}
+	return catalog, nil
+}
+
+// all 按 ls 中的顺序返回全部项目（序号从 1 开始依次对应）
+func (c *projectCatalog) all() []project.Project {
+	var projects []project.Project
+	for _, g := range c.groups {
+		projects = append(projects, g.projects...)
+	}
+	return projects
+}
+
+// qualifiedName 多个根目录时带上根目录名，如 work/api-server
+func (c *projectCatalog) qualifiedName(rootName, name string) string {
+	if len(c.groups) <= 1 {
+		return name
+	}
+	return rootName + "/" + name
+}
+
+// aliasNames 按名称排序的别名
+func (c *projectCatalog) aliasNames() []string {
+	names := make([]string, 0, len(c.aliases))
+	for alias := range c.aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve 解析 bind 参数为项目路径
+// 依次尝试：别名、ls 列表序号、相对路径（可带根目录名前缀）、项目名称匹配
+func (c *projectCatalog) resolve(target string) (string, error) {
+	if path, ok := c.aliases[target]; ok {
+		return path, nil
+	}
+
+	projects := c.all()
+	if index, err := strconv.Atoi(target); err == nil {
+		if index < 1 || index > len(projects) {
+			return "", fmt.Errorf("序号超出范围，最大序号: %d", len(projects))
+		}
+		return projects[index-1].Path, nil
+	}
+
+	if path, tried, err := c.resolvePath(target); tried {
+		return path, err
+	}
+
+	// 名称匹配时同时比较根目录内的名称和带根目录名的名称
+	candidates := make([]project.Project, 0, len(projects))
+	for _, g := range c.groups {
+		for _, p := range g.projects {
+			candidates = append(candidates, project.Project{Name: c.qualifiedName(g.root.Name, p.Name), Path: p.Path})
+		}
+	}
+	matches := project.Match(candidates, target)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("没有找到匹配 %q 的项目，发送 /ls 查看可绑定项目", target)
+	case 1:
+		return matches[0].Path, nil
+	}
+
+	const maxCandidates = 10
+	var names []string
+	for i, m := range matches {
+		if i == maxCandidates {
+			names = append(names, fmt.Sprintf("…（共 %d 个）", len(matches)))
+			break
+		}
+		names = append(names, "• "+m.Name)
+	}
+	return "", fmt.Errorf("找到多个匹配 %q 的项目，请使用更完整的名称或路径：\n%s", target, strings.Join(names, "\n"))
+}
+
+// resolvePath 按路径解析：绝对路径需位于某个根目录内；相对路径可以 "<根目录名>/" 开头，
+// 否则依次在各根目录下查找。tried 为 false 表示 target 不像路径，且不能唯一确定目录
+func (c *projectCatalog) resolvePath(target string) (path string, tried bool, err error) {
+	explicit := filepath.IsAbs(target) || strings.ContainsAny(target, `/\`) || strings.HasPrefix(target, ".")
+
+	if filepath.IsAbs(target) {
+		for _, g := range c.groups {
+			if path, err := project.Resolve(g.root.Path, target); err == nil {
+				return path, true, nil
+			}
+		}
+		return "", true, fmt.Errorf("路径不在任何项目根目录内: %s", target)
+	}
+
+	rootName, rest, _ := strings.Cut(filepath.ToSlash(target), "/")
+	for _, g := range c.groups {
+		if g.root.Name == rootName && rest != "" {
+			path, err := project.Resolve(g.root.Path, rest)
+			return path, true, err
+		}
+	}
+
+	// 在多个根目录下都存在时交给名称匹配，提示用户选择
+	var found []string
+	var firstErr error
+	for _, g := range c.groups {
+		path, err := project.Resolve(g.root.Path, target)
+		if err == nil {
+			found = append(found, path)
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	switch {
+	case len(found) == 1 || (explicit && len(found) > 0):
+		return found[0], true, nil
+	case explicit && firstErr != nil:
+		return "", true, firstErr
+	}
+	return "", false, nil
+}
+
+// handleAliasCommand 处理 alias 命令 - 查看、设置或删除项目别名
+func (mh *MessageHandler) handleAliasCommand(cc *commandContext) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 加载配置失败: %v", err))
+	}
+
+	fields := strings.Fields(cc.args)
+	if len(fields) == 0 {
+		aliases := cfg.GetProjectAliases()
+		if len(aliases) == 0 {
+			return cc.reply(mh, "📋 暂无项目别名\n使用命令: /alias <别名> <序号|名称|相对路径>")
+		}
+		catalog := &projectCatalog{aliases: aliases}
+		var builder strings.Builder
+		builder.WriteString("📋 项目别名：\n")
+		for _, alias := range catalog.aliasNames() {
+			builder.WriteString(fmt.Sprintf("• %s → %s\n", alias, aliases[alias]))
+		}
+		builder.WriteString("\n使用命令: /bind <别名>")
+		return cc.reply(mh, builder.String())
+	}
+	if len(fields) != 2 {
+		return cc.reply(mh, "❌ 用法: /alias <别名> <序号|名称|相对路径>，或 /alias <别名> off 删除")
+	}
+
+	alias, target := fields[0], fields[1]
+	if _, err := strconv.Atoi(alias); err == nil {
+		return cc.reply(mh, "❌ 别名不能是纯数字（会与 ls 序号冲突）")
+	}
+
+	if strings.EqualFold(target, "off") {
+		deleted := false
+		err := config.Update(func(cfg *config.ChatConfig) error {
+			deleted = cfg.DeleteProjectAlias(alias)
+			return nil
+		})
+		if err != nil {
+			return cc.reply(mh,
+				fmt.Sprintf("❌ 保存配置失败: %v", err))
+		}
+		if !deleted {
+			return cc.reply(mh, fmt.Sprintf("❌ 别名不存在: %s", alias))
+		}
+		return cc.reply(mh, fmt.Sprintf("✅ 已删除别名: %s\n（配置已保存）", alias))
+	}
+
+	catalog, err := loadProjectCatalog(cfg)
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
+	}
+	// 别名只能指向根目录内的项目，避免绕过路径校验
+	delete(catalog.aliases, target)
+	projectPath, err := catalog.resolve(target)
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
+	}
+	err = config.Update(func(cfg *config.ChatConfig) error {
+		return cfg.SetProjectAlias(alias, projectPath)
+	})
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 保存配置失败: %v", err))
+	}
+	return cc.reply(mh, fmt.Sprintf("✅ 已设置别名: %s → %s\n（配置已保存）", alias, projectPath))
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bot/internal/utils"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// defaultQuotedMaxRunes 引用上下文的默认最大字符数
+const defaultQuotedMaxRunes = 8000
+
+// withQuotedContext 消息是回复时，获取被回复的消息并作为上下文拼接在问题前
+// 获取失败时只记录日志，原样返回问题
+func (mh *MessageHandler) withQuotedContext(message *larkim.EventMessage, question string) string {
+	if message == nil || !quotedContextEnabled() {
+		return question
+	}
+
+	quotedID := ""
+	if message.ParentId != nil && *message.ParentId != "" {
+		quotedID = *message.ParentId
+	} else if message.RootId != nil && *message.RootId != "" {
+		quotedID = *message.RootId
+	}
+	if quotedID == "" {
+		return question
+	}
+
+	quoted, err := mh.feishuClient.GetMessage(quotedID)
+	if err != nil {
+		mh.logger.Printf("Failed to get quoted message %s: %v", quotedID, err)
+		return question
+	}
+	if quoted.Deleted != nil && *quoted.Deleted {
+		return question
+	}
+
+	text := strings.TrimSpace(mh.renderStoredMessage(quoted))
+	if text == "" {
+		return question
+	}
+	text = truncateRunes(text, quotedMaxRunes())
+	mh.logger.Printf("[DEBUG] Quoted context attached: message_id=%s len=%d", quotedID, len(text))
+
+	sender := "用户"
+	if mh.feishuClient.IsBotMessage(quoted) {
+		sender = "机器人"
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("<quoted_message sender=%q", sender))
+	if created := formatMessageTime(quoted.CreateTime); created != "" {
+		builder.WriteString(fmt.Sprintf(" time=%q", created))
+	}
+	builder.WriteString(">\n")
+	builder.WriteString(text)
+	builder.WriteString("\n</quoted_message>\n\n")
+	builder.WriteString(question)
+	return builder.String()
+}
+
+// renderStoredMessage 将通过接口获取的消息渲染为文本（图片、文件等只保留引用）
+func (mh *MessageHandler) renderStoredMessage(message *larkim.Message) string {
+	if message == nil || message.Body == nil || message.Body.Content == nil {
+		return ""
+	}
+	msgType := ""
+	if message.MsgType != nil {
+		msgType = *message.MsgType
+	}
+	content := *message.Body.Content
+
+	switch msgType {
+	case "text", "post":
+		text, err := mh.renderMessageContent(msgType, content, storedMentions(message.Mentions))
+		if err != nil {
+			mh.logger.Printf("Failed to render %s message: %v", msgType, err)
+			return ""
+		}
+		return text
+	case "image":
+		var image struct {
+			ImageKey string `json:"image_key"`
+		}
+		_ = json.Unmarshal([]byte(content), &image)
+		return fmt.Sprintf("[图片 image_key=%s]", image.ImageKey)
+	case "file":
+		var file struct {
+			FileName string `json:"file_name"`
+		}
+		_ = json.Unmarshal([]byte(content), &file)
+		return fmt.Sprintf("[文件 %s]", file.FileName)
+	case "merge_forward":
+		if message.MessageId == nil {
+			return "[合并转发]"
+		}
+		transcript, err := mh.expandMergeForward(*message.MessageId)
+		if err != nil {
+			mh.logger.Printf("Failed to expand merge_forward %s: %v", *message.MessageId, err)
+			return "[合并转发]"
+		}
+		return transcript
+	case "interactive":
+		return "[卡片消息]"
+	default:
+		return fmt.Sprintf("[%s 消息]", msgType)
+	}
+}
+
+// storedMentions 将接口返回的 mentions 转为 占位符 -> 用户
+func storedMentions(mentions []*larkim.Mention) map[string]mentionInfo {
+	result := make(map[string]mentionInfo, len(mentions))
+	for _, m := range mentions {
+		if m == nil || m.Key == nil {
+			continue
+		}
+		info := mentionInfo{}
+		if m.Name != nil {
+			info.Name = *m.Name
+		}
+		if m.Id != nil && (m.IdType == nil || *m.IdType == "open_id") {
+			info.OpenID = *m.Id
+		}
+		result[*m.Key] = info
+	}
+	return result
+}
+
+// formatMessageTime 格式化毫秒时间戳
+func formatMessageTime(ms *string) string {
+	if ms == nil {
+		return ""
+	}
+	value, err := strconv.ParseInt(*ms, 10, 64)
+	if err != nil || value <= 0 {
+		return ""
+	}
+	return time.UnixMilli(value).Format("2006-01-02 15:04:05")
+}
+
+// quotedContextEnabled 是否附带被回复消息（QUOTED_CONTEXT=false 关闭）
+func quotedContextEnabled() bool {
+	return utils.GetEnvBool("QUOTED_CONTEXT", true)
+}
+
+// quotedMaxRunes 引用上下文最大字符数（QUOTED_CONTEXT_MAX_RUNES）
+func quotedMaxRunes() int {
+	if value, err := strconv.Atoi(os.Getenv("QUOTED_CONTEXT_MAX_RUNES")); err == nil && value > 0 {
+		return value
+	}
+	return defaultQuotedMaxRunes
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"html"
+	"os"
+	"strings"
+
+	"feishu-bot/internal/utils"
+)
+
+// defaultSenderTag 群聊问题前的默认发送者标记（{name} 显示名，{open_id} 用户 open_id）
+const defaultSenderTag = `<sender name="{name}" />`
+
+// withSenderTag 群聊共享会话中，在问题前标明提问者，让 Claude 区分不同用户
+func (mh *MessageHandler) withSenderTag(openID, question string) string {
+	tag := senderTag()
+	if tag == "" || openID == "" {
+		return question
+	}
+	return formatSenderTag(tag, mh.userName(openID), openID) + "\n" + question
+}
+
+// formatSenderTag 填充发送者标记模板；值按 XML 转义并去掉换行，显示名无法伪造标记或属性
+func formatSenderTag(tag, name, openID string) string {
+	return strings.NewReplacer("{name}", escapeTagValue(name), "{open_id}", escapeTagValue(openID)).Replace(tag)
+}
+
+// escapeTagValue 转义标记属性值中的 < > & ' "，并把换行替换为空格
+func escapeTagValue(value string) string {
+	value = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+	return html.EscapeString(value)
+}
+
+// senderTag 发送者标记模板（GROUP_SENDER_TAG，设为 off 关闭）
+func senderTag() string {
+	value := strings.TrimSpace(os.Getenv("GROUP_SENDER_TAG"))
+	switch strings.ToLower(value) {
+	case "":
+		return defaultSenderTag
+	case "false", "0", "off":
+		return ""
+	}
+	return value
+}
+
+// replyMentionEnabled 群聊回复是否 @提问者（GROUP_REPLY_MENTION=true 开启）
+func replyMentionEnabled() bool {
+	return utils.GetEnvBool("GROUP_REPLY_MENTION", false)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"feishu-bot/internal/bot/client"
+	"feishu-bot/internal/store"
+)
+
+// SentRecorder 记录机器人发出的消息属于哪次运行（回复机器人消息时据此恢复会话），实现 client.SentSink
+type SentRecorder struct {
+	runs store.Runs
+}
+
+// NewSentRecorder 创建记录器
+func NewSentRecorder(runs store.Runs) *SentRecorder {
+	return &SentRecorder{runs: runs}
+}
+
+// Sent 记录发送成功的消息所属的运行（失败只记录日志）
+func (r *SentRecorder) Sent(msg *client.OutboundMessage) {
+	if msg.MessageID == "" || msg.RunID == "" {
+		return
+	}
+	if err := r.runs.LinkMessage(msg.MessageID, store.MessageLink{RunID: msg.RunID, CreatedAt: time.Now()}); err != nil {
+		log.Printf("[Store] Failed to link message %s to run %s: %v", msg.MessageID, msg.RunID, err)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"feishu-bot/internal/bot/client"
+	"feishu-bot/internal/claude"
+	"feishu-bot/internal/config"
+)
+
+// 修改设置在访问控制中的命令名（可在 command_permissions 中覆盖，默认仅管理员）
+const commandSettingsWrite = "settings.set"
+
+// languagePrompts 回复语言对应的系统提示
+var languagePrompts = map[string]string{
+	config.LanguageZh: "请始终使用简体中文回答。",
+	config.LanguageEn: "Always respond in English.",
+	config.LanguageJa: "常に日本語で回答してください。",
+}
+
+// permissionModes 权限配置对应的 --permission-mode（bypass 为空，使用 --dangerously-skip-permissions）
+var permissionModes = map[string]string{
+	config.PermissionProfileAcceptEdits: "acceptEdits",
+	config.PermissionProfilePlan:        "plan",
+}
+
+// settingSourceNames 设置来源的显示名称
+var settingSourceNames = map[string]string{
+	config.SettingSourceChat:    "本会话",
+	config.SettingSourceGlobal:  "全局",
+	config.SettingSourceBuiltin: "默认",
+}
+
+// applySettings 将会话设置应用到本次运行
+func applySettings(h *claude.StreamingTextHandler, settings config.ChatSettings) {
+	model := settings.Model
+	if model == config.ModelDefault {
+		model = ""
+	}
+	h.SetClaudeOptions(model, permissionModes[settings.PermissionProfile], languagePrompts[settings.Language])
+
+	switch settings.ResponseMode {
+	case config.ResponseModePost:
+		h.SetResponseFormat(client.FormatPost)
+	case config.ResponseModeCard:
+		h.SetResponseFormat(client.FormatCard)
+	default:
+		h.SetResponseFormat(client.FormatText)
+	}
+	if d := settings.IdleTimeout(); d > 0 {
+		h.SetIdleTimeout(d)
+	}
+	if d := settings.MaxDuration(); d > 0 {
+		h.SetMaxDuration(d)
+	}
+}
+
+// groupSessionKey 群聊按会话范围选择会话键；返回空表示不延续会话
+func groupSessionKey(scope, sharedKey, chatID, openID string) string {
+	switch scope {
+	case config.SessionScopeChat:
+		return chatID
+	case config.SessionScopeUser:
+		return chatID + ":" + openID
+	case config.SessionScopeNone:
+		return ""
+	default:
+		return sharedKey
+	}
+}
+
+// p2pSessionKey 单聊按会话范围选择会话键；返回空表示不延续会话
+func p2pSessionKey(scope, openID string) string {
+	if scope == config.SessionScopeNone {
+		return ""
+	}
+	return openID
+}
+
+// handleSettingsCommand 处理 settings 命令 - 查看、修改或重置当前会话的设置
+func (mh *MessageHandler) handleSettingsCommand(cc *commandContext) error {
+	fields := strings.Fields(cc.args)
+	sub := "get"
+	if len(fields) > 0 {
+		sub = strings.ToLower(fields[0])
+		fields = fields[1:]
+	}
+
+	switch sub {
+	case "get":
+		if len(fields) > 1 {
+			break
+		}
+		name := ""
+		if len(fields) == 1 {
+			name = fields[0]
+		}
+		return mh.showSettings(cc, name)
+	case "set":
+		if len(fields) != 2 {
+			break
+		}
+		return mh.changeSetting(cc, fields[0], fields[1])
+	case "reset":
+		if len(fields) > 1 {
+			break
+		}
+		name := ""
+		if len(fields) == 1 {
+			name = fields[0]
+		}
+		return mh.changeSetting(cc, name, "")
+	}
+	return cc.reply(mh, "❌ 用法: /settings [get [设置项]] | set <设置项> <值> | reset [设置项]")
+}
+
+// showSettings 显示生效的设置及来源（name 为空时显示全部）
+func (mh *MessageHandler) showSettings(cc *commandContext, name string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return cc.reply(mh,
+			fmt.Sprintf("❌ 加载配置失败: %v", err))
+	}
+	if name != "" {
+		if _, ok := config.LookupSetting(name); !ok {
+			return cc.reply(mh, fmt.Sprintf("❌ 未知的设置项: %s", name))
+		}
+	}
+
+	settings, sources := cfg.GetSettings(cc.bindingKey())
+	var builder strings.Builder
+	builder.WriteString("📋 当前会话设置：\n")
+	for _, spec := range config.SettingSpecs() {
+		if (name != "" && spec.Key != name) || (spec.GroupOnly && !cc.group) {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("• %s = %s（%s）\n", spec.Key, spec.Get(settings), settingSourceNames[sources[spec.Key]]))
+		if name != "" {
+			builder.WriteString("  " + spec.Description + "\n")
+			if len(spec.Values) > 0 {
+				builder.WriteString("  可选值: " + strings.Join(spec.Values, " / ") + "\n")
+			}
+		}
+	}
+	builder.WriteString("\n使用命令: /settings set <设置项> <值>，/settings reset [设置项]")
+	return cc.reply(mh, builder.String())
+}
+
+// changeSetting 修改（value 非空）或重置（value 为空，name 为空时重置全部）当前会话的设置
+func (mh *MessageHandler) changeSetting(cc *commandContext, name, value string) error {
+	if err := cc.access.CheckCommand(commandSettingsWrite, config.PermissionAdmin, cc.subject); err != nil {
+		mh.auditDenied(cc.event, cc.subject, commandSettingsWrite, err)
+		return cc.reply(mh, "⛔ "+err.Error())
+	}
+	if name != "" {
+		spec, ok := config.LookupSetting(name)
+		if !ok {
+			return cc.reply(mh, fmt.Sprintf("❌ 未知的设置项: %s", name))
+		}
+		if spec.GroupOnly && !cc.group {
+			return cc.reply(mh, fmt.Sprintf("❌ 设置项 %s 仅在群聊中可用", name))
+		}
+	}
+
+	var settings config.ChatSettings
+	err := config.Update(func(cfg *config.ChatConfig) error {
+		var err error
+		if value == "" {
+			err = cfg.ResetSetting(cc.bindingKey(), name)
+		} else {
+			err = cfg.SetSetting(cc.bindingKey(), name, value)
+		}
+		settings, _ = cfg.GetSettings(cc.bindingKey())
+		return err
+	})
+	if err != nil {
+		return cc.reply(mh, "❌ "+err.Error())
+	}
+
+	switch {
+	case value != "":
+		return cc.reply(mh, fmt.Sprintf("✅ %s 已设置为: %s\n（配置已保存）", name, value))
+	case name != "":
+		spec, _ := config.LookupSetting(name)
+		return cc.reply(mh, fmt.Sprintf("✅ %s 已重置，当前值: %s\n（配置已保存）", name, spec.Get(settings)))
+	}
+	return cc.reply(mh, "✅ 已重置全部设置\n（配置已保存）")
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+
+	"feishu-bot/internal/bot/client"
+	"feishu-bot/internal/utils"
+)
+
+// 状态表情（飞书 emoji_type，参见 message-reaction/emojis-introduce）
+const (
+	reactionReceived = "Get"       // 已收到，排队中
+	reactionRunning  = "OnIt"      // Claude 处理中
+	reactionDone     = "DONE"      // 处理成功
+	reactionFailed   = "CrossMark" // 处理失败
+)
+
+// statusReaction 用表情回复在用户消息上展示处理状态
+// 每次切换状态时先添加新表情，再删除旧表情，保证消息上始终只有一个状态表情
+type statusReaction struct {
+	feishuClient *client.FeishuClient
+	messageID    string
+	logger       *log.Logger
+
+	mu         sync.Mutex
+	current    string // 当前表情类型
+	reactionID string // 当前表情的 reaction_id
+}
+
+// newStatusReaction 创建状态表情跟踪器；messageID 为空或功能关闭时返回 nil（nil 上的调用均为空操作）
+func (mh *MessageHandler) newStatusReaction(messageID string) *statusReaction {
+	if messageID == "" || !statusReactionsEnabled() {
+		return nil
+	}
+	return &statusReaction{
+		feishuClient: mh.feishuClient,
+		messageID:    messageID,
+		logger:       mh.logger,
+	}
+}
+
+// statusReactionsEnabled 是否启用状态表情（STATUS_REACTIONS=false 关闭）
+func statusReactionsEnabled() bool {
+	return utils.GetEnvBool("STATUS_REACTIONS", true)
+}
+
+// Set 切换到指定状态表情（失败只记录日志，不影响主流程）
+func (s *statusReaction) Set(emojiType string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == emojiType {
+		return
+	}
+
+	reactionID, err := s.feishuClient.AddReaction(s.messageID, emojiType)
+	if err != nil {
+		s.logger.Printf("Failed to add status reaction: message_id=%s emoji=%s err=%v", s.messageID, emojiType, err)
+		return
+	}
+
+	if s.reactionID != "" {
+		if err := s.feishuClient.DeleteReaction(s.messageID, s.reactionID); err != nil {
+			s.logger.Printf("Failed to delete status reaction: message_id=%s reaction_id=%s err=%v", s.messageID, s.reactionID, err)
+		}
+	}
+
+	s.current = emojiType
+	s.reactionID = reactionID
+}
+
+// Finish 根据处理结果设置最终状态
+func (s *statusReaction) Finish(err error) {
+	if err != nil {
+		s.Set(reactionFailed)
+		return
+	}
+	s.Set(reactionDone)
+}
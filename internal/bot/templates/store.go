@@ -0,0 +1,89 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TaskCompleted 运行结束后发送的卡片模板（对应 configs/cards/task_completed.json）
+const TaskCompleted = "task_completed"
+
+// DefaultDir 默认模板目录
+const DefaultDir = "configs/cards"
+
+// Store 卡片模板仓库（按需加载并缓存）
+type Store struct {
+	dir       string
+	mu        sync.RWMutex
+	templates map[string]*Template
+}
+
+// NewStore 创建模板仓库
+func NewStore(dir string) *Store {
+	return &Store{
+		dir:       dir,
+		templates: make(map[string]*Template),
+	}
+}
+
+var (
+	defaultStore     *Store
+	defaultStoreOnce sync.Once
+)
+
+// Default 返回全局模板仓库（目录可通过 CARD_TEMPLATE_DIR 覆盖）
+func Default() *Store {
+	defaultStoreOnce.Do(func() {
+		dir := os.Getenv("CARD_TEMPLATE_DIR")
+		if dir == "" {
+			dir = DefaultDir
+		}
+		defaultStore = NewStore(dir)
+	})
+	return defaultStore
+}
+
+// Get 获取模板（首次使用时从文件加载）
+func (s *Store) Get(name string) (*Template, error) {
+	s.mu.RLock()
+	tpl, ok := s.templates[name]
+	s.mu.RUnlock()
+	if ok {
+		return tpl, nil
+	}
+
+	path := filepath.Join(s.dir, name+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取卡片模板失败: %w", err)
+	}
+
+	tpl, err = Parse(name, string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.templates[name] = tpl
+	s.mu.Unlock()
+
+	return tpl, nil
+}
+
+// Render 加载并渲染模板
+func (s *Store) Render(name string, vars map[string]interface{}) (string, error) {
+	tpl, err := s.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return tpl.Render(vars)
+}
+
+// Reload 清空缓存，下次使用时重新从文件加载
+func (s *Store) Reload() {
+	s.mu.Lock()
+	s.templates = make(map[string]*Template)
+	s.mu.Unlock()
+}
@@ -0,0 +1,255 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Template 卡片模板（JSON 文本 + {{name}} 占位符）
+// 支持的语法：
+//   - {{name}}                         变量替换（值按 JSON 字符串转义）
+//   - {{#if name}}...{{else}}...{{/if}} 条件块（变量缺失或为零值时取 else 分支）
+type Template struct {
+	Name  string
+	nodes []node
+	vars  []string // 模板引用的变量（出现在 {{name}} 中的变量）
+}
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeVar
+	nodeIf
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // nodeText: 原文；nodeVar/nodeIf: 变量名
+	then     []node
+	elseNode []node
+}
+
+// Parse 解析模板文本
+func Parse(name, src string) (*Template, error) {
+	p := &parser{src: src}
+	nodes, end, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("模板 %s 解析失败: %w", name, err)
+	}
+	if end != "" {
+		return nil, fmt.Errorf("模板 %s 解析失败: 多余的 {{%s}}", name, end)
+	}
+
+	seen := make(map[string]bool)
+	collectVars(nodes, seen)
+	vars := make([]string, 0, len(seen))
+	for v := range seen {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+
+	return &Template{Name: name, nodes: nodes, vars: vars}, nil
+}
+
+// Vars 返回模板引用的全部变量
+func (t *Template) Vars() []string {
+	return append([]string(nil), t.vars...)
+}
+
+// Render 渲染模板
+// 实际渲染到的分支中缺少变量，或渲染结果不是合法 JSON 时返回错误
+func (t *Template) Render(vars map[string]interface{}) (string, error) {
+	var builder strings.Builder
+	missing := make(map[string]bool)
+	if err := renderNodes(&builder, t.nodes, vars, missing); err != nil {
+		return "", fmt.Errorf("模板 %s 渲染失败: %w", t.Name, err)
+	}
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("模板 %s 缺少变量: %s", t.Name, strings.Join(names, ", "))
+	}
+
+	out := builder.String()
+	if !json.Valid([]byte(out)) {
+		return "", fmt.Errorf("模板 %s 渲染结果不是合法 JSON", t.Name)
+	}
+	return out, nil
+}
+
+func renderNodes(builder *strings.Builder, nodes []node, vars map[string]interface{}, missing map[string]bool) error {
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText:
+			builder.WriteString(n.text)
+		case nodeVar:
+			value, ok := vars[n.text]
+			if !ok {
+				missing[n.text] = true
+				continue
+			}
+			escaped, err := escapeValue(value)
+			if err != nil {
+				return fmt.Errorf("变量 %s: %w", n.text, err)
+			}
+			builder.WriteString(escaped)
+		case nodeIf:
+			branch := n.elseNode
+			if truthy(vars[n.text]) {
+				branch = n.then
+			}
+			if err := renderNodes(builder, branch, vars, missing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// escapeValue 将变量值转为可以直接嵌入 JSON 字符串字面量的文本
+func escapeValue(value interface{}) (string, error) {
+	var str string
+	switch v := value.(type) {
+	case nil:
+		str = ""
+	case string:
+		str = v
+	case fmt.Stringer:
+		str = v.String()
+	default:
+		str = fmt.Sprint(v)
+	}
+
+	data, err := json.Marshal(str)
+	if err != nil {
+		return "", err
+	}
+	// 去掉首尾引号，只保留转义后的内容
+	return string(data[1 : len(data)-1]), nil
+}
+
+// truthy 判断条件变量是否为真
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case int:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case []string:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func collectVars(nodes []node, seen map[string]bool) {
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeVar:
+			seen[n.text] = true
+		case nodeIf:
+			collectVars(n.then, seen)
+			collectVars(n.elseNode, seen)
+		}
+	}
+}
+
+// parser 简单的递归下降解析器
+type parser struct {
+	src string
+	pos int
+}
+
+// parse 解析到文件结束或遇到 {{else}} / {{/if}}，返回遇到的结束标签
+func (p *parser) parse() ([]node, string, error) {
+	var nodes []node
+	for p.pos < len(p.src) {
+		start := strings.Index(p.src[p.pos:], "{{")
+		if start < 0 {
+			nodes = append(nodes, node{kind: nodeText, text: p.src[p.pos:]})
+			p.pos = len(p.src)
+			break
+		}
+		if start > 0 {
+			nodes = append(nodes, node{kind: nodeText, text: p.src[p.pos : p.pos+start]})
+		}
+		p.pos += start
+
+		end := strings.Index(p.src[p.pos:], "}}")
+		if end < 0 {
+			return nil, "", fmt.Errorf("位置 %d 的 {{ 未闭合", p.pos)
+		}
+		tag := strings.TrimSpace(p.src[p.pos+2 : p.pos+end])
+		p.pos += end + 2
+
+		switch {
+		case tag == "else" || tag == "/if":
+			return nodes, tag, nil
+		case strings.HasPrefix(tag, "#if "):
+			name := strings.TrimSpace(strings.TrimPrefix(tag, "#if "))
+			if !isIdent(name) {
+				return nil, "", fmt.Errorf("无效的条件变量: %q", name)
+			}
+			ifNode, err := p.parseIf(name)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, ifNode)
+		case isIdent(tag):
+			nodes = append(nodes, node{kind: nodeVar, text: tag})
+		default:
+			return nil, "", fmt.Errorf("不支持的标签: {{%s}}", tag)
+		}
+	}
+	return nodes, "", nil
+}
+
+func (p *parser) parseIf(name string) (node, error) {
+	n := node{kind: nodeIf, text: name}
+
+	then, end, err := p.parse()
+	if err != nil {
+		return n, err
+	}
+	n.then = then
+
+	if end == "else" {
+		elseNodes, end2, err := p.parse()
+		if err != nil {
+			return n, err
+		}
+		n.elseNode = elseNodes
+		end = end2
+	}
+
+	if end != "/if" {
+		return n, fmt.Errorf("{{#if %s}} 缺少 {{/if}}", name)
+	}
+	return n, nil
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
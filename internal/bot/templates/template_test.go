@@ -0,0 +1,208 @@
+package templates
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAndRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		vars     map[string]interface{}
+		want     string
+		wantVars []string
+	}{
+		{"plain text", `{"a":1}`, nil, `{"a":1}`, nil},
+		{"variable", `{"a":"{{name}}"}`, map[string]interface{}{"name": "x"}, `{"a":"x"}`, []string{"name"}},
+		{"spaces in tag", `{"a":"{{ name }}"}`, map[string]interface{}{"name": "x"}, `{"a":"x"}`, []string{"name"}},
+		{"if true", `{"a":"{{#if on}}yes{{/if}}"}`, map[string]interface{}{"on": true}, `{"a":"yes"}`, nil},
+		{"if false", `{"a":"{{#if on}}yes{{/if}}"}`, map[string]interface{}{"on": false}, `{"a":""}`, nil},
+		{"if missing", `{"a":"{{#if on}}yes{{/if}}"}`, nil, `{"a":""}`, nil},
+		{"if else", `{"a":"{{#if on}}yes{{else}}no{{/if}}"}`, map[string]interface{}{"on": 0}, `{"a":"no"}`, nil},
+		{"if non-empty string", `{"a":"{{#if s}}{{s}}{{else}}none{{/if}}"}`, map[string]interface{}{"s": "v"}, `{"a":"v"}`, []string{"s"}},
+		{"if empty string", `{"a":"{{#if s}}{{s}}{{else}}none{{/if}}"}`, map[string]interface{}{"s": ""}, `{"a":"none"}`, []string{"s"}},
+		{"if empty slice", `{"a":"{{#if l}}some{{else}}none{{/if}}"}`, map[string]interface{}{"l": []string{}}, `{"a":"none"}`, nil},
+		{
+			"nested if",
+			`{"a":"{{#if x}}{{#if y}}xy{{else}}x{{/if}}{{else}}-{{/if}}"}`,
+			map[string]interface{}{"x": true, "y": false},
+			`{"a":"x"}`,
+			nil,
+		},
+		{
+			"missing variable in untaken branch",
+			`{"a":"{{#if on}}{{value}}{{else}}off{{/if}}"}`,
+			map[string]interface{}{"on": false},
+			`{"a":"off"}`,
+			[]string{"value"},
+		},
+		{
+			"conditional element",
+			`{"e":[{{#if x}}{"t":"{{x}}"},{{/if}}{"t":"end"}]}`,
+			map[string]interface{}{"x": "v"},
+			`{"e":[{"t":"v"},{"t":"end"}]}`,
+			[]string{"x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := Parse(tt.name, tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if vars := tpl.Vars(); !reflect.DeepEqual(vars, tt.wantVars) {
+				t.Errorf("Vars() = %v, want %v", vars, tt.wantVars)
+			}
+			got, err := tpl.Render(tt.vars)
+			if err != nil {
+				t.Fatalf("Render() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"unclosed tag", `{"a":"{{name"}`},
+		{"stray end if", `{"a":"x{{/if}}"}`},
+		{"stray else", `{"a":"x{{else}}"}`},
+		{"missing end if", `{"a":"{{#if x}}yes"}`},
+		{"missing end if after else", `{"a":"{{#if x}}yes{{else}}no"}`},
+		{"double else", `{"a":"{{#if x}}a{{else}}b{{else}}c{{/if}}"}`},
+		{"invalid condition", `{"a":"{{#if a-b}}x{{/if}}"}`},
+		{"empty condition", `{"a":"{{#if }}x{{/if}}"}`},
+		{"invalid variable", `{"a":"{{a.b}}"}`},
+		{"unsupported tag", `{"a":"{{#each items}}"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tpl, err := Parse(tt.name, tt.src); err == nil {
+				t.Fatalf("Parse(%q) = %+v, want error", tt.src, tpl)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		vars    map[string]interface{}
+		wantErr string
+	}{
+		{"missing variables", `{"a":"{{b}}{{a}}"}`, nil, "缺少变量: a, b"},
+		{"missing variable in taken branch", `{"a":"{{#if on}}{{v}}{{/if}}"}`, map[string]interface{}{"on": true}, "缺少变量: v"},
+		{"invalid JSON", `{"a":{{n}}}`, map[string]interface{}{"n": "x"}, "不是合法 JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := Parse(tt.name, tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			_, err = tpl.Render(tt.vars)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEscapeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, ""},
+		{"plain", "hello", "hello"},
+		{"quote", `say "hi"`, `say \"hi\"`},
+		{"backslash", `C:\dir`, `C:\\dir`},
+		{"newline and tab", "a\nb\tc", `a\nb\tc`},
+		{"control character", "a\x01b", `a\u0001b`},
+		{"html characters", "<at id=1></at> & more", `\u003cat id=1\u003e\u003c/at\u003e \u0026 more`},
+		{"non-ASCII", "中文 🎉", "中文 🎉"},
+		{"closing brace and tag", `"}, {"tag": "x`, `\"}, {\"tag\": \"x`},
+		{"int", 42, "42"},
+		{"float", 0.5, "0.5"},
+		{"bool", true, "true"},
+		{"stringer", 1500 * time.Millisecond, "1.5s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := escapeValue(tt.value)
+			if err != nil {
+				t.Fatalf("escapeValue() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("escapeValue(%#v) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEscapedValueStaysInString 变量值不能跳出所在的 JSON 字符串（注入额外字段或元素）
+func TestEscapedValueStaysInString(t *testing.T) {
+	tpl, err := Parse("inject", `{"text":"{{v}}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := `x", "tag": "button", "y": "` + "\n\\"
+	got, err := tpl.Render(map[string]interface{}{"v": value})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded["text"] != value {
+		t.Fatalf("Render() = %s, decoded %v, want only text = %q", got, decoded, value)
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	store := NewStore(filepath.Join("..", "..", "..", DefaultDir))
+	tpl, err := store.Get(TaskCompleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := make(map[string]interface{})
+	for _, name := range tpl.Vars() {
+		vars[name] = "v"
+	}
+	for _, isError := range []bool{false, true} {
+		vars["is_error"] = isError
+		if _, err := tpl.Render(vars); err != nil {
+			t.Errorf("Render(is_error=%v) error: %v", isError, err)
+		}
+	}
+
+	// 目录中的每个模板都应能解析（启动后首次使用时才会加载）
+	files, err := filepath.Glob(filepath.Join(store.dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(filepath.Base(file), string(data)); err != nil {
+			t.Errorf("Parse(%s) error: %v", file, err)
+		}
+	}
+}
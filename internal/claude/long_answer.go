@@ -0,0 +1,96 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"feishu-bot/internal/bot/client"
+	"feishu-bot/internal/utils"
+)
+
+// AnswerUploader 超长回答的投递方式（文件附件 / 云文档 / 本地文件）
+type AnswerUploader interface {
+	// Upload 投递完整回答，返回可以附在提示消息里的链接（没有链接时返回空字符串）
+	Upload(receiveID, receiveIDType, runID, title, markdown string) (string, error)
+	// Kind 投递方式名称（用于提示消息）
+	Kind() string
+}
+
+// NewAnswerUploader 根据 LONG_ANSWER_MODE 创建投递方式：file（默认）/ docx / local / off
+func NewAnswerUploader(feishuClient *client.FeishuClient) AnswerUploader {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("LONG_ANSWER_MODE"))) {
+	case "off", "false", "none":
+		return nil
+	case "docx", "doc":
+		return &docxAnswerUploader{
+			feishuClient: feishuClient,
+			baseURL:      utils.GetEnvOrDefault("FEISHU_DOC_BASE_URL", "https://feishu.cn/docx/"),
+		}
+	case "local":
+		return &localAnswerUploader{
+			dir: utils.GetEnvOrDefault("LONG_ANSWER_DIR", filepath.Join(os.TempDir(), "feishu-answers")),
+		}
+	default:
+		return &fileAnswerUploader{feishuClient: feishuClient}
+	}
+}
+
+// fileAnswerUploader 以 .md 文件消息发送完整回答
+type fileAnswerUploader struct {
+	feishuClient *client.FeishuClient
+}
+
+func (u *fileAnswerUploader) Kind() string { return "文件" }
+
+func (u *fileAnswerUploader) Upload(receiveID, receiveIDType, runID, title, markdown string) (string, error) {
+	fileKey, err := u.feishuClient.UploadFile(answerFileName(title), []byte(markdown))
+	if err != nil {
+		return "", err
+	}
+	if err := u.feishuClient.SendFile(receiveID, receiveIDType, fileKey, runID); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// docxAnswerUploader 创建飞书云文档保存完整回答
+type docxAnswerUploader struct {
+	feishuClient *client.FeishuClient
+	baseURL      string
+}
+
+func (u *docxAnswerUploader) Kind() string { return "云文档" }
+
+func (u *docxAnswerUploader) Upload(receiveID, receiveIDType, runID, title, markdown string) (string, error) {
+	documentID, err := u.feishuClient.CreateDocument(title, markdown, receiveID, receiveIDType)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(u.baseURL, "/") + "/" + documentID, nil
+}
+
+// localAnswerUploader 写入本地目录（本地调试用，不调用飞书接口）
+type localAnswerUploader struct {
+	dir string
+}
+
+func (u *localAnswerUploader) Kind() string { return "本地文件" }
+
+func (u *localAnswerUploader) Upload(receiveID, receiveIDType, runID, title, markdown string) (string, error) {
+	if err := os.MkdirAll(u.dir, 0755); err != nil {
+		return "", fmt.Errorf("创建目录失败: %w", err)
+	}
+	path := filepath.Join(u.dir, answerFileName(title))
+	if err := os.WriteFile(path, []byte(markdown), 0644); err != nil {
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+	return path, nil
+}
+
+// answerFileName 根据标题生成文件名
+func answerFileName(title string) string {
+	replacer := strings.NewReplacer(" ", "-", ":", "", "/", "-", "\\", "-")
+	return replacer.Replace(title) + ".md"
+}
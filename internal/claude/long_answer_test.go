@@ -0,0 +1,156 @@
+package claude
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubUploader 记录上传内容的 AnswerUploader
+type stubUploader struct {
+	uploads []string
+}
+
+func (u *stubUploader) Kind() string { return "测试" }
+
+func (u *stubUploader) Upload(receiveID, receiveIDType, runID, title, markdown string) (string, error) {
+	u.uploads = append(u.uploads, markdown)
+	return "https://example.invalid/doc", nil
+}
+
+func newLongAnswerHandler(uploader AnswerUploader) *StreamingTextHandler {
+	return &StreamingTextHandler{
+		maxBufferSize:       100,
+		longAnswerThreshold: 250,
+		uploader:            uploader,
+		logger:              log.New(io.Discard, "", 0),
+	}
+}
+
+func TestCheckLongAnswer(t *testing.T) {
+	tests := []struct {
+		name       string
+		uploader   AnswerUploader
+		answer     int // 回答总长
+		buffer     int // 其中尚未发送的长度
+		want       bool
+		wantOffset int
+	}{
+		{"short answer", &stubUploader{}, 50, 50, false, 0},
+		{"full chunk below threshold", &stubUploader{}, 150, 120, false, 0},
+		{"at threshold", &stubUploader{}, 250, 100, false, 0},
+		{"above threshold", &stubUploader{}, 260, 60, true, 200},
+		{"no uploader", nil, 1000, 100, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newLongAnswerHandler(tt.uploader)
+			h.answer = []rune(strings.Repeat("a", tt.answer))
+			h.buffer = []rune(strings.Repeat("a", tt.buffer))
+
+			if got := h.checkLongAnswer(); got != tt.want {
+				t.Fatalf("checkLongAnswer() = %v, want %v", got, tt.want)
+			}
+			if h.longMode != tt.want {
+				t.Fatalf("longMode = %v, want %v", h.longMode, tt.want)
+			}
+			if !tt.want {
+				if len(h.buffer) != tt.buffer {
+					t.Fatalf("buffer = %d chars, want untouched %d", len(h.buffer), tt.buffer)
+				}
+				return
+			}
+			if h.longModeOffset != tt.wantOffset || len(h.buffer) != 0 {
+				t.Fatalf("offset = %d buffer = %d, want offset %d and empty buffer", h.longModeOffset, len(h.buffer), tt.wantOffset)
+			}
+
+			// 进入超长模式后新增内容不再分段发送
+			h.buffer = append(h.buffer, []rune("more")...)
+			if !h.checkLongAnswer() || len(h.buffer) != 0 {
+				t.Fatalf("checkLongAnswer() in long mode should drop buffered text, buffer = %q", string(h.buffer))
+			}
+		})
+	}
+}
+
+func TestLongAnswerTextCountsUnsentRedactions(t *testing.T) {
+	secret := "sk-ant-" + strings.Repeat("x", 30)
+	sent := "sent " + secret + " "
+	unsent := "unsent " + secret
+
+	h := newLongAnswerHandler(&stubUploader{})
+	h.answer = []rune(sent + unsent)
+	h.longModeOffset = len([]rune(sent))
+	h.redactions = 1 // 已发送分段中的一次替换
+
+	answer, remaining := h.longAnswerText()
+	if strings.Contains(answer, secret) || strings.Count(answer, "[REDACTED]") != 2 {
+		t.Fatalf("answer = %q, want both secrets redacted", answer)
+	}
+	if remaining != "unsent [REDACTED]" {
+		t.Fatalf("remaining = %q, want the redacted unsent part", remaining)
+	}
+	if h.redactions != 2 {
+		t.Fatalf("redactions = %d, want 2 (sent part counted once)", h.redactions)
+	}
+}
+
+func TestNewAnswerUploader(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string // Kind()，空表示关闭
+	}{
+		{"", "文件"},
+		{"file", "文件"},
+		{"docx", "云文档"},
+		{" DOC ", "云文档"},
+		{"local", "本地文件"},
+		{"off", ""},
+		{"none", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("LONG_ANSWER_MODE", tt.mode)
+			u := NewAnswerUploader(nil)
+			if tt.want == "" {
+				if u != nil {
+					t.Fatalf("NewAnswerUploader() = %T, want nil", u)
+				}
+				return
+			}
+			if u == nil || u.Kind() != tt.want {
+				t.Fatalf("NewAnswerUploader() = %v, want kind %s", u, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalAnswerUploader(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "answers")
+	u := &localAnswerUploader{dir: dir}
+
+	link, err := u.Upload("oc_1", "chat_id", "run_1", "Claude 回答 2024-01-02 03:04:05", "# 标题\n内容")
+	if err != nil {
+		t.Fatalf("Upload() error: %v", err)
+	}
+	if want := filepath.Join(dir, "Claude-回答-2024-01-02-030405.md"); link != want {
+		t.Fatalf("Upload() = %s, want %s", link, want)
+	}
+	data, err := os.ReadFile(link)
+	if err != nil || string(data) != "# 标题\n内容" {
+		t.Fatalf("file content = %q, %v", data, err)
+	}
+}
+
+func TestAnswerSummary(t *testing.T) {
+	if got := answerSummary("  short  ", 5); got != "short" {
+		t.Fatalf("answerSummary(short) = %q", got)
+	}
+	got := answerSummary("0123456789abcdefghij", 3)
+	if want := "开头：\n012\n……\n结尾：\nhij"; got != want {
+		t.Fatalf("answerSummary(long) = %q, want %q", got, want)
+	}
+}
@@ -35,6 +35,37 @@ type TextDelta struct {
 	} `json:"delta"`
 }
 
+// RunResult 单次运行的统计信息（来自 stream-json 的 result 事件和工具调用记录）
+type RunResult struct {
+	Result       string   // 最终回答文本
+	IsError      bool     // CLI 是否报告错误
+	CostUSD      float64  // 本次运行花费（美元）
+	DurationMs   int64    // CLI 报告的耗时
+	NumTurns     int      // 对话轮数
+	ChangedFiles []string // 通过 Edit/Write 等工具修改过的文件
+	Redactions   int      // 出站内容中被脱敏的敏感信息数量
+}
+
+// resultEvent stream-json 的最终 result 事件
+type resultEvent struct {
+	Type         string  `json:"type"`
+	Subtype      string  `json:"subtype"`
+	IsError      bool    `json:"is_error"`
+	Result       string  `json:"result"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	DurationMs   int64   `json:"duration_ms"`
+	NumTurns     int     `json:"num_turns"`
+	SessionID    string  `json:"session_id"`
+}
+
+// fileEditTools 会修改文件的工具及其路径参数名
+var fileEditTools = map[string]string{
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"Write":        "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
 // ClaudeManager Claude CLI 进程管理器
 type ClaudeManager struct {
 	cmd           *exec.Cmd
@@ -58,6 +89,8 @@ type ClaudeManager struct {
 	onError       func(err error)
 	pendingTool   *pendingToolCall // 当前待执行的工具
 	lastError     error            // 记录最后一个错误
+	runResult     RunResult        // 运行统计
+	changedFiles  map[string]bool  // 已记录的修改文件（去重）
 
 	// 批量发送优化
 	lastUpdateLen int             // 上次发送时的文本长度
@@ -69,6 +102,10 @@ type ClaudeManager struct {
 // ClaudeConfig Claude CLI 配置
 type ClaudeConfig struct {
 	ProjectDir    string
+	AddDirs       []string // 额外允许访问的目录（--add-dir）
+	Model         string   // 模型（--model，为空使用 CLI 默认模型）
+	PermissionMode string  // 权限模式（--permission-mode，为空时跳过所有权限确认）
+	SystemPrompt  string   // 追加的系统提示（--append-system-prompt）
 	InitialPrompt string
 }
 
@@ -131,18 +168,32 @@ func (m *ClaudeManager) Start(ctx context.Context, userMessage, resumeSessionID
 		args = append(args, "--resume", resumeSessionID)
 		m.sessionID = resumeSessionID
 	}
+	for _, dir := range m.config.AddDirs {
+		args = append(args, "--add-dir", dir)
+	}
+	if m.config.Model != "" {
+		args = append(args, "--model", m.config.Model)
+	}
+	if m.config.SystemPrompt != "" {
+		args = append(args, "--append-system-prompt", m.config.SystemPrompt)
+	}
+	if m.config.PermissionMode != "" {
+		args = append([]string{"--permission-mode", m.config.PermissionMode}, args...)
+	} else {
+		args = append([]string{"--dangerously-skip-permissions"}, args...)
+	}
 
 	// 从环境变量读取 Claude CLI 路径，默认使用 "claude" 从 PATH 查找
-	claudePath := getEnvOrDefault("CLAUDE_CLI_PATH", "claude")
-	m.cmd = exec.CommandContext(ctx, claudePath, append([]string{"--dangerously-skip-permissions"}, args...)...)
+	claudePath := utils.GetEnvOrDefault("CLAUDE_CLI_PATH", "claude")
+	m.cmd = exec.CommandContext(ctx, claudePath, args...)
 
 	// 从环境变量设置 Claude 配置
 	m.cmd.Env = append(os.Environ(),
-		fmt.Sprintf("ANTHROPIC_BASE_URL=%s", getEnvOrDefault("ANTHROPIC_BASE_URL", "https://api.anthropic.com")),
-		fmt.Sprintf("ANTHROPIC_API_KEY=%s", getEnvOrDefault("ANTHROPIC_API_KEY", "")),
-		fmt.Sprintf("ANTHROPIC_AUTH_TOKEN=%s", getEnvOrDefault("ANTHROPIC_AUTH_TOKEN", "")),
-		fmt.Sprintf("CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC=%s", getEnvOrDefault("CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC", "true")),
-		fmt.Sprintf("CLAUDE_CODE_ENABLE_UNIFIED_READ_TOOL=%s", getEnvOrDefault("CLAUDE_CODE_ENABLE_UNIFIED_READ_TOOL", "true")),
+		fmt.Sprintf("ANTHROPIC_BASE_URL=%s", utils.GetEnvOrDefault("ANTHROPIC_BASE_URL", "https://api.anthropic.com")),
+		fmt.Sprintf("ANTHROPIC_API_KEY=%s", utils.GetEnvOrDefault("ANTHROPIC_API_KEY", "")),
+		fmt.Sprintf("ANTHROPIC_AUTH_TOKEN=%s", utils.GetEnvOrDefault("ANTHROPIC_AUTH_TOKEN", "")),
+		fmt.Sprintf("CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC=%s", utils.GetEnvOrDefault("CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC", "true")),
+		fmt.Sprintf("CLAUDE_CODE_ENABLE_UNIFIED_READ_TOOL=%s", utils.GetEnvOrDefault("CLAUDE_CODE_ENABLE_UNIFIED_READ_TOOL", "true")),
 	)
 
 	// 设置工作目录为项目目录
@@ -198,6 +249,8 @@ func (m *ClaudeManager) Start(ctx context.Context, userMessage, resumeSessionID
 	m.currentText.Reset()
 	m.textSequence = 0
 	m.lastMessageID = ""
+	m.runResult = RunResult{}
+	m.changedFiles = make(map[string]bool)
 
 	// 启动输出解析协程
 	go m.processUpdates()
@@ -254,6 +307,8 @@ func (m *ClaudeManager) parseOutput() {
 			}
 			// 系统事件，记录但不处理
 			log.Printf("[Claude CLI] System event: %s", line)
+		case "result":
+			m.handleResultEvent(line)
 		case "error":
 			m.handleError(fmt.Errorf("claude error: %s", line))
 		}
@@ -343,8 +398,74 @@ func (m *ClaudeManager) handleStreamEvent(event StreamEvent) {
 	}
 }
 
+// handleResultEvent 记录最终 result 事件中的统计信息
+func (m *ClaudeManager) handleResultEvent(line string) {
+	var result resultEvent
+	if err := json.Unmarshal([]byte(line), &result); err != nil {
+		log.Printf("[ClaudeManager] Failed to parse result event: %v", err)
+		return
+	}
+	if result.SessionID != "" {
+		m.setSessionID(result.SessionID)
+	}
+
+	m.mu.Lock()
+	m.runResult.Result = result.Result
+	m.runResult.IsError = result.IsError
+	m.runResult.CostUSD = result.TotalCostUSD
+	m.runResult.DurationMs = result.DurationMs
+	m.runResult.NumTurns = result.NumTurns
+	m.mu.Unlock()
+
+	log.Printf("[ClaudeManager] result: subtype=%s is_error=%t cost_usd=%.4f duration_ms=%d turns=%d",
+		result.Subtype, result.IsError, result.TotalCostUSD, result.DurationMs, result.NumTurns)
+}
+
+// recordChangedFiles 从 assistant 消息的 tool_use 块中记录被修改的文件
+func (m *ClaudeManager) recordChangedFiles(message map[string]interface{}) {
+	contentSlice, ok := message["content"].([]interface{})
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range contentSlice {
+		contentItem, ok := item.(map[string]interface{})
+		if !ok || contentItem["type"] != "tool_use" {
+			continue
+		}
+		name, _ := contentItem["name"].(string)
+		pathKey, ok := fileEditTools[name]
+		if !ok {
+			continue
+		}
+		input, _ := contentItem["input"].(map[string]interface{})
+		path, _ := input[pathKey].(string)
+		if path == "" || m.changedFiles[path] {
+			continue
+		}
+		if m.changedFiles == nil {
+			m.changedFiles = make(map[string]bool)
+		}
+		m.changedFiles[path] = true
+		m.runResult.ChangedFiles = append(m.runResult.ChangedFiles, path)
+	}
+}
+
+// GetRunResult 返回本次运行的统计信息
+func (m *ClaudeManager) GetRunResult() RunResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := m.runResult
+	result.ChangedFiles = append([]string(nil), m.runResult.ChangedFiles...)
+	return result
+}
+
 // handleAssistantMessage 处理完整的 assistant 消息快照
 func (m *ClaudeManager) handleAssistantMessage(event StreamEvent) {
+	m.recordChangedFiles(event.Message)
+
 	assistantText := extractAssistantText(event.Message)
 	if assistantText == "" {
 		return
@@ -814,11 +935,3 @@ func (m *ClaudeManager) stopFlushTimer() {
 		m.flushTimer = nil
 	}
 }
-
-// getEnvOrDefault 获取环境变量，如果不存在则返回默认值
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
@@ -4,20 +4,24 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"feishu-bot/internal/bot/client"
+	"feishu-bot/internal/redact"
 	"feishu-bot/internal/utils"
 )
 
+// cardMaxBufferSize 卡片模式下单段的最大字符数（卡片内容上限约 30KB）
+const cardMaxBufferSize = 8000
+
 // StreamingTextHandler 流式文本处理器（不使用 CardKit，节省 API 调用）
 type StreamingTextHandler struct {
 	feishuClient  *client.FeishuClient
 	claudeManager *ClaudeManager
 	lastSessionID string
+	lastResult    RunResult
 	logger        *log.Logger
 
 	// 流式发送状态
@@ -25,13 +29,30 @@ type StreamingTextHandler struct {
 	bufferMu     sync.Mutex
 	receiveID    string
 	receiveIDType string
+	runID        string    // 当前运行 ID（触发消息的 message_id，用于死信追踪）
 	lastFullLen  int       // 上次完整文本的长度（用于计算增量）
+	pendingSends []<-chan error // 已入队但尚未确认结果的分段
+	redactions   int            // 本次运行脱敏替换次数
+	mentionOpenID string        // 第一段回复 @ 的用户（为空表示不 @）
+	addDirs       []string      // 附加目录（--add-dir）
+	model         string        // 模型（--model）
+	permissionMode string       // 权限模式（--permission-mode）
+	systemPrompt  string        // 追加的系统提示
+	format        string        // 回复消息格式（client.FormatText / FormatPost / FormatCard）
+	mentioned     bool          // 本次运行是否已 @ 过
 
 	// 时间分段配置
 	idleTimeout     time.Duration // 空闲超时：N毫秒无新数据则发送
 	maxDuration     time.Duration // 最大持续时间：连续输出N秒后强制分段
 	maxBufferSize   int           // 最大缓冲区大小：超过此大小强制分段（防止超过飞书150KB限制）
 
+	// 超长回答：超过阈值后停止分段发送，结束时以文件/云文档投递完整回答
+	longAnswerThreshold int
+	uploader            AnswerUploader
+	answer              []rune // 完整回答
+	longMode            bool   // 是否已进入超长回答模式
+	longModeOffset      int    // 进入超长模式时已发送的字符数
+
 	// 定时器控制
 	lastDataTime    time.Time     // 最后一次收到数据的时间
 	durationTimer   *time.Timer   // 持续时间定时器
@@ -50,7 +71,9 @@ func NewStreamingTextHandler(feishuClient *client.FeishuClient) *StreamingTextHa
 		idleTimeout:   timeoutConfig.StreamIdleTimeout,
 		maxDuration:   timeoutConfig.StreamMaxDuration,
 		maxBufferSize: timeoutConfig.StreamMaxBufferSize,
-		logger:        log.New(os.Stdout, "[StreamingTextHandler] ", log.LstdFlags),
+		longAnswerThreshold: timeoutConfig.LongAnswerThreshold,
+		uploader:      NewAnswerUploader(feishuClient),
+		logger:        log.New(log.Writer(), "[StreamingTextHandler] ", log.LstdFlags),
 		stopTimers:    make(chan struct{}),
 	}
 }
@@ -59,42 +82,35 @@ func NewStreamingTextHandler(feishuClient *client.FeishuClient) *StreamingTextHa
 func (h *StreamingTextHandler) HandleMessage(ctx context.Context, token, receiveID, receiveIDType, userMessage, resumeSessionID, projectDir string) error {
 	h.logger.Printf("Processing message with time-based streaming mode: receive_id=%s type=%s project_dir=%s", receiveID, receiveIDType, projectDir)
 
+	startTime := time.Now()
+
 	// 初始化状态
 	h.receiveID = receiveID
 	h.receiveIDType = receiveIDType
 	h.buffer = make([]rune, 0)
+	h.answer = make([]rune, 0)
+	h.longMode = false
+	h.longModeOffset = 0
+	h.redactions = 0
+	h.mentioned = false
 	h.lastDataTime = time.Now()
 	h.stopTimers = make(chan struct{})
 
 	// 启动空闲定时器 goroutine（只启动一次）
 	h.runIdleTimerGoroutine()
 
-	// 初始化 Claude 管理器（带项目目录）
-	config := ClaudeConfig{}
+	// 初始化 Claude 管理器（带项目目录）；resume 失败重试时沿用同一配置
+	config := ClaudeConfig{
+		AddDirs:        h.addDirs,
+		Model:          h.model,
+		PermissionMode: h.permissionMode,
+		SystemPrompt:   h.systemPrompt,
+	}
 	if projectDir != "" {
 		config.ProjectDir = projectDir
-		h.logger.Printf("Using project directory: %s", projectDir)
+		h.logger.Printf("Using project directory: %s (add dirs: %v)", projectDir, h.addDirs)
 	}
-	h.claudeManager = NewClaudeManager(config)
-
-	// 设置文本增量回调 - 基于时间智能分段
-	h.claudeManager.SetTextDeltaCallback(func(text string, sequence int) error {
-		h.logger.Printf("[TextDelta] seq=%d text_len=%d", sequence, len(text))
-		return h.onTextDelta(text)
-	})
-
-	// 设置完成回调 - 只记录日志，不立即发送（防止多次触发）
-	// 发送由 WaitForExit 后统一处理
-	h.claudeManager.SetCompleteCallback(func(finalText string) error {
-		h.logger.Printf("[Complete] final_text_len=%d (skipping send)", len(finalText))
-		return nil
-	})
-
-	// 设置错误回调 - 不停止定时器，让空闲定时器继续工作
-	h.claudeManager.SetErrorCallback(func(err error) {
-		h.logger.Printf("[Error] Claude error: %v", err)
-		// 不停止定时器，让 idleTimer 继续发送缓冲区内容
-	})
+	h.newClaudeManager(config, "")
 
 	// 启动 Claude CLI
 	h.logger.Printf("Starting Claude CLI...")
@@ -115,27 +131,8 @@ func (h *StreamingTextHandler) HandleMessage(ctx context.Context, token, receive
 			h.logger.Printf("Session resume failed, retrying without resume...")
 			// 不停止定时器，让空闲定时器继续工作
 
-			// 重新初始化 manager，不使用 resume
-			config := ClaudeConfig{}
-			if projectDir != "" {
-				config.ProjectDir = projectDir
-			}
-			h.claudeManager = NewClaudeManager(config)
-
-			// 重新设置回调
-			h.claudeManager.SetTextDeltaCallback(func(text string, sequence int) error {
-				h.logger.Printf("[TextDelta] seq=%d text_len=%d", sequence, len(text))
-				return h.onTextDelta(text)
-			})
-
-			h.claudeManager.SetCompleteCallback(func(finalText string) error {
-				h.logger.Printf("[Complete] final_text_len=%d (skipping send, retry)", len(finalText))
-				return nil
-			})
-
-			h.claudeManager.SetErrorCallback(func(err error) {
-				h.logger.Printf("[Error] %v", err)
-			})
+			// 重新初始化 manager（与首次相同的配置），不使用 resume
+			h.newClaudeManager(config, " (retry)")
 
 			// 重新启动（不使用 resume）
 			if err := h.claudeManager.Start(ctx, userMessage, ""); err != nil {
@@ -175,11 +172,41 @@ func (h *StreamingTextHandler) HandleMessage(ctx context.Context, token, receive
 	h.stopAllTimers() // 最终发送完成后停止定时器
 
 	h.lastSessionID = h.claudeManager.GetSessionID()
+	h.lastResult = h.claudeManager.GetRunResult()
+	h.bufferMu.Lock()
+	h.lastResult.Redactions = h.redactions
+	h.bufferMu.Unlock()
+	if h.lastResult.DurationMs == 0 {
+		h.lastResult.DurationMs = time.Since(startTime).Milliseconds()
+	}
 	h.logger.Printf("Message processing completed, session_id=%s", h.lastSessionID)
 
 	return nil
 }
 
+// newClaudeManager 按配置创建 Claude 管理器并设置回调；logSuffix 附加在完成、错误日志后（区分重试）
+func (h *StreamingTextHandler) newClaudeManager(config ClaudeConfig, logSuffix string) {
+	h.claudeManager = NewClaudeManager(config)
+
+	// 设置文本增量回调 - 基于时间智能分段
+	h.claudeManager.SetTextDeltaCallback(func(text string, sequence int) error {
+		h.logger.Printf("[TextDelta] seq=%d text_len=%d", sequence, len(text))
+		return h.onTextDelta(text)
+	})
+
+	// 设置完成回调 - 只记录日志，不立即发送（防止多次触发）
+	// 发送由 WaitForExit 后统一处理
+	h.claudeManager.SetCompleteCallback(func(finalText string) error {
+		h.logger.Printf("[Complete] final_text_len=%d (skipping send%s)", len(finalText), logSuffix)
+		return nil
+	})
+
+	// 设置错误回调 - 不停止定时器，让空闲定时器继续工作
+	h.claudeManager.SetErrorCallback(func(err error) {
+		h.logger.Printf("[Error] Claude error%s: %v", logSuffix, err)
+	})
+}
+
 // onTextDelta 收到文本增量时的处理
 func (h *StreamingTextHandler) onTextDelta(text string) error {
 	h.bufferMu.Lock()
@@ -193,6 +220,7 @@ func (h *StreamingTextHandler) onTextDelta(text string) error {
 	if newLen > h.lastFullLen {
 		newContent := fullTextRunes[h.lastFullLen:]
 		h.buffer = append(h.buffer, newContent...)
+		h.answer = append(h.answer, newContent...)
 		h.lastFullLen = newLen
 		h.logger.Printf("[Buffer] accumulated=%d chars, new_increment=%d chars, full_text=%d chars",
 			len(h.buffer), len(newContent), newLen)
@@ -205,20 +233,21 @@ func (h *StreamingTextHandler) onTextDelta(text string) error {
 	now := time.Now()
 	h.lastDataTime = now
 
+	// 超长回答：不再分段发送，等结束时统一投递
+	if h.checkLongAnswer() {
+		return nil
+	}
+
 	// 检查缓冲区是否超过最大限制
 	for len(h.buffer) >= h.maxBufferSize {
 		// 强制分段发送
-		chunk := string(h.buffer[:h.maxBufferSize])
-		h.buffer = h.buffer[h.maxBufferSize:]
+		chunk := h.takeChunk(h.maxBufferSize, false)
+		if chunk == "" {
+			break
+		}
 
 		h.logger.Printf("[Buffer] Max buffer size %d reached, force sending chunk", h.maxBufferSize)
-		h.bufferMu.Unlock() // 临时解锁以发送消息
-		if err := h.sendMessage(chunk); err != nil {
-			h.logger.Printf("[Buffer] Failed to send forced chunk: %v", err)
-			h.bufferMu.Lock()
-			return err
-		}
-		h.bufferMu.Lock()
+		h.enqueueMessage(chunk)
 	}
 
 	// 如果缓冲区不为空且持续时间定时器未启动，启动持续时间定时器
@@ -244,17 +273,10 @@ func (h *StreamingTextHandler) startDurationTimer() {
 		case <-h.durationTimer.C:
 			h.logger.Printf("[DurationTimer] Max duration %v reached, force sending", h.maxDuration)
 			h.bufferMu.Lock()
-			if len(h.buffer) > 0 {
-				chunk := string(h.buffer)
-				h.buffer = make([]rune, 0)
-				h.bufferMu.Unlock()
-
-				if err := h.sendMessage(chunk); err != nil {
-					h.logger.Printf("[DurationTimer] Failed to send: %v", err)
-				}
-			} else {
-				h.bufferMu.Unlock()
+			if chunk := h.takeChunk(0, false); chunk != "" {
+				h.enqueueMessage(chunk)
 			}
+			h.bufferMu.Unlock()
 		case <-h.stopTimers:
 			h.logger.Printf("[DurationTimer] Stopped")
 			return
@@ -277,13 +299,12 @@ func (h *StreamingTextHandler) runIdleTimerGoroutine() {
 				idleTime := time.Since(h.lastDataTime)
 				if idleTime >= h.idleTimeout && len(h.buffer) > 0 {
 					h.logger.Printf("[IdleTimer] Idle timeout %v reached, sending buffer", h.idleTimeout)
-					chunk := string(h.buffer)
-					h.buffer = make([]rune, 0)
+					// 空闲说明一段输出已结束（通常是调用工具前），不保留末尾
+					if chunk := h.takeChunk(0, true); chunk != "" {
+						h.enqueueMessage(chunk)
+					}
 					h.bufferMu.Unlock()
 
-					if err := h.sendMessage(chunk); err != nil {
-						h.logger.Printf("[IdleTimer] Failed to send: %v", err)
-					}
 					// 只在发送后重置定时器
 					timer.Reset(h.idleTimeout)
 				} else {
@@ -317,38 +338,151 @@ func (h *StreamingTextHandler) stopAllTimers() {
 	h.stopTimers = make(chan struct{})
 }
 
-// sendRemaining 发送缓冲区剩余的所有内容
+// sendRemaining 发送缓冲区剩余的所有内容，并等待所有已入队分段发送完成
 func (h *StreamingTextHandler) sendRemaining() error {
 	h.bufferMu.Lock()
-	defer h.bufferMu.Unlock()
-
 	if len(h.buffer) == 0 {
 		h.logger.Printf("No remaining content to send")
-		return nil
+	} else {
+		chunk := h.takeChunk(0, true)
+		h.logger.Printf("Sending remaining content: %d chars", len(chunk))
+		h.enqueueMessage(chunk)
+	}
+	pending := h.pendingSends
+	h.pendingSends = nil
+	deliverLong := h.longMode
+	h.longMode = false
+	h.bufferMu.Unlock()
+
+	// 等待出站队列发送完成，返回第一个失败
+	var firstErr error
+	for _, result := range pending {
+		if err := <-result; err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
 
-	chunk := string(h.buffer)
-	h.logger.Printf("Sending remaining content: %d chars", len(chunk))
-	if err := h.sendMessage(chunk); err != nil {
-		return err
+	if deliverLong {
+		if err := h.deliverLongAnswer(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
+	return firstErr
+}
 
-	// 清空缓冲区
-	h.buffer = make([]rune, 0)
-	return nil
+// checkLongAnswer 检查是否进入超长回答模式（调用方需持有 bufferMu）
+// 回答总长超过阈值时进入，进入后丢弃缓冲区中未发送的内容，返回 true 表示本次不再分段发送
+func (h *StreamingTextHandler) checkLongAnswer() bool {
+	if h.longMode {
+		h.buffer = h.buffer[:0]
+		return true
+	}
+	if h.uploader == nil || h.longAnswerThreshold <= 0 {
+		return false
+	}
+	if len(h.answer) <= h.longAnswerThreshold {
+		return false
+	}
+
+	h.longMode = true
+	h.longModeOffset = len(h.answer) - len(h.buffer)
+	h.buffer = h.buffer[:0]
+	h.logger.Printf("[LongAnswer] Threshold %d exceeded (answer=%d chars, sent=%d chars), switching to %s delivery",
+		h.longAnswerThreshold, len(h.answer), h.longModeOffset, h.uploader.Kind())
+	return true
 }
 
-// sendMessage 发送文本消息到飞书
-func (h *StreamingTextHandler) sendMessage(content string) error {
-	h.logger.Printf("Sending message: len=%d", len(content))
+// deliverLongAnswer 投递完整回答并发送提示；投递失败时回退为分段发送剩余内容
+func (h *StreamingTextHandler) deliverLongAnswer() error {
+	h.bufferMu.Lock()
+	answer, remaining := h.longAnswerText()
+	h.bufferMu.Unlock()
 
-	if err := h.feishuClient.SendMessage(h.receiveID, h.receiveIDType, content); err != nil {
-		h.logger.Printf("Failed to send message: %v", err)
-		return err
+	title := "Claude 回答 " + time.Now().Format("2006-01-02 15:04:05")
+	link, err := h.uploader.Upload(h.receiveID, h.receiveIDType, h.runID, title, answer)
+	if err != nil {
+		h.logger.Printf("[LongAnswer] Upload via %s failed, falling back to chunks: %v", h.uploader.Kind(), err)
+		h.bufferMu.Lock()
+		rest := []rune(remaining)
+		for len(rest) > 0 {
+			size := h.maxBufferSize
+			if size <= 0 || size > len(rest) {
+				size = len(rest)
+			}
+			h.enqueueMessage(string(rest[:size]))
+			rest = rest[size:]
+		}
+		h.bufferMu.Unlock()
+		return h.sendRemaining()
 	}
 
-	h.logger.Printf("Message sent successfully")
-	return nil
+	notice := fmt.Sprintf("📄 回答较长（共 %d 字），后续内容未再分段发送，完整回答已保存为%s", len([]rune(answer)), h.uploader.Kind())
+	if link != "" {
+		notice += "：\n" + link
+	}
+	notice += "\n\n" + answerSummary(answer, longAnswerSummaryRunes)
+	return <-h.feishuClient.EnqueueRedacted(h.receiveID, h.receiveIDType, client.FormatText, notice, h.runID)
+}
+
+// longAnswerText 返回脱敏后的完整回答和进入超长模式后未发送的部分（调用方需持有 bufferMu）
+// 已发送分段的替换次数在 takeChunk 中已计数，这里只计入未发送部分的替换次数
+func (h *StreamingTextHandler) longAnswerText() (answer, remaining string) {
+	answer, _ = redact.Default().Redact(string(h.answer))
+	remaining, n := redact.Default().Redact(string(h.answer[h.longModeOffset:]))
+	h.redactions += n
+	return answer, remaining
+}
+
+// longAnswerSummaryRunes 超长回答提示中附带的开头、结尾摘录长度（字符）
+const longAnswerSummaryRunes = 300
+
+// answerSummary 截取回答的开头和结尾各 n 个字符作为摘要
+func answerSummary(answer string, n int) string {
+	runes := []rune(strings.TrimSpace(answer))
+	if len(runes) <= 2*n {
+		return string(runes)
+	}
+	return fmt.Sprintf("开头：\n%s\n……\n结尾：\n%s", string(runes[:n]), string(runes[len(runes)-n:]))
+}
+
+// redactOverlap 非最终分段在缓冲区末尾保留的字符数：跨分段边界的敏感值与后续内容合并后再脱敏
+const redactOverlap = 256
+
+// takeChunk 对整个缓冲区脱敏后取出下一段（最多 max 个字符，max <= 0 表示不限），调用方需持有 bufferMu
+// final 为 false 时在末尾保留 redactOverlap 个字符，避免敏感值被分段边界切开后逃过脱敏；没有可发送内容时返回空字符串
+func (h *StreamingTextHandler) takeChunk(max int, final bool) string {
+	redacted, n := redact.Default().Redact(string(h.buffer))
+	h.redactions += n
+	runes := []rune(redacted)
+
+	size := len(runes)
+	if !final {
+		size -= redactOverlap
+	}
+	if max > 0 && size > max {
+		size = max
+	}
+	if size <= 0 {
+		h.buffer = runes
+		return ""
+	}
+	h.buffer = runes[size:]
+	return string(runes[:size])
+}
+
+// enqueueMessage 将已脱敏的分段加入出站队列
+// 调用方必须持有 bufferMu：从缓冲区取出分段与入队在同一临界区内完成，保证分段顺序
+func (h *StreamingTextHandler) enqueueMessage(content string) {
+	if h.mentionOpenID != "" && !h.mentioned {
+		mention := fmt.Sprintf("<at user_id=\"%s\"></at>", h.mentionOpenID)
+		if h.format == client.FormatCard {
+			mention = fmt.Sprintf("<at id=%s></at>", h.mentionOpenID)
+		}
+		content = mention + " " + content
+		h.mentioned = true
+	}
+	h.logger.Printf("Enqueue message: len=%d format=%s", len(content), h.format)
+	h.pendingSends = append(h.pendingSends, h.feishuClient.EnqueueRedacted(h.receiveID, h.receiveIDType, h.format, content, h.runID))
 }
 
 // SessionID 返回会话 ID
@@ -356,6 +490,41 @@ func (h *StreamingTextHandler) SessionID() string {
 	return h.lastSessionID
 }
 
+// RunResult 返回最近一次运行的统计信息
+func (h *StreamingTextHandler) RunResult() RunResult {
+	return h.lastResult
+}
+
+// SetRunID 设置当前运行 ID
+func (h *StreamingTextHandler) SetRunID(runID string) {
+	h.runID = runID
+}
+
+// SetMentionUser 设置回复时 @ 的用户（只在第一段回复中 @）
+func (h *StreamingTextHandler) SetMentionUser(openID string) {
+	h.mentionOpenID = openID
+}
+
+// SetAddDirs 设置主项目目录之外允许 Claude 访问的目录
+func (h *StreamingTextHandler) SetAddDirs(dirs []string) {
+	h.addDirs = dirs
+}
+
+// SetClaudeOptions 设置模型、权限模式和追加的系统提示（为空表示使用默认值）
+func (h *StreamingTextHandler) SetClaudeOptions(model, permissionMode, systemPrompt string) {
+	h.model = model
+	h.permissionMode = permissionMode
+	h.systemPrompt = systemPrompt
+}
+
+// SetResponseFormat 设置回复消息格式；卡片有大小限制，同时收紧单段长度
+func (h *StreamingTextHandler) SetResponseFormat(format string) {
+	h.format = format
+	if format == client.FormatCard && h.maxBufferSize > cardMaxBufferSize {
+		h.maxBufferSize = cardMaxBufferSize
+	}
+}
+
 // SetIdleTimeout 设置空闲超时时间
 func (h *StreamingTextHandler) SetIdleTimeout(timeout time.Duration) {
 	h.idleTimeout = timeout
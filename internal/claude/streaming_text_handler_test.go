@@ -0,0 +1,78 @@
+package claude
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeClaudeScript 模拟 Claude CLI：每次调用把参数追加到 $FAKE_CLAUDE_ARGS（一行一次）；
+// 带 --resume 时像会话不存在一样在 stderr 报错退出
+const fakeClaudeScript = `#!/bin/sh
+echo "$*" >> "$FAKE_CLAUDE_ARGS"
+cat > /dev/null
+for arg in "$@"; do
+	if [ "$arg" = "--resume" ]; then
+		echo "No conversation found with session ID: old-session" >&2
+		sleep 0.2
+		exit 1
+	fi
+done
+echo '{"type":"system","subtype":"init","session_id":"new-session"}'
+echo '{"type":"result","subtype":"success","is_error":false,"result":"","session_id":"new-session"}'
+`
+
+func TestResumeRetryKeepsConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake Claude CLI is a shell script")
+	}
+	dir := t.TempDir()
+	cli := filepath.Join(dir, "claude")
+	if err := os.WriteFile(cli, []byte(fakeClaudeScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	argsFile := filepath.Join(dir, "args")
+	t.Setenv("CLAUDE_CLI_PATH", cli)
+	t.Setenv("FAKE_CLAUDE_ARGS", argsFile)
+
+	h := NewStreamingTextHandler(nil)
+	h.logger = log.New(io.Discard, "", 0)
+	h.SetAddDirs([]string{"/extra/dir"})
+	h.SetClaudeOptions("claude-test-model", "plan", "be brief")
+
+	if err := h.HandleMessage(context.Background(), "", "oc_1", "chat_id", "hello", "old-session", dir); err != nil {
+		t.Fatalf("HandleMessage() error: %v", err)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(calls) != 2 {
+		t.Fatalf("claude invoked %d times, want 2 (resume + retry):\n%s", len(calls), data)
+	}
+	if !strings.Contains(calls[0], "--resume old-session") {
+		t.Fatalf("first call %q should resume the session", calls[0])
+	}
+	retry := calls[1]
+	if strings.Contains(retry, "--resume") {
+		t.Fatalf("retry %q should not resume", retry)
+	}
+	for _, want := range []string{"--permission-mode plan", "--model claude-test-model", "--append-system-prompt be brief", "--add-dir /extra/dir"} {
+		if !strings.Contains(retry, want) {
+			t.Errorf("retry %q is missing %q", retry, want)
+		}
+	}
+	if strings.Contains(retry, "--dangerously-skip-permissions") {
+		t.Errorf("retry %q fell back to skipping permissions", retry)
+	}
+	if got := h.SessionID(); got != "new-session" {
+		t.Errorf("SessionID() = %q, want new-session", got)
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 命令权限级别
+const (
+	PermissionUser  = "user"  // 允许访问的用户都可以使用
+	PermissionAdmin = "admin" // 仅管理员可以使用
+)
+
+// CommandChat 普通对话（转发给 Claude）在权限表中的名称
+const CommandChat = "chat"
+
+// AccessConfigFile 访问控制配置文件路径
+const AccessConfigFile = "configs/access_control.json"
+
+// AccessConfig 访问控制配置
+// 用户可以用 open_id / union_id / user_id 标识；部门使用 open_department_id
+// 列表为空表示不限制该项
+type AccessConfig struct {
+	AllowedUsers       []string          `json:"allowed_users,omitempty"`       // 允许使用机器人的用户
+	AllowedDepartments []string          `json:"allowed_departments,omitempty"` // 允许使用机器人的部门（部门成员均可使用）
+	AllowedChats       []string          `json:"allowed_chats,omitempty"`       // 允许使用机器人的群聊
+	AdminUsers         []string          `json:"admin_users,omitempty"`         // 管理员（不受用户/部门白名单限制）
+	CommandPermissions map[string]string `json:"command_permissions,omitempty"` // 命令 -> user / admin（覆盖命令的默认权限）
+}
+
+// Subject 发起请求的用户
+type Subject struct {
+	OpenID        string
+	UnionID       string
+	UserID        string
+	DepartmentIDs []string
+}
+
+// ids 用户的全部标识
+func (s Subject) ids() []string {
+	var ids []string
+	for _, id := range []string{s.OpenID, s.UnionID, s.UserID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// accessStore 访问控制配置文件的缓存（与聊天配置相同，文件被外部修改后自动重新加载）
+var accessStore = &cachedFile[*AccessConfig]{path: AccessConfigFile, decode: decodeAccessConfig}
+
+// LoadAccess 加载访问控制配置：配置文件不存在时只使用环境变量
+// 环境变量 ALLOWED_USERS / ALLOWED_DEPARTMENTS / ALLOWED_CHATS / ADMIN_USERS（逗号分隔）追加到配置文件中的列表
+func LoadAccess() (*AccessConfig, error) {
+	accessStore.mu.Lock()
+	cached, err := accessStore.readLocked()
+	accessStore.mu.Unlock()
+
+	cfg := &AccessConfig{}
+	if err == nil {
+		cfg = cached.clone()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("访问控制配置: %w", err)
+	}
+
+	cfg.AllowedUsers = append(cfg.AllowedUsers, envList("ALLOWED_USERS")...)
+	cfg.AllowedDepartments = append(cfg.AllowedDepartments, envList("ALLOWED_DEPARTMENTS")...)
+	cfg.AllowedChats = append(cfg.AllowedChats, envList("ALLOWED_CHATS")...)
+	cfg.AdminUsers = append(cfg.AdminUsers, envList("ADMIN_USERS")...)
+	return cfg, nil
+}
+
+// decodeAccessConfig 解析访问控制配置文件
+func decodeAccessConfig(data []byte) (*AccessConfig, error) {
+	cfg := &AccessConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("解析访问控制配置失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// clone 深拷贝访问控制配置（LoadAccess 返回的副本追加环境变量时不影响缓存）
+func (cfg *AccessConfig) clone() *AccessConfig {
+	return &AccessConfig{
+		AllowedUsers:       append([]string(nil), cfg.AllowedUsers...),
+		AllowedDepartments: append([]string(nil), cfg.AllowedDepartments...),
+		AllowedChats:       append([]string(nil), cfg.AllowedChats...),
+		AdminUsers:         append([]string(nil), cfg.AdminUsers...),
+		CommandPermissions: cloneMap(cfg.CommandPermissions),
+	}
+}
+
+// UsesDepartments 是否配置了部门白名单（需要查询用户所在部门）
+func (cfg *AccessConfig) UsesDepartments() bool {
+	return len(cfg.AllowedDepartments) > 0
+}
+
+// IsAdmin 是否为管理员；未配置管理员时没有人是管理员（管理员命令全部拒绝）
+func (cfg *AccessConfig) IsAdmin(s Subject) bool {
+	return len(cfg.AdminUsers) > 0 && containsAny(cfg.AdminUsers, s.ids())
+}
+
+// Warnings 启动时提示的不安全配置
+func (cfg *AccessConfig) Warnings() []string {
+	var warnings []string
+	if len(cfg.AllowedUsers) == 0 && len(cfg.AllowedDepartments) == 0 {
+		warnings = append(warnings, "no user or department allowlist configured: every user who can reach the bot may chat with Claude (set ALLOWED_USERS / ALLOWED_DEPARTMENTS)")
+	}
+	if len(cfg.AdminUsers) == 0 {
+		warnings = append(warnings, "no admin users configured: admin commands are disabled (set ADMIN_USERS)")
+	}
+	return warnings
+}
+
+// CheckUser 检查用户是否允许使用机器人，不允许时返回原因
+func (cfg *AccessConfig) CheckUser(s Subject) error {
+	if len(cfg.AllowedUsers) == 0 && len(cfg.AllowedDepartments) == 0 {
+		return nil
+	}
+	if len(cfg.AdminUsers) > 0 && containsAny(cfg.AdminUsers, s.ids()) {
+		return nil
+	}
+	if containsAny(cfg.AllowedUsers, s.ids()) || containsAny(cfg.AllowedDepartments, s.DepartmentIDs) {
+		return nil
+	}
+	return fmt.Errorf("用户不在白名单中")
+}
+
+// CheckChat 检查群聊是否允许使用机器人
+func (cfg *AccessConfig) CheckChat(chatID string) error {
+	if len(cfg.AllowedChats) == 0 || containsAny(cfg.AllowedChats, []string{chatID}) {
+		return nil
+	}
+	return fmt.Errorf("群聊不在白名单中")
+}
+
+// CommandPermission 命令实际所需的权限级别：配置中未覆盖时使用命令声明的 required
+func (cfg *AccessConfig) CommandPermission(command, required string) string {
+	if perm, ok := cfg.CommandPermissions[command]; ok {
+		return perm
+	}
+	return required
+}
+
+// CheckCommand 检查用户是否可以执行命令
+func (cfg *AccessConfig) CheckCommand(command, required string, s Subject) error {
+	if cfg.CommandPermission(command, required) != PermissionAdmin || cfg.IsAdmin(s) {
+		return nil
+	}
+	if len(cfg.AdminUsers) == 0 {
+		return fmt.Errorf("命令 %s 仅管理员可用（未配置管理员）", command)
+	}
+	return fmt.Errorf("命令 %s 仅管理员可用", command)
+}
+
+// containsAny list 中是否包含 values 中的任一值
+func containsAny(list, values []string) bool {
+	for _, item := range list {
+		for _, v := range values {
+			if item == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envList 读取逗号分隔的环境变量
+func envList(name string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
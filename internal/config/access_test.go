@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useAccessFile 让 LoadAccess 读取临时文件（测试结束后恢复）
+func useAccessFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "access_control.json")
+	old := accessStore
+	accessStore = &cachedFile[*AccessConfig]{path: path, decode: decodeAccessConfig}
+	t.Cleanup(func() { accessStore = old })
+	return path
+}
+
+func TestLoadAccessReloadsAndCaches(t *testing.T) {
+	path := useAccessFile(t)
+	for _, key := range []string{"ALLOWED_USERS", "ALLOWED_DEPARTMENTS", "ALLOWED_CHATS", "ADMIN_USERS"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("ADMIN_USERS", "ou_env_admin")
+
+	// 文件不存在时只使用环境变量
+	cfg, err := LoadAccess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.AdminUsers, []string{"ou_env_admin"}) || len(cfg.AllowedUsers) != 0 {
+		t.Fatalf("LoadAccess() without file = %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"allowed_users":["ou_1"],"admin_users":["ou_admin"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		// 多次加载时环境变量不会在缓存上重复追加
+		cfg, err = LoadAccess()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg.AllowedUsers, []string{"ou_1"}) || !reflect.DeepEqual(cfg.AdminUsers, []string{"ou_admin", "ou_env_admin"}) {
+			t.Fatalf("LoadAccess() #%d = %+v", i, cfg)
+		}
+	}
+
+	// 外部修改后重新加载
+	if err := os.WriteFile(path, []byte(`{"allowed_users":["ou_1","ou_2"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadAccess(); err != nil || !reflect.DeepEqual(cfg.AllowedUsers, []string{"ou_1", "ou_2"}) {
+		t.Fatalf("LoadAccess() after change = %+v, %v", cfg, err)
+	}
+
+	// 修改后无法解析时继续使用上一次的内容
+	if err := os.WriteFile(path, []byte(`{"allowed_users":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadAccess(); err != nil || !reflect.DeepEqual(cfg.AllowedUsers, []string{"ou_1", "ou_2"}) {
+		t.Fatalf("LoadAccess() after invalid edit = %+v, %v", cfg, err)
+	}
+}
+
+func TestLoadAccessInvalidOnFirstLoad(t *testing.T) {
+	path := useAccessFile(t)
+	if err := os.WriteFile(path, []byte(`not json`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := LoadAccess(); err == nil {
+		t.Fatalf("LoadAccess() = %+v, want error for invalid file", cfg)
+	}
+}
@@ -1,117 +1,183 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 	"sync"
 )
 
+// 群聊响应策略
+const (
+	MentionPolicyMention = "mention" // 仅 @机器人 时响应
+	MentionPolicyAll     = "all"     // 响应群内所有消息
+	MentionPolicyThread  = "thread"  // @机器人 或在机器人消息的回复/话题中时响应
+)
+
+// DefaultRootName 由 base_dir / BASE_DIR 配置的根目录名称
+const DefaultRootName = "default"
+
+// Root 命名的项目根目录
+type Root struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
 // ChatConfig 聊天配置
 type ChatConfig struct {
-	BaseDir      string            `json:"base_dir"`       // 基础目录（用于 ls 命令）
-	ProjectPaths map[string]string `json:"project_paths"`  // 群聊/聊天 ID -> 项目路径
+	Version      int64             `json:"version"`            // 每次写入递增，便于排查并发修改
+	BaseDir      string            `json:"base_dir,omitempty"` // 基础目录（单个根目录的旧配置，等同于名为 default 的根目录）
+	Roots        []Root            `json:"roots,omitempty"`    // 命名的项目根目录（用于 ls / bind 命令）
+	ProjectAliases map[string]string `json:"project_aliases,omitempty"` // 项目别名 -> 项目路径
+	ProjectPaths map[string]string `json:"project_paths"`  // 旧版：群聊/聊天 ID -> 项目路径（已迁移到运行状态存储，只读）
+	Defaults        *ChatSettings     `json:"defaults,omitempty"`         // 全局设置，会话未设置的项继承这里的值
+	Settings        map[string]*ChatSettings `json:"settings,omitempty"` // 群聊 ID / 单聊用户 open_id -> 会话设置
+	ContextSizes    map[string]int    `json:"context_sizes,omitempty"`    // 群聊 ID -> 附带的最近消息条数
 	mu           sync.RWMutex
 }
 
 // ConfigFile 默认配置文件路径
 const ConfigFile = "configs/chat_config.json"
 
-// Load 加载配置文件
-func Load() (*ChatConfig, error) {
-	cfg := &ChatConfig{
-		ProjectPaths: make(map[string]string),
-	}
+// SetBaseDir 设置基础目录
+func (cfg *ChatConfig) SetBaseDir(baseDir string) error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
 
-	// 优先从环境变量读取基础目录
-	if baseDir := os.Getenv("BASE_DIR"); baseDir != "" {
-		cfg.BaseDir = baseDir
-	}
+	cfg.BaseDir = baseDir
+	return nil
+}
 
-	// 读取配置文件（如果存在，覆盖项目路径映射）
-	data, err := os.ReadFile(ConfigFile)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("读取配置文件失败: %w", err)
-		}
-		// 文件不存在，使用环境变量或默认值
-		if cfg.BaseDir == "" {
-			cfg.BaseDir = "/Users/wen/Desktop/code/"
-		}
-		if err := cfg.Save(); err != nil {
-			return nil, fmt.Errorf("创建默认配置失败: %w", err)
-		}
-		return cfg, nil
+// GetBaseDir 获取基础目录
+func (cfg *ChatConfig) GetBaseDir() string {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.BaseDir
+}
+
+// GetRoots 获取全部项目根目录：base_dir（名为 default）、配置文件中的 roots、
+// 以及环境变量 PROJECT_ROOTS（如 "work=/srv/work,oss=/srv/oss"），名称重复时以先出现的为准
+func (cfg *ChatConfig) GetRoots() []Root {
+	cfg.mu.RLock()
+	candidates := make([]Root, 0, len(cfg.Roots)+1)
+	if cfg.BaseDir != "" {
+		candidates = append(candidates, Root{Name: DefaultRootName, Path: cfg.BaseDir})
 	}
+	candidates = append(candidates, cfg.Roots...)
+	cfg.mu.RUnlock()
 
-	// 解析 JSON（注意：不会覆盖环境变量的 BaseDir）
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	for _, item := range strings.Split(os.Getenv("PROJECT_ROOTS"), ",") {
+		name, path, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if ok && strings.TrimSpace(name) != "" && strings.TrimSpace(path) != "" {
+			candidates = append(candidates, Root{Name: strings.TrimSpace(name), Path: strings.TrimSpace(path)})
+		}
 	}
 
-	// 环境变量优先级高于文件
-	if baseDir := os.Getenv("BASE_DIR"); baseDir != "" {
-		cfg.BaseDir = baseDir
-	} else if cfg.BaseDir == "" {
-		cfg.BaseDir = "/Users/wen/Desktop/code/"
+	seen := make(map[string]bool)
+	var roots []Root
+	for _, root := range candidates {
+		if seen[root.Name] {
+			continue
+		}
+		seen[root.Name] = true
+		roots = append(roots, root)
 	}
+	return roots
+}
 
-	return cfg, nil
+// ExportProjectPaths 导出旧版配置文件中的项目绑定（副本），供首次打开运行状态存储时导入
+func (cfg *ChatConfig) ExportProjectPaths() map[string]string {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cloneMap(cfg.ProjectPaths)
 }
 
-// Save 保存配置文件
-func (cfg *ChatConfig) Save() error {
+// SetProjectAlias 设置项目别名
+func (cfg *ChatConfig) SetProjectAlias(alias, projectPath string) error {
 	cfg.mu.Lock()
 	defer cfg.mu.Unlock()
 
-	// 确保目录存在
-	if err := os.MkdirAll(filepath.Dir(ConfigFile), 0755); err != nil {
-		return fmt.Errorf("创建配置目录失败: %w", err)
-	}
-
-	// 序列化为 JSON（带缩进）
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化配置失败: %w", err)
-	}
-
-	// 写入文件
-	if err := os.WriteFile(ConfigFile, data, 0644); err != nil {
-		return fmt.Errorf("写入配置文件失败: %w", err)
+	if cfg.ProjectAliases == nil {
+		cfg.ProjectAliases = make(map[string]string)
 	}
-
+	cfg.ProjectAliases[alias] = projectPath
 	return nil
 }
 
-// SetBaseDir 设置基础目录
-func (cfg *ChatConfig) SetBaseDir(baseDir string) error {
+// DeleteProjectAlias 删除项目别名，别名不存在时返回 false
+func (cfg *ChatConfig) DeleteProjectAlias(alias string) bool {
 	cfg.mu.Lock()
 	defer cfg.mu.Unlock()
 
-	cfg.BaseDir = baseDir
-	return nil
+	if _, ok := cfg.ProjectAliases[alias]; !ok {
+		return false
+	}
+	delete(cfg.ProjectAliases, alias)
+	return true
 }
 
-// GetBaseDir 获取基础目录
-func (cfg *ChatConfig) GetBaseDir() string {
+// GetProjectAliases 获取全部项目别名（副本）
+func (cfg *ChatConfig) GetProjectAliases() map[string]string {
 	cfg.mu.RLock()
 	defer cfg.mu.RUnlock()
-	return cfg.BaseDir
+
+	aliases := make(map[string]string, len(cfg.ProjectAliases))
+	for alias, path := range cfg.ProjectAliases {
+		aliases[alias] = path
+	}
+	return aliases
+}
+
+// ValidMentionPolicy 是否为合法的响应策略
+func ValidMentionPolicy(policy string) bool {
+	switch policy {
+	case MentionPolicyMention, MentionPolicyAll, MentionPolicyThread:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetMentionPolicy 设置群聊的响应策略（保存在会话设置中）
+func (cfg *ChatConfig) SetMentionPolicy(chatID, policy string) error {
+	if !ValidMentionPolicy(policy) {
+		return fmt.Errorf("无效的响应策略: %s（可选 mention / all / thread）", policy)
+	}
+	return cfg.SetSetting(chatID, "mention_policy", policy)
 }
 
-// SetProjectPath 设置群聊绑定的项目路径
-func (cfg *ChatConfig) SetProjectPath(chatID, projectPath string) error {
+// GetMentionPolicy 获取群聊生效的响应策略（会话设置 > 全局设置 > GROUP_MENTION_POLICY，默认 mention）
+func (cfg *ChatConfig) GetMentionPolicy(chatID string) string {
+	settings, _ := cfg.GetSettings(chatID)
+	return settings.MentionPolicy
+}
+
+// MaxContextSize context 命令允许的最大消息条数（消息列表接口单页上限）
+const MaxContextSize = 50
+
+// SetContextSize 设置群聊附带的最近消息条数（0 表示关闭）
+func (cfg *ChatConfig) SetContextSize(chatID string, size int) error {
+	if size < 0 || size > MaxContextSize {
+		return fmt.Errorf("消息条数需在 0-%d 之间", MaxContextSize)
+	}
+
 	cfg.mu.Lock()
 	defer cfg.mu.Unlock()
 
-	cfg.ProjectPaths[chatID] = projectPath
+	if size == 0 {
+		delete(cfg.ContextSizes, chatID)
+		return nil
+	}
+	if cfg.ContextSizes == nil {
+		cfg.ContextSizes = make(map[string]int)
+	}
+	cfg.ContextSizes[chatID] = size
 	return nil
 }
 
-// GetProjectPath 获取群聊绑定的项目路径
-func (cfg *ChatConfig) GetProjectPath(chatID string) string {
+// GetContextSize 获取群聊附带的最近消息条数（0 表示关闭）
+func (cfg *ChatConfig) GetContextSize(chatID string) int {
 	cfg.mu.RLock()
 	defer cfg.mu.RUnlock()
-	return cfg.ProjectPaths[chatID]
+	return cfg.ContextSizes[chatID]
 }
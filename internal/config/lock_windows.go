@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockfileExclusiveLock LOCKFILE_EXCLUSIVE_LOCK
+const lockfileExclusiveLock = 0x00000002
+
+// lockFile 获取排他文件锁（阻塞直到获得）
+func lockFile(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r1, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile 释放文件锁
+func unlockFile(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r1, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"feishu-bot/internal/utils"
+)
+
+// 回复语言
+const (
+	LanguageAuto = "auto" // 跟随提问语言
+	LanguageZh   = "zh"
+	LanguageEn   = "en"
+	LanguageJa   = "ja"
+)
+
+// 回复消息格式
+const (
+	ResponseModeText = "text" // 纯文本消息
+	ResponseModePost = "post" // 富文本消息（渲染 Markdown）
+	ResponseModeCard = "card" // 消息卡片（渲染 Markdown）
+)
+
+// 会话范围
+const (
+	SessionScopeAuto   = "auto"   // 群聊共享一个全局会话，单聊按用户
+	SessionScopeShared = "shared" // 所有群聊共享一个会话
+	SessionScopeChat   = "chat"   // 每个群聊一个会话
+	SessionScopeUser   = "user"   // 每个用户一个会话（群聊中按 群+用户）
+	SessionScopeNone   = "none"   // 不延续会话，每条消息都是新会话
+)
+
+// Claude 权限配置
+const (
+	PermissionProfileBypass      = "bypass"       // 跳过所有权限确认（--dangerously-skip-permissions）
+	PermissionProfileAcceptEdits = "accept_edits" // 自动接受文件修改（--permission-mode acceptEdits）
+	PermissionProfilePlan        = "plan"         // 只做规划，不修改文件（--permission-mode plan）
+)
+
+// ModelDefault 使用 Claude CLI 的默认模型
+const ModelDefault = "default"
+
+// 流式分段时间的允许范围
+const (
+	minStreamIdleTimeout = time.Second
+	maxStreamIdleTimeout = 5 * time.Minute
+	minStreamMaxDuration = 5 * time.Second
+	maxStreamMaxDuration = 10 * time.Minute
+)
+
+// modelPattern 模型名称（如 sonnet、opus、claude-sonnet-4-5-20250929）
+var modelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:\[\]-]{0,63}$`)
+
+// ChatSettings 会话设置；空字符串表示未设置，继承全局设置（defaults）或内置默认值
+type ChatSettings struct {
+	Language          string `json:"language,omitempty"`            // auto / zh / en / ja
+	ResponseMode      string `json:"response_mode,omitempty"`       // text / post / card
+	StreamIdleTimeout string `json:"stream_idle_timeout,omitempty"` // 如 8s：无新输出多久后发送已缓冲的内容
+	StreamMaxDuration string `json:"stream_max_duration,omitempty"` // 如 20s：连续输出多久后强制分段
+	MentionPolicy     string `json:"mention_policy,omitempty"`      // mention / all / thread（仅群聊）
+	SessionScope      string `json:"session_scope,omitempty"`       // auto / shared / chat / user / none
+	Model             string `json:"model,omitempty"`               // default 或模型名称
+	PermissionProfile string `json:"permission_profile,omitempty"`  // bypass / accept_edits / plan
+}
+
+// SettingSpec 一项设置的说明与校验规则
+type SettingSpec struct {
+	Key         string
+	Description string
+	Values      []string // 可选值（为空表示自由取值，由 validate 校验）
+	GroupOnly   bool     // 仅群聊可用
+	validate    func(value string) error
+	field       func(s *ChatSettings) *string
+}
+
+// settingSpecs 全部设置项（按显示顺序）
+var settingSpecs = []SettingSpec{
+	{
+		Key:         "language",
+		Description: "回复语言",
+		Values:      []string{LanguageAuto, LanguageZh, LanguageEn, LanguageJa},
+		field:       func(s *ChatSettings) *string { return &s.Language },
+	},
+	{
+		Key:         "response_mode",
+		Description: "回复消息格式",
+		Values:      []string{ResponseModeText, ResponseModePost, ResponseModeCard},
+		field:       func(s *ChatSettings) *string { return &s.ResponseMode },
+	},
+	{
+		Key:         "stream_idle_timeout",
+		Description: fmt.Sprintf("无新输出多久后发送已缓冲的内容（%s-%s）", minStreamIdleTimeout, maxStreamIdleTimeout),
+		validate:    durationValidator(minStreamIdleTimeout, maxStreamIdleTimeout),
+		field:       func(s *ChatSettings) *string { return &s.StreamIdleTimeout },
+	},
+	{
+		Key:         "stream_max_duration",
+		Description: fmt.Sprintf("连续输出多久后强制分段（%s-%s）", minStreamMaxDuration, maxStreamMaxDuration),
+		validate:    durationValidator(minStreamMaxDuration, maxStreamMaxDuration),
+		field:       func(s *ChatSettings) *string { return &s.StreamMaxDuration },
+	},
+	{
+		Key:         "mention_policy",
+		Description: "群聊响应策略",
+		Values:      []string{MentionPolicyMention, MentionPolicyAll, MentionPolicyThread},
+		GroupOnly:   true,
+		field:       func(s *ChatSettings) *string { return &s.MentionPolicy },
+	},
+	{
+		Key:         "session_scope",
+		Description: "会话范围（auto: 群聊共享全局会话、单聊按用户；单聊中 shared / chat 等同 user）",
+		Values:      []string{SessionScopeAuto, SessionScopeShared, SessionScopeChat, SessionScopeUser, SessionScopeNone},
+		field:       func(s *ChatSettings) *string { return &s.SessionScope },
+	},
+	{
+		Key:         "model",
+		Description: "Claude 模型（default 为 CLI 默认模型）",
+		validate: func(value string) error {
+			if value != ModelDefault && !modelPattern.MatchString(value) {
+				return fmt.Errorf("无效的模型名称: %s", value)
+			}
+			return nil
+		},
+		field: func(s *ChatSettings) *string { return &s.Model },
+	},
+	{
+		Key:         "permission_profile",
+		Description: "Claude 权限配置",
+		Values:      []string{PermissionProfileBypass, PermissionProfileAcceptEdits, PermissionProfilePlan},
+		field:       func(s *ChatSettings) *string { return &s.PermissionProfile },
+	},
+}
+
+// SettingSpecs 全部设置项
+func SettingSpecs() []SettingSpec {
+	return settingSpecs
+}
+
+// LookupSetting 按名称查找设置项
+func LookupSetting(key string) (SettingSpec, bool) {
+	for _, spec := range settingSpecs {
+		if spec.Key == key {
+			return spec, true
+		}
+	}
+	return SettingSpec{}, false
+}
+
+// Validate 校验设置值
+func (spec SettingSpec) Validate(value string) error {
+	if len(spec.Values) > 0 {
+		for _, v := range spec.Values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s 的值无效: %s（可选 %s）", spec.Key, value, strings.Join(spec.Values, " / "))
+	}
+	if spec.validate != nil {
+		return spec.validate(value)
+	}
+	return nil
+}
+
+// Get 读取设置值（未设置时为空）
+func (spec SettingSpec) Get(s ChatSettings) string {
+	return *spec.field(&s)
+}
+
+// durationValidator 校验时长（如 8s、1m30s）并限制范围
+func durationValidator(min, max time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("无效的时长: %s（示例: 8s、1m30s）", value)
+		}
+		if d < min || d > max {
+			return fmt.Errorf("时长需在 %s-%s 之间", min, max)
+		}
+		return nil
+	}
+}
+
+// BuiltinSettings 内置默认设置（流式分段时间来自 TimeoutConfig，响应策略来自 GROUP_MENTION_POLICY）
+func BuiltinSettings() ChatSettings {
+	timeouts := utils.DefaultTimeoutConfig()
+	return ChatSettings{
+		Language:          LanguageAuto,
+		ResponseMode:      ResponseModeText,
+		StreamIdleTimeout: timeouts.StreamIdleTimeout.String(),
+		StreamMaxDuration: timeouts.StreamMaxDuration.String(),
+		MentionPolicy:     DefaultMentionPolicy(),
+		SessionScope:      SessionScopeAuto,
+		Model:             ModelDefault,
+		PermissionProfile: PermissionProfileBypass,
+	}
+}
+
+// validate 校验已设置的全部值
+func (s *ChatSettings) validate() error {
+	for _, spec := range settingSpecs {
+		if value := *spec.field(s); value != "" {
+			if err := spec.Validate(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IdleTimeout 空闲超时
+func (s ChatSettings) IdleTimeout() time.Duration {
+	d, _ := time.ParseDuration(s.StreamIdleTimeout)
+	return d
+}
+
+// MaxDuration 最大持续时间
+func (s ChatSettings) MaxDuration() time.Duration {
+	d, _ := time.ParseDuration(s.StreamMaxDuration)
+	return d
+}
+
+// 设置值的来源
+const (
+	SettingSourceChat    = "chat"    // 当前会话
+	SettingSourceGlobal  = "global"  // 全局设置（defaults）
+	SettingSourceBuiltin = "builtin" // 内置默认值
+)
+
+// GetSettings 获取会话（群聊 ID 或单聊用户 open_id）的生效设置，以及每项设置的来源
+// 优先级：会话设置 > 全局设置（defaults） > 内置默认值
+func (cfg *ChatConfig) GetSettings(key string) (ChatSettings, map[string]string) {
+	builtin := BuiltinSettings()
+
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+
+	effective := builtin
+	sources := make(map[string]string, len(settingSpecs))
+	for _, spec := range settingSpecs {
+		field := spec.field(&effective)
+		sources[spec.Key] = SettingSourceBuiltin
+		if cfg.Defaults != nil {
+			if value := *spec.field(cfg.Defaults); value != "" {
+				*field = value
+				sources[spec.Key] = SettingSourceGlobal
+			}
+		}
+		if settings := cfg.Settings[key]; settings != nil {
+			if value := *spec.field(settings); value != "" {
+				*field = value
+				sources[spec.Key] = SettingSourceChat
+			}
+		}
+	}
+	return effective, sources
+}
+
+// SetSetting 校验并设置会话的一项设置
+func (cfg *ChatConfig) SetSetting(key, name, value string) error {
+	spec, ok := LookupSetting(name)
+	if !ok {
+		return fmt.Errorf("未知的设置项: %s", name)
+	}
+	if err := spec.Validate(value); err != nil {
+		return err
+	}
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	if cfg.Settings == nil {
+		cfg.Settings = make(map[string]*ChatSettings)
+	}
+	settings := cfg.Settings[key]
+	if settings == nil {
+		settings = &ChatSettings{}
+		cfg.Settings[key] = settings
+	}
+	*spec.field(settings) = value
+	return nil
+}
+
+// ResetSetting 清除会话的一项设置（name 为空时清除全部），恢复为继承值
+func (cfg *ChatConfig) ResetSetting(key, name string) error {
+	var spec SettingSpec
+	if name != "" {
+		var ok bool
+		if spec, ok = LookupSetting(name); !ok {
+			return fmt.Errorf("未知的设置项: %s", name)
+		}
+	}
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	settings := cfg.Settings[key]
+	if settings == nil {
+		return nil
+	}
+	if name == "" {
+		delete(cfg.Settings, key)
+		return nil
+	}
+	*spec.field(settings) = ""
+	if *settings == (ChatSettings{}) {
+		delete(cfg.Settings, key)
+	}
+	return nil
+}
+
+// validateSettings 校验配置文件中的全局设置和会话设置
+func (cfg *ChatConfig) validateSettings() error {
+	if cfg.Defaults != nil {
+		if err := cfg.Defaults.validate(); err != nil {
+			return fmt.Errorf("defaults: %w", err)
+		}
+	}
+	for key, settings := range cfg.Settings {
+		if settings == nil {
+			continue
+		}
+		if err := settings.validate(); err != nil {
+			return fmt.Errorf("settings[%s]: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// DefaultMentionPolicy 全局默认响应策略（GROUP_MENTION_POLICY，默认 mention）
+func DefaultMentionPolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(os.Getenv("GROUP_MENTION_POLICY")))
+	if ValidMentionPolicy(policy) {
+		return policy
+	}
+	return MentionPolicyMention
+}
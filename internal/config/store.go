@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"feishu-bot/internal/utils"
+)
+
+// cachedFile 配置文件的进程内缓存
+// 读取时按文件的修改时间和大小判断是否需要重新加载（热加载外部修改）
+type cachedFile[T any] struct {
+	path   string
+	decode func(data []byte) (T, error)
+
+	mu      sync.Mutex // 串行化本进程内的读写
+	value   T          // 最近一次成功解析的内容（只读，对外返回副本）
+	modTime time.Time  // value 对应的文件修改时间
+	size    int64      // value 对应的文件大小
+	loaded  bool
+}
+
+// readLocked 返回缓存的内容（调用方需持有 mu，且不得修改返回值），文件变化时重新解析；文件不存在时返回 os.ErrNotExist
+// 外部修改导致文件无法解析时继续使用上一次的内容，避免机器人因手工编辑错误而不可用
+func (c *cachedFile[T]) readLocked() (T, error) {
+	var zero T
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return zero, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if c.loaded && info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.value, nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return zero, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	value, err := c.decode(data)
+	if err != nil {
+		if !c.loaded {
+			return zero, err
+		}
+		// 记录本次文件状态，避免每条消息都重复解析和打印同一个错误
+		log.Printf("[Config] Ignoring invalid %s, keeping previous version: %v", c.path, err)
+		c.modTime, c.size = info.ModTime(), info.Size()
+		return c.value, nil
+	}
+
+	if c.loaded {
+		log.Printf("[Config] Reloaded %s after external change", c.path)
+	}
+	c.value, c.modTime, c.size, c.loaded = value, info.ModTime(), info.Size(), true
+	return c.value, nil
+}
+
+// store 聊天配置文件的缓存与写入协调
+// 写入时持有文件锁，基于磁盘上的最新内容修改，并通过临时文件 + 重命名原子替换
+type store struct {
+	cachedFile[*ChatConfig]
+}
+
+// chatStore 聊天配置文件的共享存储
+var chatStore = &store{cachedFile[*ChatConfig]{path: ConfigFile, decode: decodeChatConfig}}
+
+// Load 加载配置：文件未变化时使用缓存（按修改时间和大小判断），文件被外部修改后自动重新加载
+// 返回的是独立副本，修改后需通过 Update 持久化
+func Load() (*ChatConfig, error) {
+	cached, err := chatStore.read()
+	if err != nil {
+		return nil, err
+	}
+	cfg := cached.clone()
+
+	// 环境变量优先级高于文件（只影响运行时，不写回文件）
+	if baseDir := os.Getenv("BASE_DIR"); baseDir != "" {
+		cfg.BaseDir = baseDir
+	}
+	return cfg, nil
+}
+
+// Update 在文件锁内读取磁盘上的最新配置，执行 fn 修改后原子写回，并递增版本号
+// fn 返回错误时不写入；多个实例或并发命令同时修改时不会互相覆盖
+func Update(fn func(cfg *ChatConfig) error) error {
+	return chatStore.update(fn)
+}
+
+// decodeChatConfig 解析并校验配置文件内容（迁移旧版字段）
+func decodeChatConfig(data []byte) (*ChatConfig, error) {
+	cfg := &ChatConfig{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	}
+	if cfg.ProjectPaths == nil {
+		cfg.ProjectPaths = make(map[string]string)
+	}
+
+	if err := cfg.validateSettings(); err != nil {
+		return nil, fmt.Errorf("配置文件中的设置无效: %w", err)
+	}
+	return cfg, nil
+}
+
+// read 返回缓存的配置（调用方不得修改）；文件不存在时创建空配置
+func (s *store) read() (*ChatConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.readLocked()
+	if errors.Is(err, os.ErrNotExist) {
+		if err := s.writeLocked(func(cfg *ChatConfig) error { return nil }); err != nil {
+			return nil, fmt.Errorf("创建默认配置失败: %w", err)
+		}
+		return s.value, nil
+	}
+	return cfg, err
+}
+
+// update 见 Update
+func (s *store) update(fn func(cfg *ChatConfig) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(fn)
+}
+
+// writeLocked 持有文件锁修改配置（调用方需持有 s.mu）
+func (s *store) writeLocked(fn func(cfg *ChatConfig) error) error {
+	return withFileLock(s.path+".lock", func() error {
+		// 以磁盘上的内容为准：其它实例可能刚刚写入过
+		data, err := os.ReadFile(s.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		cfg, err := decodeChatConfig(data)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(cfg); err != nil {
+			return err
+		}
+		cfg.Version++
+
+		cfg.mu.RLock()
+		data, err = json.MarshalIndent(cfg, "", "  ")
+		cfg.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("序列化配置失败: %w", err)
+		}
+		if err := utils.WriteFileAtomic(s.path, data, 0644); err != nil {
+			return fmt.Errorf("写入配置文件失败: %w", err)
+		}
+
+		if info, err := os.Stat(s.path); err == nil {
+			// 缓存副本：fn 可能仍持有 cfg
+			s.value, s.modTime, s.size, s.loaded = cfg.clone(), info.ModTime(), info.Size(), true
+		} else {
+			s.loaded = false
+		}
+		return nil
+	})
+}
+
+// clone 深拷贝配置（Load 返回的副本互不影响，也不影响缓存）
+func (cfg *ChatConfig) clone() *ChatConfig {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+
+	c := &ChatConfig{
+		Version:        cfg.Version,
+		BaseDir:        cfg.BaseDir,
+		Roots:          append([]Root(nil), cfg.Roots...),
+		ProjectAliases: cloneMap(cfg.ProjectAliases),
+		ProjectPaths:   cloneMap(cfg.ProjectPaths),
+		ContextSizes:   cloneMap(cfg.ContextSizes),
+	}
+	if cfg.Defaults != nil {
+		defaults := *cfg.Defaults
+		c.Defaults = &defaults
+	}
+	if cfg.Settings != nil {
+		c.Settings = make(map[string]*ChatSettings, len(cfg.Settings))
+		for key, settings := range cfg.Settings {
+			if settings != nil {
+				copied := *settings
+				settings = &copied
+			}
+			c.Settings[key] = settings
+		}
+	}
+	return c
+}
+
+// cloneMap 复制 map（nil 保持为 nil）
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// withFileLock 持有跨进程的文件锁执行 fn
+func withFileLock(lockPath string, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %w", err)
+	}
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("获取配置文件锁失败: %w", err)
+	}
+	defer unlockFile(f)
+	return fn()
+}
@@ -0,0 +1,86 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"feishu-bot/internal/bot/client"
+)
+
+// Redeliverer 重新投递消息（由 client.FeishuClient 实现）
+type Redeliverer interface {
+	Redeliver(msg *client.OutboundMessage) <-chan error
+}
+
+// Retrier 后台定期重投死信
+type Retrier struct {
+	store       *Store
+	client      Redeliverer
+	interval    time.Duration
+	maxAttempts int
+	ttl         time.Duration
+}
+
+// NewRetrier 创建重投器；超过 maxAttempts 次重投仍失败的死信只能手动重投，
+// 创建超过 ttl 的死信（无论是否还会重投）会被删除，ttl <= 0 表示永久保留
+func NewRetrier(store *Store, redeliverer Redeliverer, interval time.Duration, maxAttempts int, ttl time.Duration) *Retrier {
+	return &Retrier{
+		store:       store,
+		client:      redeliverer,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		ttl:         ttl,
+	}
+}
+
+// Run 定期重投，直到 ctx 结束
+func (r *Retrier) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.retryPending()
+		}
+	}
+}
+
+// retryPending 清理过期死信，然后重投所有未超过重投次数的死信
+func (r *Retrier) retryPending() {
+	if r.ttl > 0 {
+		r.store.Prune(time.Now().Add(-r.ttl))
+	}
+	for _, e := range r.store.List() {
+		if e.Attempts >= r.maxAttempts {
+			continue
+		}
+		if err := r.Redeliver(e.ID); errors.Is(err, ErrInFlight) {
+			continue
+		} else if err != nil {
+			log.Printf("[DeadLetter] Background redeliver failed: id=%s attempts=%d err=%v", e.ID, e.Attempts+1, err)
+		}
+	}
+}
+
+// Redeliver 重投指定死信，成功后从存储中删除
+// 同一条死信同时只有一个重投在进行，另一方返回 ErrInFlight
+func (r *Retrier) Redeliver(id string) error {
+	e, err := r.store.claim(id)
+	if err != nil {
+		return err
+	}
+	defer r.store.release(id)
+
+	if err := <-r.client.Redeliver(e.Message()); err != nil {
+		return err
+	}
+
+	r.store.Remove(id)
+	log.Printf("[DeadLetter] Redelivered: id=%s receive_id=%s run_id=%s", e.ID, e.ReceiveID, e.RunID)
+	return nil
+}
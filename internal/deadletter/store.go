@@ -0,0 +1,235 @@
+package deadletter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"feishu-bot/internal/bot/client"
+	"feishu-bot/internal/utils"
+)
+
+// ErrInFlight 死信正在重投中
+var ErrInFlight = errors.New("死信正在重投中")
+
+// DefaultFile 默认死信文件路径
+const DefaultFile = "data/dead_letters.json"
+
+// Entry 一条发送失败的消息
+type Entry struct {
+	ID            string    `json:"id"`
+	ReceiveID     string    `json:"receive_id"`
+	ReceiveIDType string    `json:"receive_id_type"`
+	MsgType       string    `json:"msg_type"`
+	Content       string    `json:"content"`
+	RunID         string    `json:"run_id,omitempty"`
+	ErrorCode     int       `json:"error_code"`
+	ErrorMessage  string    `json:"error_message"`
+	Attempts      int       `json:"attempts"` // 死信重投次数（不含首次投递）
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Message 转换为可重新投递的出站消息
+func (e *Entry) Message() *client.OutboundMessage {
+	return &client.OutboundMessage{
+		ReceiveID:     e.ReceiveID,
+		ReceiveIDType: e.ReceiveIDType,
+		MsgType:       e.MsgType,
+		Content:       e.Content,
+		RunID:         e.RunID,
+		DeadLetterID:  e.ID,
+	}
+}
+
+// Store 基于 JSON 文件的死信存储，实现 client.DeadLetterSink
+type Store struct {
+	path     string
+	mu       sync.Mutex
+	entries  map[string]*Entry
+	inFlight map[string]bool // 正在重投的死信，避免后台重投和手动重投同时发送同一条
+}
+
+// Open 打开（或创建）死信存储
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		entries:  make(map[string]*Entry),
+		inFlight: make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("读取死信文件失败: %w", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析死信文件失败: %w", err)
+	}
+	for _, e := range entries {
+		s.entries[e.ID] = e
+	}
+	return s, nil
+}
+
+// Add 记录发送失败的消息；重投失败时更新已有记录
+func (s *Store) Add(msg *client.OutboundMessage, sendErr error) {
+	code := 0
+	var feishuErr *client.FeishuError
+	if errors.As(sendErr, &feishuErr) {
+		code = feishuErr.Code
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg.DeadLetterID != "" {
+		if e, ok := s.entries[msg.DeadLetterID]; ok {
+			e.Attempts++
+			e.ErrorCode = code
+			e.ErrorMessage = sendErr.Error()
+			e.UpdatedAt = now
+			s.saveLocked()
+			return
+		}
+	}
+
+	e := &Entry{
+		ID:            newID(),
+		ReceiveID:     msg.ReceiveID,
+		ReceiveIDType: msg.ReceiveIDType,
+		MsgType:       msg.MsgType,
+		Content:       msg.Content,
+		RunID:         msg.RunID,
+		ErrorCode:     code,
+		ErrorMessage:  sendErr.Error(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.entries[e.ID] = e
+	log.Printf("[DeadLetter] Recorded: id=%s receive_id=%s run_id=%s code=%d", e.ID, e.ReceiveID, e.RunID, code)
+	s.saveLocked()
+}
+
+// List 按创建时间返回所有死信
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// Get 获取指定死信
+func (s *Store) Get(id string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// claim 标记死信为正在重投并返回其内容；不存在或已在重投时返回错误
+func (s *Store) claim(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, fmt.Errorf("死信不存在: %s", id)
+	}
+	if s.inFlight[id] {
+		return Entry{}, fmt.Errorf("%w: %s", ErrInFlight, id)
+	}
+	s.inFlight[id] = true
+	return *e, nil
+}
+
+// release 取消正在重投的标记
+func (s *Store) release(id string) {
+	s.mu.Lock()
+	delete(s.inFlight, id)
+	s.mu.Unlock()
+}
+
+// Remove 删除死信（重投成功或手动清理）
+func (s *Store) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return false
+	}
+	delete(s.entries, id)
+	s.saveLocked()
+	return true
+}
+
+// Prune 删除创建时间早于 before 的死信（正在重投的除外），返回删除数量
+func (s *Store) Prune(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, e := range s.entries {
+		if s.inFlight[id] || !e.CreatedAt.Before(before) {
+			continue
+		}
+		delete(s.entries, id)
+		removed++
+		log.Printf("[DeadLetter] Expired: id=%s receive_id=%s run_id=%s attempts=%d", e.ID, e.ReceiveID, e.RunID, e.Attempts)
+	}
+	if removed > 0 {
+		s.saveLocked()
+	}
+	return removed
+}
+
+// saveLocked 持久化到文件（调用方需持有锁，失败只记录日志）
+func (s *Store) saveLocked() {
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		log.Printf("[DeadLetter] Failed to marshal: %v", err)
+		return
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		log.Printf("[DeadLetter] Failed to write file: %v", err)
+	}
+}
+
+func newID() string {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("dl_%d", time.Now().UnixNano())
+	}
+	return "dl_" + hex.EncodeToString(buf)
+}
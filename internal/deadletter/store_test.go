@@ -0,0 +1,224 @@
+package deadletter
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"feishu-bot/internal/bot/client"
+)
+
+// fakeRedeliverer 模拟出站队列：失败时像 Outbox 一样把消息交回死信存储
+type fakeRedeliverer struct {
+	store *Store
+	err   error
+	block chan struct{} // 非 nil 时等待关闭后才返回结果
+	sent  []*client.OutboundMessage
+}
+
+func (f *fakeRedeliverer) Redeliver(msg *client.OutboundMessage) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		if f.block != nil {
+			<-f.block
+		}
+		f.sent = append(f.sent, msg)
+		if f.err != nil {
+			f.store.Add(msg, f.err)
+		}
+		done <- f.err
+	}()
+	return done
+}
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dead_letters.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func addEntry(t *testing.T, s *Store, receiveID string) Entry {
+	t.Helper()
+	s.Add(&client.OutboundMessage{
+		ReceiveID:     receiveID,
+		ReceiveIDType: "chat_id",
+		MsgType:       "text",
+		Content:       `{"text":"hi"}`,
+		RunID:         "run_1",
+	}, &client.FeishuError{Code: 230001, Message: "failed"})
+	entries := s.List()
+	for _, e := range entries {
+		if e.ReceiveID == receiveID {
+			return e
+		}
+	}
+	t.Fatalf("entry for %s not recorded", receiveID)
+	return Entry{}
+}
+
+func TestStorePersists(t *testing.T) {
+	s, path := openTestStore(t)
+	e := addEntry(t, s, "oc_1")
+	if e.ErrorCode != 230001 || e.Attempts != 0 {
+		t.Fatalf("entry = %+v, want code 230001 and 0 attempts", e)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reopened.Get(e.ID)
+	if !ok || got.ReceiveID != "oc_1" || got.Content != e.Content {
+		t.Fatalf("Get(%s) after reopen = %+v, %v", e.ID, got, ok)
+	}
+}
+
+func TestClaimAndRelease(t *testing.T) {
+	s, _ := openTestStore(t)
+	e := addEntry(t, s, "oc_1")
+
+	if _, err := s.claim("dl_missing"); err == nil || errors.Is(err, ErrInFlight) {
+		t.Fatalf("claim(missing) error = %v, want not-found error", err)
+	}
+	if _, err := s.claim(e.ID); err != nil {
+		t.Fatalf("claim() error: %v", err)
+	}
+	if _, err := s.claim(e.ID); !errors.Is(err, ErrInFlight) {
+		t.Fatalf("second claim() error = %v, want ErrInFlight", err)
+	}
+	s.release(e.ID)
+	if _, err := s.claim(e.ID); err != nil {
+		t.Fatalf("claim() after release error: %v", err)
+	}
+}
+
+func TestRedeliverSuccess(t *testing.T) {
+	s, _ := openTestStore(t)
+	e := addEntry(t, s, "oc_1")
+	sender := &fakeRedeliverer{store: s}
+	r := NewRetrier(s, sender, time.Minute, 3, 0)
+
+	if err := r.Redeliver(e.ID); err != nil {
+		t.Fatalf("Redeliver() error: %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].DeadLetterID != e.ID || sender.sent[0].Content != e.Content {
+		t.Fatalf("sent = %+v, want the dead letter's message", sender.sent)
+	}
+	if _, ok := s.Get(e.ID); ok {
+		t.Fatal("entry still stored after successful redelivery")
+	}
+}
+
+func TestRedeliverFailure(t *testing.T) {
+	s, _ := openTestStore(t)
+	e := addEntry(t, s, "oc_1")
+	sender := &fakeRedeliverer{store: s, err: errors.New("send failed")}
+	r := NewRetrier(s, sender, time.Minute, 3, 0)
+
+	if err := r.Redeliver(e.ID); err == nil {
+		t.Fatal("Redeliver() error = nil, want send error")
+	}
+	got, ok := s.Get(e.ID)
+	if !ok || got.Attempts != 1 || got.ErrorMessage != "send failed" {
+		t.Fatalf("entry after failed redelivery = %+v, %v, want 1 attempt", got, ok)
+	}
+	// 失败后释放，可以再次重投
+	if _, err := s.claim(e.ID); err != nil {
+		t.Fatalf("claim() after failed redelivery error: %v", err)
+	}
+}
+
+func TestRedeliverInFlight(t *testing.T) {
+	s, _ := openTestStore(t)
+	e := addEntry(t, s, "oc_1")
+	sender := &fakeRedeliverer{store: s, block: make(chan struct{})}
+	r := NewRetrier(s, sender, time.Minute, 3, 0)
+
+	done := make(chan error, 1)
+	go func() { done <- r.Redeliver(e.ID) }()
+	// 等待第一次重投认领死信
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		claimed := s.inFlight[e.ID]
+		s.mu.Unlock()
+		if claimed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first redelivery did not claim the entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := r.Redeliver(e.ID); !errors.Is(err, ErrInFlight) {
+		t.Fatalf("concurrent Redeliver() error = %v, want ErrInFlight", err)
+	}
+	close(sender.block)
+	if err := <-done; err != nil {
+		t.Fatalf("first Redeliver() error: %v", err)
+	}
+}
+
+func TestRetryPending(t *testing.T) {
+	s, _ := openTestStore(t)
+	fresh := addEntry(t, s, "oc_fresh")
+	exhausted := addEntry(t, s, "oc_exhausted")
+	s.entries[exhausted.ID].Attempts = 3
+	sender := &fakeRedeliverer{store: s, err: errors.New("send failed")}
+	r := NewRetrier(s, sender, time.Minute, 3, 0)
+
+	r.retryPending()
+	if len(sender.sent) != 1 || sender.sent[0].DeadLetterID != fresh.ID {
+		t.Fatalf("sent = %+v, want only %s", sender.sent, fresh.ID)
+	}
+	if got, _ := s.Get(exhausted.ID); got.Attempts != 3 {
+		t.Fatalf("exhausted entry attempts = %d, want 3", got.Attempts)
+	}
+}
+
+func TestRetryPendingPrunesExpired(t *testing.T) {
+	s, path := openTestStore(t)
+	old := addEntry(t, s, "oc_old")
+	recent := addEntry(t, s, "oc_recent")
+	s.entries[old.ID].CreatedAt = time.Now().Add(-48 * time.Hour)
+	s.entries[old.ID].Attempts = 3
+	sender := &fakeRedeliverer{store: s, err: errors.New("send failed")}
+	r := NewRetrier(s, sender, time.Minute, 3, 24*time.Hour)
+
+	r.retryPending()
+	if _, ok := s.Get(old.ID); ok {
+		t.Fatal("expired entry still stored")
+	}
+	if _, ok := s.Get(recent.ID); !ok {
+		t.Fatal("recent entry was pruned")
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := reopened.List(); len(entries) != 1 || entries[0].ID != recent.ID {
+		t.Fatalf("entries after reopen = %+v, want only %s", entries, recent.ID)
+	}
+}
+
+func TestPruneSkipsInFlight(t *testing.T) {
+	s, _ := openTestStore(t)
+	e := addEntry(t, s, "oc_1")
+	if _, err := s.claim(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Prune(time.Now().Add(time.Hour)); n != 0 {
+		t.Fatalf("Prune() = %d, want 0 while in flight", n)
+	}
+	s.release(e.ID)
+	if n := s.Prune(time.Now().Add(time.Hour)); n != 1 {
+		t.Fatalf("Prune() = %d, want 1", n)
+	}
+}
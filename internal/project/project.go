@@ -0,0 +1,240 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultScanDepth 默认向下查找项目的目录层数
+	DefaultScanDepth = 2
+	// defaultMarkers 默认的项目标记文件 / 目录
+	defaultMarkers = ".git,go.mod,package.json"
+)
+
+// skipDirs 查找项目时跳过的目录
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"dist":         true,
+	"build":        true,
+}
+
+// Project 可绑定的项目
+type Project struct {
+	Name string // 相对基础目录的路径（使用 / 分隔）
+	Path string // 绝对路径
+}
+
+// Discover 在基础目录下查找项目：包含标记文件的目录视为项目，不再向下查找
+// 第一层目录下没有找到项目时，该目录本身也作为项目列出
+// 基础目录是符号链接时按实际路径列出，与 Resolve 返回的路径一致
+func Discover(baseDir string, maxDepth int) ([]Project, error) {
+	root, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base dir: %w", err)
+	}
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+	if maxDepth < 1 {
+		maxDepth = 1
+	}
+	markers := Markers()
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []Project
+	for _, entry := range entries {
+		if !entry.IsDir() || skipDir(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		found := scan(root, dir, 1, maxDepth, markers)
+		if len(found) == 0 {
+			found = []Project{newProject(root, dir)}
+		}
+		projects = append(projects, found...)
+	}
+
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// scan 递归查找 dir 下的项目（depth 为 dir 相对基础目录的层数）
+func scan(root, dir string, depth, maxDepth int, markers []string) []Project {
+	if hasMarker(dir, markers) {
+		return []Project{newProject(root, dir)}
+	}
+	if depth >= maxDepth {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var projects []Project
+	for _, entry := range entries {
+		if entry.IsDir() && !skipDir(entry.Name()) {
+			projects = append(projects, scan(root, filepath.Join(dir, entry.Name()), depth+1, maxDepth, markers)...)
+		}
+	}
+	return projects
+}
+
+// hasMarker 目录中是否存在任一标记文件 / 目录
+func hasMarker(dir string, markers []string) bool {
+	for _, marker := range markers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDir 隐藏目录和依赖、构建产物目录不作为项目
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || skipDirs[name]
+}
+
+func newProject(root, dir string) Project {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		rel = filepath.Base(dir)
+	}
+	return Project{Name: filepath.ToSlash(rel), Path: dir}
+}
+
+// Resolve 将相对基础目录的路径（或基础目录内的绝对路径）解析为实际的绝对路径（已解析符号链接），
+// 并确认它是基础目录内已存在的目录
+// 拒绝 .. 越界、通过符号链接指向基础目录之外的路径，以及基础目录本身（绑定基础目录等于放开其中所有项目）
+func Resolve(baseDir, relPath string) (string, error) {
+	root, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid base dir: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("基础目录不可用: %w", err)
+	}
+
+	// 也接受基础目录内的绝对路径
+	target := filepath.Clean(relPath)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, filepath.FromSlash(relPath))
+	}
+	if !within(root, target) && !within(realRoot, target) {
+		return "", fmt.Errorf("路径超出基础目录: %s", relPath)
+	}
+
+	realTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("目录不存在: %s", relPath)
+		}
+		return "", fmt.Errorf("无法访问目录: %w", err)
+	}
+	if !within(realRoot, realTarget) {
+		return "", fmt.Errorf("路径通过符号链接指向基础目录之外: %s", relPath)
+	}
+	if realTarget == realRoot {
+		return "", fmt.Errorf("不能绑定基础目录本身，请指定其中的项目: %s", relPath)
+	}
+
+	info, err := os.Stat(realTarget)
+	if err != nil {
+		return "", fmt.Errorf("无法访问目录: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("不是目录: %s", relPath)
+	}
+	return realTarget, nil
+}
+
+// within 判断 path 是否在 root 之内（含 root 本身）
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Match 按名称查找项目：完整路径或目录名完全匹配 > 包含 > 按顺序包含全部字符（模糊匹配）
+// 返回最高一级匹配到的全部项目
+func Match(projects []Project, query string) []Project {
+	query = strings.ToLower(strings.Trim(filepath.ToSlash(query), "/"))
+	if query == "" {
+		return nil
+	}
+
+	var exact, contains, fuzzy []Project
+	for _, p := range projects {
+		name := strings.ToLower(p.Name)
+		base := strings.ToLower(filepath.Base(p.Path))
+		switch {
+		case name == query || base == query:
+			exact = append(exact, p)
+		case strings.Contains(name, query):
+			contains = append(contains, p)
+		case subsequence(name, query):
+			fuzzy = append(fuzzy, p)
+		}
+	}
+	switch {
+	case len(exact) > 0:
+		return exact
+	case len(contains) > 0:
+		return contains
+	default:
+		return fuzzy
+	}
+}
+
+// subsequence query 的字符是否按顺序出现在 s 中
+func subsequence(s, query string) bool {
+	rs := []rune(s)
+	i := 0
+	for _, q := range query {
+		for i < len(rs) && rs[i] != q {
+			i++
+		}
+		if i == len(rs) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// Markers 项目标记文件 / 目录（PROJECT_MARKERS，逗号分隔）
+func Markers() []string {
+	value := os.Getenv("PROJECT_MARKERS")
+	if strings.TrimSpace(value) == "" {
+		value = defaultMarkers
+	}
+	var markers []string
+	for _, m := range strings.Split(value, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			markers = append(markers, m)
+		}
+	}
+	return markers
+}
+
+// ScanDepth 向下查找项目的目录层数（PROJECT_SCAN_DEPTH）
+func ScanDepth() int {
+	if value, err := strconv.Atoi(os.Getenv("PROJECT_SCAN_DEPTH")); err == nil && value > 0 {
+		return value
+	}
+	return DefaultScanDepth
+}
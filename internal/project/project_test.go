@@ -0,0 +1,103 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTree 创建测试目录：
+//
+//	tmp/base/app      项目
+//	tmp/base/escape   -> tmp/outside（指向基础目录之外的符号链接）
+//	tmp/base/inner    -> tmp/base/app（基础目录内的符号链接）
+//	tmp/base/file.txt 普通文件
+//	tmp/outside       基础目录之外的目录
+//	tmp/link          -> tmp/base（符号链接形式的基础目录）
+func newTree(t *testing.T) (tmp, base string) {
+	t.Helper()
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	base = filepath.Join(tmp, "base")
+	for _, dir := range []string{filepath.Join(base, "app"), filepath.Join(tmp, "outside")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		filepath.Join(base, "escape"): filepath.Join(tmp, "outside"),
+		filepath.Join(base, "inner"):  filepath.Join(base, "app"),
+		filepath.Join(tmp, "link"):    base,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return tmp, base
+}
+
+func TestResolve(t *testing.T) {
+	tmp, base := newTree(t)
+	app := filepath.Join(base, "app")
+
+	tests := []struct {
+		name    string
+		baseDir string
+		path    string
+		want    string // 为空表示应返回错误
+	}{
+		{"relative", base, "app", app},
+		{"relative with slash", base, "app/", app},
+		{"absolute inside", base, app, app},
+		{"dot is the base dir itself", base, ".", ""},
+		{"empty is the base dir itself", base, "", ""},
+		{"parent", base, "..", ""},
+		{"parent then sibling", base, "../outside", ""},
+		{"parent inside path", base, "app/../../outside", ""},
+		{"absolute outside", base, filepath.Join(tmp, "outside"), ""},
+		{"symlink escape", base, "escape", ""},
+		{"symlink inside resolves to target", base, "inner", app},
+		{"missing", base, "missing", ""},
+		{"not a directory", base, "file.txt", ""},
+		{"symlinked base relative", filepath.Join(tmp, "link"), "app", app},
+		{"symlinked base absolute via link", filepath.Join(tmp, "link"), filepath.Join(tmp, "link", "app"), app},
+		{"symlinked base absolute via real path", filepath.Join(tmp, "link"), app, app},
+		{"symlinked base escape", filepath.Join(tmp, "link"), "escape", ""},
+		{"symlinked base dot", filepath.Join(tmp, "link"), ".", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.baseDir, tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("Resolve(%q, %q) = %q, want error", tt.baseDir, tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q, %q) error: %v", tt.baseDir, tt.path, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve(%q, %q) = %q, want %q", tt.baseDir, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverSymlinkedBase(t *testing.T) {
+	tmp, base := newTree(t)
+
+	projects, err := Discover(filepath.Join(tmp, "link"), DefaultScanDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].Name != "app" || projects[0].Path != filepath.Join(base, "app") {
+		t.Fatalf("Discover() = %+v, want only app under the real base dir", projects)
+	}
+}
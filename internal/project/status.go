@@ -0,0 +1,157 @@
+package project
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gitTimeout     = 5 * time.Second  // 单个 git 命令的超时
+	inspectWorkers = 8                // 并发检查的项目数
+	activityTTL    = 30 * time.Second // 最近活动时间的缓存时长（连续翻页、过滤时不重复执行 git log）
+)
+
+// Status 项目的 git 状态与最近活动时间
+type Status struct {
+	Git          bool      // 是否为 git 仓库
+	Branch       string    // 当前分支（detached HEAD 时为 "(detached)"）
+	Dirty        bool      // 是否有未提交的改动
+	LastActivity time.Time // 最后一次提交时间；非 git 项目为目录修改时间
+}
+
+// Inspect 获取项目状态；git 不可用或命令失败时退化为目录修改时间
+func Inspect(path string) Status {
+	status := inspectGit(path)
+	status.LastActivity = LastActivity(path)
+	return status
+}
+
+// inspectGit 获取项目的 git 分支和改动状态（不含最近活动时间）
+func inspectGit(path string) Status {
+	var status Status
+	out, err := runGit(path, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		return status
+	}
+	status.Git = true
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			status.Branch = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "#"):
+		case line != "":
+			status.Dirty = true
+		}
+	}
+	return status
+}
+
+// LastActivity 最后一次提交时间；不是 git 仓库或没有提交时为目录修改时间
+func LastActivity(path string) time.Time {
+	if out, err := runGit(path, "log", "-1", "--format=%ct"); err == nil {
+		if sec, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64); err == nil {
+			return time.Unix(sec, 0)
+		}
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// InspectAll 并发获取多个项目的状态，返回 路径 -> 状态
+// activity 为已经获取的最近活动时间（如排序时 LastActivities 的结果），其中没有的项目再单独获取
+func InspectAll(paths []string, activity map[string]time.Time) map[string]Status {
+	return forEach(paths, func(path string) Status {
+		status := inspectGit(path)
+		if at, ok := activity[path]; ok {
+			status.LastActivity = at
+		} else {
+			status.LastActivity = cachedLastActivity(path)
+		}
+		return status
+	})
+}
+
+// LastActivities 并发获取多个项目的最近活动时间（缓存 activityTTL），返回 路径 -> 时间
+func LastActivities(paths []string) map[string]time.Time {
+	return forEach(paths, cachedLastActivity)
+}
+
+// activityEntry 最近活动时间缓存项
+type activityEntry struct {
+	at        time.Time
+	expiresAt time.Time
+}
+
+// activityCache 路径 -> 最近活动时间
+var activityCache = struct {
+	mu      sync.Mutex
+	entries map[string]activityEntry
+}{entries: make(map[string]activityEntry)}
+
+// cachedLastActivity 带缓存的 LastActivity
+func cachedLastActivity(path string) time.Time {
+	now := time.Now()
+	activityCache.mu.Lock()
+	entry, ok := activityCache.entries[path]
+	activityCache.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.at
+	}
+
+	at := LastActivity(path)
+	activityCache.mu.Lock()
+	// 顺带清理过期项，避免缓存无限增长
+	for p, e := range activityCache.entries {
+		if now.After(e.expiresAt) {
+			delete(activityCache.entries, p)
+		}
+	}
+	activityCache.entries[path] = activityEntry{at: at, expiresAt: now.Add(activityTTL)}
+	activityCache.mu.Unlock()
+	return at
+}
+
+// forEach 以有限的并发对每个路径执行 fn
+func forEach[T any](paths []string, fn func(string) T) map[string]T {
+	result := make(map[string]T, len(paths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, inspectWorkers)
+
+	for _, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			value := fn(path)
+			mu.Lock()
+			result[path] = value
+			mu.Unlock()
+		}(path)
+	}
+	wg.Wait()
+	return result
+}
+
+// runGit 在目录中执行 git 命令
+func runGit(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	// 禁止交互式凭证提示并跳过可选锁，避免命令挂起或与正在运行的 git 抢锁
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_OPTIONAL_LOCKS=0")
+	out, err := cmd.Output()
+	return string(out), err
+}
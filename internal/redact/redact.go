@@ -0,0 +1,222 @@
+// Package redact 对出站消息和日志中的敏感信息（密钥、令牌、私钥等）做脱敏替换
+package redact
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"feishu-bot/internal/utils"
+)
+
+// Placeholder 替换敏感内容的占位文本
+const Placeholder = "[REDACTED]"
+
+// DefaultPatternsFile 默认的自定义规则文件（每行一个正则，# 开头为注释）
+const DefaultPatternsFile = "configs/redact_patterns.txt"
+
+// minSecretLen 环境变量值短于此长度时不作为敏感值（避免误伤常见短字符串）
+const minSecretLen = 8
+
+// builtinPatterns 常见凭证格式
+var builtinPatterns = []string{
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`, // PEM 私钥
+	`sk-ant-[A-Za-z0-9_\-]{20,}`,                                         // Anthropic
+	`\bsk-[A-Za-z0-9_\-]{20,}`,                                           // OpenAI 等
+	`\bAKIA[0-9A-Z]{16}\b`,                                               // AWS Access Key ID
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                                     // GitHub Token
+	`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,                                   // GitHub fine-grained token
+	`\bxox[abprs]-[A-Za-z0-9\-]{10,}`,                                    // Slack Token
+	`\bAIza[0-9A-Za-z_\-]{35}\b`,                                         // Google API Key
+	`\b[tu]-g[0-9a-z]{3}[A-Za-z0-9_]{28,}\b`,                             // 飞书 tenant/user access token
+	`\beyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`, // JWT
+}
+
+// assignmentPattern key=value 形式的敏感赋值（保留 key，只替换 value）
+var assignmentPattern = regexp.MustCompile(`(?i)\b([A-Z0-9_]*(?:SECRET|PASSWORD|PASSWD|TOKEN|API_KEY|APIKEY|PRIVATE_KEY|ACCESS_KEY)[A-Z0-9_]*)(\s*[=:]\s*)("?)([^\s"'\\` + "`" + `]{6,})`)
+
+// sensitiveEnvName 名称看起来是凭证的环境变量
+var sensitiveEnvName = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|API_KEY|APIKEY|PRIVATE_KEY|ACCESS_KEY|CREDENTIAL)`)
+
+// Redactor 脱敏器
+type Redactor struct {
+	mu       sync.RWMutex
+	patterns []*regexp.Regexp
+	values   []string // 已知敏感值（按长度降序，优先替换长串）
+}
+
+// New 创建脱敏器：内置凭证格式 + 自定义正则 + 已知敏感值
+func New(extraPatterns []string, values []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range append(append([]string(nil), builtinPatterns...), extraPatterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的脱敏规则 %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, v := range values {
+		r.AddValue(v)
+	}
+	return r, nil
+}
+
+// AddValue 添加一个需要脱敏的具体值（如运行时获取的 tenant token）
+func (r *Redactor) AddValue(value string) {
+	value = strings.TrimSpace(value)
+	if len(value) < minSecretLen {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.values {
+		if v == value {
+			return
+		}
+	}
+	r.values = append(r.values, value)
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// Redact 返回脱敏后的文本和替换次数
+func (r *Redactor) Redact(text string) (string, int) {
+	if text == "" {
+		return text, 0
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, v := range r.values {
+		if n := strings.Count(text, v); n > 0 {
+			count += n
+			text = strings.ReplaceAll(text, v, Placeholder)
+		}
+	}
+	for _, re := range r.patterns {
+		text = re.ReplaceAllStringFunc(text, func(string) string {
+			count++
+			return Placeholder
+		})
+	}
+	text = assignmentPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := assignmentPattern.FindStringSubmatch(match)
+		if parts[4] == Placeholder {
+			return match
+		}
+		count++
+		return parts[1] + parts[2] + parts[3] + Placeholder
+	})
+	return text, count
+}
+
+// String 返回脱敏后的文本
+func (r *Redactor) String(text string) string {
+	out, _ := r.Redact(text)
+	return out
+}
+
+var (
+	defaultRedactor     *Redactor
+	defaultRedactorOnce sync.Once
+)
+
+// Default 返回全局脱敏器
+// 规则来源：内置凭证格式、REDACT_PATTERNS_FILE（默认 configs/redact_patterns.txt）、
+// 名称像凭证的环境变量的值，以及 REDACT_ENV_VARS 中额外列出的环境变量的值
+func Default() *Redactor {
+	// 初始化过程中不能写日志：日志输出本身经过脱敏器，会在 Once 内重入
+	var initErr error
+	defaultRedactorOnce.Do(func() {
+		patterns, err := loadPatternsFile(utils.GetEnvOrDefault("REDACT_PATTERNS_FILE", DefaultPatternsFile))
+		if err != nil {
+			initErr = fmt.Errorf("读取脱敏规则文件失败: %w", err)
+		}
+
+		r, err := New(patterns, sensitiveEnvValues())
+		if err != nil {
+			initErr = fmt.Errorf("%w，仅使用内置规则", err)
+			r, _ = New(nil, sensitiveEnvValues())
+		}
+		defaultRedactor = r
+	})
+	if initErr != nil {
+		log.Printf("[Redact] %v", initErr)
+	}
+	return defaultRedactor
+}
+
+// String 使用全局脱敏器处理文本
+func String(text string) string {
+	return Default().String(text)
+}
+
+// sensitiveEnvValues 收集敏感环境变量的值
+func sensitiveEnvValues() []string {
+	extra := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("REDACT_ENV_VARS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			extra[name] = true
+		}
+	}
+
+	var values []string
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if sensitiveEnvName.MatchString(parts[0]) || extra[parts[0]] {
+			values = append(values, parts[1])
+		}
+	}
+	return values
+}
+
+// loadPatternsFile 读取自定义规则文件（文件不存在时返回空）
+func loadPatternsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// writer 对写入内容脱敏的 io.Writer（用于日志输出）
+type writer struct {
+	out io.Writer
+}
+
+// NewWriter 包装 io.Writer，写入前脱敏
+func NewWriter(out io.Writer) io.Writer {
+	return &writer{out: out}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
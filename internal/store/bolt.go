@@ -0,0 +1,442 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bbolt bucket 名称
+var (
+	bucketMeta     = []byte("meta")
+	bucketSessions = []byte("sessions")
+	bucketBindings = []byte("bindings")
+	bucketDedup    = []byte("dedup")
+	bucketMessages = []byte("messages")
+	bucketRuns     = []byte("runs")
+	bucketUsage    = []byte("usage")
+
+	boltBuckets = [][]byte{bucketMeta, bucketSessions, bucketBindings, bucketDedup, bucketMessages, bucketRuns, bucketUsage}
+)
+
+// keySchemaVersion meta bucket 中的 schema 版本
+var keySchemaVersion = []byte("schema_version")
+
+// boltOpenTimeout 等待数据库文件锁的时长（另一个实例正在使用时打开失败，而不是一直阻塞）
+const boltOpenTimeout = 3 * time.Second
+
+// boltStore 基于 bbolt 的存储：按记录读写，每次修改一个事务
+type boltStore struct {
+	db *bolt.DB
+}
+
+// openBolt 打开（或创建）bbolt 数据库并创建全部 bucket
+func openBolt(path string) (*boltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("打开数据库 %s 超时（是否有其它实例正在使用？）", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+// SchemaVersion 当前 schema 版本（读取失败时视为 0）
+func (s *boltStore) SchemaVersion() int {
+	version := 0
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		version, _ = strconv.Atoi(string(tx.Bucket(bucketMeta).Get(keySchemaVersion)))
+		return nil
+	})
+	return version
+}
+
+func (s *boltStore) setSchemaVersion(version int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte(strconv.Itoa(version)))
+	})
+}
+
+// GetSession 获取会话 ID
+func (s *boltStore) GetSession(key string) (string, error) {
+	var sessionID string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sessionID = string(tx.Bucket(bucketSessions).Get([]byte(key)))
+		return nil
+	})
+	return sessionID, err
+}
+
+// SetSession 保存会话 ID
+func (s *boltStore) SetSession(key, sessionID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).Put([]byte(key), []byte(sessionID))
+	})
+}
+
+// DeleteSession 删除会话
+func (s *boltStore) DeleteSession(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).Delete([]byte(key))
+	})
+}
+
+// GetBinding 获取会话绑定
+func (s *boltStore) GetBinding(key string) (Binding, bool, error) {
+	b := Binding{Key: key}
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = getJSON(tx.Bucket(bucketBindings), key, &b)
+		return err
+	})
+	return b, found, err
+}
+
+// ListBindings 列出全部绑定
+func (s *boltStore) ListBindings() ([]Binding, error) {
+	var bindings []Binding
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketBindings).ForEach(func(k, v []byte) error {
+			var b Binding
+			if err := json.Unmarshal(v, &b); err != nil {
+				return fmt.Errorf("解析绑定 %s 失败: %w", k, err)
+			}
+			bindings = append(bindings, b)
+			return nil
+		})
+	})
+	sortBindings(bindings)
+	return bindings, err
+}
+
+// UpdateBinding 在同一事务中读取、修改并保存会话绑定
+func (s *boltStore) UpdateBinding(key string, fn func(b *Binding) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketBindings)
+		b := Binding{Key: key}
+		if _, err := getJSON(bucket, key, &b); err != nil {
+			return err
+		}
+		if err := fn(&b); err != nil {
+			return err
+		}
+		b.Key = key
+		return putJSON(bucket, key, b)
+	})
+}
+
+// MarkSeen 记录消息 ID，窗口内重复时返回 true（过期记录由 PruneDedup 清理）
+func (s *boltStore) MarkSeen(messageID string, now time.Time, window time.Duration) (bool, error) {
+	duplicate := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketDedup)
+		if seen, ok := decodeTime(bucket.Get([]byte(messageID))); ok && now.Sub(seen) < window {
+			duplicate = true
+			return nil
+		}
+		return bucket.Put([]byte(messageID), encodeTime(now))
+	})
+	return duplicate, err
+}
+
+// PruneDedup 删除 before 之前的去重记录
+func (s *boltStore) PruneDedup(before time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteWhere(tx.Bucket(bucketDedup), func(v []byte) bool {
+			seen, ok := decodeTime(v)
+			return !ok || seen.Before(before)
+		})
+	})
+}
+
+// LinkMessage 记录消息所属的运行
+func (s *boltStore) LinkMessage(messageID string, link MessageLink) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketMessages), messageID, link)
+	})
+}
+
+// LookupMessage 查找消息所属的运行
+func (s *boltStore) LookupMessage(messageID string) (MessageLink, bool, error) {
+	var link MessageLink
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = getJSON(tx.Bucket(bucketMessages), messageID, &link)
+		return err
+	})
+	return link, found, err
+}
+
+// SaveRun 保存运行记录
+func (s *boltStore) SaveRun(run Run) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketRuns), run.ID, run)
+	})
+}
+
+// GetRun 获取运行记录
+func (s *boltStore) GetRun(runID string) (Run, bool, error) {
+	var run Run
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = getJSON(tx.Bucket(bucketRuns), runID, &run)
+		return err
+	})
+	return run, found, err
+}
+
+// ListRuns 列出 since 之后更新的运行
+func (s *boltStore) ListRuns(since time.Time) ([]Run, error) {
+	var runs []Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRuns).ForEach(func(k, v []byte) error {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("解析运行记录 %s 失败: %w", k, err)
+			}
+			if !run.UpdatedAt.Before(since) {
+				runs = append(runs, run)
+			}
+			return nil
+		})
+	})
+	sortRuns(runs)
+	return runs, err
+}
+
+// PruneRuns 删除 before 之前的运行记录和消息对应关系
+func (s *boltStore) PruneRuns(before time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := deleteWhere(tx.Bucket(bucketMessages), func(v []byte) bool {
+			var link MessageLink
+			return json.Unmarshal(v, &link) != nil || link.CreatedAt.Before(before)
+		})
+		if err != nil {
+			return err
+		}
+		return deleteWhere(tx.Bucket(bucketRuns), func(v []byte) bool {
+			var run Run
+			return json.Unmarshal(v, &run) != nil || run.UpdatedAt.Before(before)
+		})
+	})
+}
+
+// AddUsage 累加用量（读取和写回在同一事务中）
+func (s *boltStore) AddUsage(delta UsageRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUsage)
+		key := usageKey(delta.Day, delta.ChatID)
+		record := UsageRecord{Day: delta.Day, ChatID: delta.ChatID}
+		if _, err := getJSON(bucket, key, &record); err != nil {
+			return err
+		}
+		record.add(delta)
+		return putJSON(bucket, key, record)
+	})
+}
+
+// ListUsage 列出 since 之后的用量记录（key 以日期开头，直接从 since 开始遍历）
+func (s *boltStore) ListUsage(since string) ([]UsageRecord, error) {
+	var records []UsageRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketUsage).Cursor()
+		for k, v := c.Seek([]byte(since)); k != nil; k, v = c.Next() {
+			var record UsageRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("解析用量记录 %s 失败: %w", k, err)
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	sortUsage(records)
+	return records, err
+}
+
+// Export 在一个只读事务中导出全部数据
+func (s *boltStore) Export() (*Snapshot, error) {
+	snapshot := newSnapshot(DriverBolt, s.SchemaVersion())
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketSessions).ForEach(func(k, v []byte) error {
+			snapshot.Sessions[string(k)] = string(v)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketBindings).ForEach(func(k, v []byte) error {
+			var b Binding
+			if err := json.Unmarshal(v, &b); err != nil {
+				return fmt.Errorf("解析绑定 %s 失败: %w", k, err)
+			}
+			snapshot.Bindings = append(snapshot.Bindings, b)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketDedup).ForEach(func(k, v []byte) error {
+			if seen, ok := decodeTime(v); ok {
+				snapshot.Dedup[string(k)] = seen
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketMessages).ForEach(func(k, v []byte) error {
+			var link MessageLink
+			if err := json.Unmarshal(v, &link); err != nil {
+				return fmt.Errorf("解析消息记录 %s 失败: %w", k, err)
+			}
+			snapshot.Messages[string(k)] = link
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketRuns).ForEach(func(k, v []byte) error {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("解析运行记录 %s 失败: %w", k, err)
+			}
+			snapshot.Runs = append(snapshot.Runs, run)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketUsage).ForEach(func(k, v []byte) error {
+			var record UsageRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("解析用量记录 %s 失败: %w", k, err)
+			}
+			snapshot.Usage = append(snapshot.Usage, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortBindings(snapshot.Bindings)
+	sortRuns(snapshot.Runs)
+	sortUsage(snapshot.Usage)
+	return snapshot, nil
+}
+
+// Import 在一个事务中导入快照（失败时不写入任何记录）
+func (s *boltStore) Import(snapshot *Snapshot) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for k, v := range snapshot.Sessions {
+			if err := tx.Bucket(bucketSessions).Put([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		for _, b := range snapshot.Bindings {
+			if err := putJSON(tx.Bucket(bucketBindings), b.Key, b); err != nil {
+				return err
+			}
+		}
+		for k, v := range snapshot.Dedup {
+			if err := tx.Bucket(bucketDedup).Put([]byte(k), encodeTime(v)); err != nil {
+				return err
+			}
+		}
+		for k, v := range snapshot.Messages {
+			if err := putJSON(tx.Bucket(bucketMessages), k, v); err != nil {
+				return err
+			}
+		}
+		for _, run := range snapshot.Runs {
+			if err := putJSON(tx.Bucket(bucketRuns), run.ID, run); err != nil {
+				return err
+			}
+		}
+		for _, record := range snapshot.Usage {
+			if err := putJSON(tx.Bucket(bucketUsage), usageKey(record.Day, record.ChatID), record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close 关闭数据库（释放文件锁）
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// putJSON 以 JSON 保存记录
+func putJSON(bucket *bolt.Bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), raw)
+}
+
+// getJSON 读取 JSON 记录，不存在时返回 false
+func getJSON(bucket *bolt.Bucket, key string, v interface{}) (bool, error) {
+	raw := bucket.Get([]byte(key))
+	if raw == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, fmt.Errorf("解析记录 %s 失败: %w", key, err)
+	}
+	return true, nil
+}
+
+// deleteWhere 删除值满足条件的记录（先收集再删除，遍历时不能修改 bucket）
+func deleteWhere(bucket *bolt.Bucket, match func(v []byte) bool) error {
+	var keys [][]byte
+	if err := bucket.ForEach(func(k, v []byte) error {
+		if match(v) {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTime 时间以 RFC3339Nano 文本保存
+func encodeTime(t time.Time) []byte {
+	return []byte(t.Format(time.RFC3339Nano))
+}
+
+// decodeTime 解析 encodeTime 保存的时间
+func decodeTime(raw []byte) (time.Time, bool) {
+	if raw == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(raw))
+	return t, err == nil
+}
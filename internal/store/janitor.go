@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunJanitor 定期清理过期的运行记录（runTTL）和去重记录（dedupTTL），直到 ctx 结束
+// 用量记录按天汇总、数据量小，不清理
+func RunJanitor(ctx context.Context, s Store, interval, runTTL, dedupTTL time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if runTTL > 0 {
+			if err := s.PruneRuns(now.Add(-runTTL)); err != nil {
+				log.Printf("[Store] Failed to prune runs: %v", err)
+			}
+		}
+		if dedupTTL > 0 {
+			if err := s.PruneDedup(now.Add(-dedupTTL)); err != nil {
+				log.Printf("[Store] Failed to prune dedup records: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"feishu-bot/internal/utils"
+)
+
+// jsonData JSON 存储文件格式
+type jsonData struct {
+	SchemaVersion int                    `json:"schema_version"`
+	Sessions      map[string]string      `json:"sessions"`
+	Bindings      map[string]Binding     `json:"bindings"`
+	Dedup         map[string]time.Time   `json:"dedup"`
+	Messages      map[string]MessageLink `json:"messages"`
+	Runs          map[string]Run         `json:"runs"`
+	Usage         map[string]UsageRecord `json:"usage"` // day/chat_id -> 用量
+}
+
+// jsonFlushDelay 修改后延迟写盘的时长：这段时间内的修改（去重、消息对应关系、用量等）合并为一次写入
+const jsonFlushDelay = time.Second
+
+// jsonStore 基于单个 JSON 文件的存储：全部数据常驻内存，修改合并后延迟 jsonFlushDelay 原子重写整个文件，
+// Close 时写入剩余的修改（进程崩溃时可能丢失最后一次写盘之后的修改）；项目绑定和 schema 版本修改后立即写盘
+// 只适合单个实例使用；记录多、写入频繁时使用 bolt
+type jsonStore struct {
+	path string
+	mu   sync.Mutex
+	data jsonData
+
+	dirty      bool        // 是否有尚未写盘的修改
+	flushTimer *time.Timer // 等待中的延迟写盘
+	closed     bool
+}
+
+// openJSON 打开（或创建）JSON 存储
+func openJSON(path string) (*jsonStore, error) {
+	s := &jsonStore{path: path}
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("读取存储文件失败: %w", err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("解析存储文件失败: %w", err)
+		}
+	}
+
+	if s.data.Sessions == nil {
+		s.data.Sessions = make(map[string]string)
+	}
+	if s.data.Bindings == nil {
+		s.data.Bindings = make(map[string]Binding)
+	}
+	if s.data.Dedup == nil {
+		s.data.Dedup = make(map[string]time.Time)
+	}
+	if s.data.Messages == nil {
+		s.data.Messages = make(map[string]MessageLink)
+	}
+	if s.data.Runs == nil {
+		s.data.Runs = make(map[string]Run)
+	}
+	if s.data.Usage == nil {
+		s.data.Usage = make(map[string]UsageRecord)
+	}
+	return s, nil
+}
+
+// saveLocked 立即持久化（调用方需持有锁）
+func (s *jsonStore) saveLocked() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化存储失败: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, raw, 0600); err != nil {
+		return fmt.Errorf("写入存储文件失败: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// markDirtyLocked 记录有未写盘的修改，jsonFlushDelay 后统一写入（调用方需持有锁）
+func (s *jsonStore) markDirtyLocked() error {
+	s.dirty = true
+	if s.closed {
+		return s.saveLocked()
+	}
+	if s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(jsonFlushDelay, s.flush)
+	}
+	return nil
+}
+
+// flush 延迟写盘；失败时只记录日志，下一次修改后重试
+func (s *jsonStore) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flushTimer = nil
+	if !s.dirty {
+		return
+	}
+	if err := s.saveLocked(); err != nil {
+		log.Printf("[Store] Failed to flush %s: %v", s.path, err)
+	}
+}
+
+// SchemaVersion 当前 schema 版本
+func (s *jsonStore) SchemaVersion() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.SchemaVersion
+}
+
+func (s *jsonStore) setSchemaVersion(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.SchemaVersion = version
+	return s.saveLocked() // 迁移进度立即落盘
+}
+
+// GetSession 获取会话 ID
+func (s *jsonStore) GetSession(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Sessions[key], nil
+}
+
+// SetSession 保存会话 ID
+func (s *jsonStore) SetSession(key, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Sessions[key] = sessionID
+	return s.markDirtyLocked()
+}
+
+// DeleteSession 删除会话
+func (s *jsonStore) DeleteSession(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Sessions[key]; !ok {
+		return nil
+	}
+	delete(s.data.Sessions, key)
+	return s.markDirtyLocked()
+}
+
+// GetBinding 获取会话绑定
+func (s *jsonStore) GetBinding(key string) (Binding, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.data.Bindings[key]
+	if !ok {
+		return Binding{Key: key}, false, nil
+	}
+	return b.clone(), true, nil
+}
+
+// ListBindings 列出全部绑定
+func (s *jsonStore) ListBindings() ([]Binding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings := make([]Binding, 0, len(s.data.Bindings))
+	for _, b := range s.data.Bindings {
+		bindings = append(bindings, b.clone())
+	}
+	sortBindings(bindings)
+	return bindings, nil
+}
+
+// UpdateBinding 在锁内读取、修改并保存会话绑定；立即写盘，写入失败时撤销修改并返回错误
+func (s *jsonStore) UpdateBinding(key string, fn func(b *Binding) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.data.Bindings[key]
+	b := Binding{Key: key}
+	if ok {
+		b = prev.clone()
+	}
+	if err := fn(&b); err != nil {
+		return err
+	}
+	b.Key = key
+	s.data.Bindings[key] = b
+	if err := s.saveLocked(); err != nil {
+		if ok {
+			s.data.Bindings[key] = prev
+		} else {
+			delete(s.data.Bindings, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// MarkSeen 记录消息 ID，窗口内重复时返回 true；顺带清理过期记录
+func (s *jsonStore) MarkSeen(messageID string, now time.Time, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seen, ok := s.data.Dedup[messageID]; ok && now.Sub(seen) < window {
+		return true, nil
+	}
+	s.data.Dedup[messageID] = now
+	for id, seen := range s.data.Dedup {
+		if now.Sub(seen) >= window {
+			delete(s.data.Dedup, id)
+		}
+	}
+	return false, s.markDirtyLocked()
+}
+
+// PruneDedup 删除 before 之前的去重记录
+func (s *jsonStore) PruneDedup(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruned := false
+	for id, seen := range s.data.Dedup {
+		if seen.Before(before) {
+			delete(s.data.Dedup, id)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return s.markDirtyLocked()
+}
+
+// LinkMessage 记录消息所属的运行
+func (s *jsonStore) LinkMessage(messageID string, link MessageLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Messages[messageID] = link
+	return s.markDirtyLocked()
+}
+
+// LookupMessage 查找消息所属的运行
+func (s *jsonStore) LookupMessage(messageID string) (MessageLink, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.data.Messages[messageID]
+	return link, ok, nil
+}
+
+// SaveRun 保存运行记录
+func (s *jsonStore) SaveRun(run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Runs[run.ID] = run
+	return s.markDirtyLocked()
+}
+
+// GetRun 获取运行记录
+func (s *jsonStore) GetRun(runID string) (Run, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.data.Runs[runID]
+	return run, ok, nil
+}
+
+// ListRuns 列出 since 之后更新的运行
+func (s *jsonStore) ListRuns(since time.Time) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var runs []Run
+	for _, run := range s.data.Runs {
+		if !run.UpdatedAt.Before(since) {
+			runs = append(runs, run)
+		}
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// PruneRuns 删除 before 之前的运行记录和消息对应关系
+func (s *jsonStore) PruneRuns(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruned := false
+	for id, link := range s.data.Messages {
+		if link.CreatedAt.Before(before) {
+			delete(s.data.Messages, id)
+			pruned = true
+		}
+	}
+	for id, run := range s.data.Runs {
+		if run.UpdatedAt.Before(before) {
+			delete(s.data.Runs, id)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return s.markDirtyLocked()
+}
+
+// AddUsage 累加用量
+func (s *jsonStore) AddUsage(delta UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := usageKey(delta.Day, delta.ChatID)
+	record, ok := s.data.Usage[key]
+	if !ok {
+		record = UsageRecord{Day: delta.Day, ChatID: delta.ChatID}
+	}
+	record.add(delta)
+	s.data.Usage[key] = record
+	return s.markDirtyLocked()
+}
+
+// ListUsage 列出 since 之后的用量记录
+func (s *jsonStore) ListUsage(since string) ([]UsageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []UsageRecord
+	for _, record := range s.data.Usage {
+		if record.Day >= since {
+			records = append(records, record)
+		}
+	}
+	sortUsage(records)
+	return records, nil
+}
+
+// Export 导出全部数据
+func (s *jsonStore) Export() (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := newSnapshot(DriverJSON, s.data.SchemaVersion)
+	for k, v := range s.data.Sessions {
+		snapshot.Sessions[k] = v
+	}
+	for _, b := range s.data.Bindings {
+		snapshot.Bindings = append(snapshot.Bindings, b.clone())
+	}
+	for k, v := range s.data.Dedup {
+		snapshot.Dedup[k] = v
+	}
+	for k, v := range s.data.Messages {
+		snapshot.Messages[k] = v
+	}
+	for _, run := range s.data.Runs {
+		snapshot.Runs = append(snapshot.Runs, run)
+	}
+	for _, record := range s.data.Usage {
+		snapshot.Usage = append(snapshot.Usage, record)
+	}
+	sortBindings(snapshot.Bindings)
+	sortRuns(snapshot.Runs)
+	sortUsage(snapshot.Usage)
+	return snapshot, nil
+}
+
+// Import 导入快照（立即写入）
+func (s *jsonStore) Import(snapshot *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range snapshot.Sessions {
+		s.data.Sessions[k] = v
+	}
+	for _, b := range snapshot.Bindings {
+		s.data.Bindings[b.Key] = b.clone()
+	}
+	for k, v := range snapshot.Dedup {
+		s.data.Dedup[k] = v
+	}
+	for k, v := range snapshot.Messages {
+		s.data.Messages[k] = v
+	}
+	for _, run := range snapshot.Runs {
+		s.data.Runs[run.ID] = run
+	}
+	for _, record := range snapshot.Usage {
+		s.data.Usage[usageKey(record.Day, record.ChatID)] = record
+	}
+	return s.saveLocked()
+}
+
+// Close 写入尚未落盘的修改；之后的修改立即写盘
+func (s *jsonStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// sortBindings 按会话排序
+func sortBindings(bindings []Binding) {
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Key < bindings[j].Key
+	})
+}
+
+// sortRuns 按更新时间排序
+func sortRuns(runs []Run) {
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].UpdatedAt.Equal(runs[j].UpdatedAt) {
+			return runs[i].UpdatedAt.Before(runs[j].UpdatedAt)
+		}
+		return runs[i].ID < runs[j].ID
+	})
+}
+
+// sortUsage 按日期、会话排序
+func sortUsage(records []UsageRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Day != records[j].Day {
+			return records[i].Day < records[j].Day
+		}
+		return records[i].ChatID < records[j].ChatID
+	})
+}
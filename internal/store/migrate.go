@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"log"
+
+	"feishu-bot/internal/config"
+)
+
+// migratable 支持迁移的存储实现
+type migratable interface {
+	Store
+	setSchemaVersion(version int) error
+}
+
+// migration 一次 schema 迁移；新增迁移只能追加到列表末尾，版本号递增
+// 迁移通过 Store 接口操作数据，两种实现共用；各实现的存储结构（bucket、字段）在打开时创建
+type migration struct {
+	version int
+	name    string
+	up      func(s Store, opts Options) error
+}
+
+var migrations = []migration{
+	{version: 1, name: "import project bindings from the chat config", up: importLegacyBindings},
+}
+
+// latestSchemaVersion 程序支持的最新 schema 版本
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate 依次执行版本高于当前 schema 的迁移，每完成一个立即记录版本
+func migrate(s migratable, opts Options) error {
+	current := s.SchemaVersion()
+	if latest := latestSchemaVersion(); current > latest {
+		return fmt.Errorf("存储的 schema 版本 %d 高于程序支持的版本 %d，请升级程序", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		log.Printf("[Store] Migrating schema to v%d: %s", m.version, m.name)
+		if err := m.up(s, opts); err != nil {
+			return fmt.Errorf("迁移 schema v%d（%s）失败: %w", m.version, m.name, err)
+		}
+		if err := s.setSchemaVersion(m.version); err != nil {
+			return fmt.Errorf("记录 schema 版本失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// importLegacyBindings 导入旧版保存在配置文件中的项目绑定（同一会话已有绑定时覆盖）
+func importLegacyBindings(s Store, opts Options) error {
+	bindings := bindingsFromProjectPaths(opts.LegacyProjectPaths)
+	for _, binding := range bindings {
+		binding := binding
+		if err := s.UpdateBinding(binding.Key, func(b *Binding) error {
+			*b = binding
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(bindings) > 0 {
+		log.Printf("[Store] Imported %d project bindings from %s (the bindings there are no longer used)", len(bindings), config.ConfigFile)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SnapshotFormat 快照文件格式版本（与存储的 schema 版本无关）
+const SnapshotFormat = 1
+
+// Snapshot 存储的完整导出，用于备份和在两种实现之间迁移
+type Snapshot struct {
+	Format        int                    `json:"format"`
+	Driver        string                 `json:"driver"`         // 导出来源
+	SchemaVersion int                    `json:"schema_version"` // 导出来源的 schema 版本
+	ExportedAt    time.Time              `json:"exported_at"`
+	Sessions      map[string]string      `json:"sessions"`
+	Bindings      []Binding              `json:"bindings"`
+	Dedup         map[string]time.Time   `json:"dedup"`
+	Messages      map[string]MessageLink `json:"messages"`
+	Runs          []Run                  `json:"runs"`
+	Usage         []UsageRecord          `json:"usage"`
+}
+
+// newSnapshot 创建空快照
+func newSnapshot(driver string, schemaVersion int) *Snapshot {
+	return &Snapshot{
+		Format:        SnapshotFormat,
+		Driver:        driver,
+		SchemaVersion: schemaVersion,
+		ExportedAt:    time.Now(),
+		Sessions:      make(map[string]string),
+		Dedup:         make(map[string]time.Time),
+		Messages:      make(map[string]MessageLink),
+	}
+}
+
+// WriteSnapshot 以缩进 JSON 写出快照
+func WriteSnapshot(w io.Writer, snapshot *Snapshot) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
+
+// ReadSnapshot 读取快照并检查格式版本
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("解析快照失败: %w", err)
+	}
+	if snapshot.Format != SnapshotFormat {
+		return nil, fmt.Errorf("不支持的快照格式版本: %d", snapshot.Format)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,273 @@
+// Package store 机器人运行状态的持久化：Claude 会话、项目绑定、消息去重记录、运行记录（出站消息 -> 运行 -> 会话）和用量统计
+//
+// 提供两种实现：JSON 文件（默认，便于查看和手工修改）和 bbolt 嵌入式数据库（记录多、写入频繁时使用）。
+// 打开时自动执行 schema 迁移；两种实现之间可以通过 storectl export / import 迁移数据。
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// 存储实现
+const (
+	DriverJSON = "json"
+	DriverBolt = "bolt"
+)
+
+// 默认存储文件路径
+const (
+	DefaultJSONFile = "data/state.json"
+	DefaultBoltFile = "data/state.db"
+)
+
+// Sessions 会话 key（单聊用户、群聊共享会话等，见 handlers 的 session_scope）-> Claude 会话 ID
+type Sessions interface {
+	// GetSession 获取会话 ID，不存在时返回空字符串
+	GetSession(key string) (string, error)
+	SetSession(key, sessionID string) error
+	DeleteSession(key string) error
+}
+
+// 绑定变更类型
+const (
+	BindingActionBind   = "bind"
+	BindingActionUnbind = "unbind"
+)
+
+// maxBindingHistory 每个会话保留的绑定变更记录条数
+const maxBindingHistory = 10
+
+// Binding 会话（群聊 chat_id 或单聊用户 open_id）的项目绑定
+type Binding struct {
+	Key         string          `json:"key"`
+	User        bool            `json:"user,omitempty"`         // 单聊用户的绑定
+	ProjectPath string          `json:"project_path,omitempty"` // 主项目（Claude 的工作目录），为空表示未绑定
+	AddDirs     []string        `json:"add_dirs,omitempty"`     // 附加目录（以 --add-dir 传给 Claude）
+	UpdatedBy   string          `json:"updated_by,omitempty"`   // 最后修改者 open_id
+	UpdatedAt   time.Time       `json:"updated_at"`
+	History     []BindingChange `json:"history,omitempty"` // 最近的变更（新的在后），解绑后仍保留
+}
+
+// BindingChange 一次绑定变更
+type BindingChange struct {
+	Action  string    `json:"action"` // bind / unbind
+	Path    string    `json:"path,omitempty"`
+	AddDirs []string  `json:"add_dirs,omitempty"`
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+}
+
+// Bind 绑定项目路径和附加目录，并记录变更者
+func (b *Binding) Bind(user bool, projectPath string, addDirs []string, by string, at time.Time) {
+	b.User = user
+	b.ProjectPath = projectPath
+	b.AddDirs = append([]string(nil), addDirs...)
+	b.record(BindingChange{Action: BindingActionBind, Path: projectPath, AddDirs: b.AddDirs, By: by, At: at})
+}
+
+// Unbind 解除绑定并记录变更者，未绑定时返回 false
+func (b *Binding) Unbind(by string, at time.Time) bool {
+	if b.ProjectPath == "" {
+		return false
+	}
+	path := b.ProjectPath
+	b.ProjectPath, b.AddDirs = "", nil
+	b.record(BindingChange{Action: BindingActionUnbind, Path: path, By: by, At: at})
+	return true
+}
+
+// clone 深拷贝（AddDirs、History 不与原绑定共用底层数组）
+func (b Binding) clone() Binding {
+	b.AddDirs = append([]string(nil), b.AddDirs...)
+	if b.History != nil {
+		history := make([]BindingChange, len(b.History))
+		for i, change := range b.History {
+			change.AddDirs = append([]string(nil), change.AddDirs...)
+			history[i] = change
+		}
+		b.History = history
+	}
+	return b
+}
+
+// record 追加变更记录，只保留最近 maxBindingHistory 条
+func (b *Binding) record(change BindingChange) {
+	b.UpdatedBy = change.By
+	b.UpdatedAt = change.At
+	b.History = append(b.History, change)
+	if len(b.History) > maxBindingHistory {
+		b.History = b.History[len(b.History)-maxBindingHistory:]
+	}
+}
+
+// Bindings 会话的项目绑定
+type Bindings interface {
+	// GetBinding 获取会话绑定，不存在时返回 false（返回只有 Key 的空绑定）
+	GetBinding(key string) (Binding, bool, error)
+	// ListBindings 列出全部绑定（包括解绑后只剩变更记录的），按 Key 排序
+	ListBindings() ([]Binding, error)
+	// UpdateBinding 在同一把锁 / 同一事务内读取、修改并保存会话绑定，并发修改不会互相覆盖
+	// 不存在时 fn 收到只有 Key 的空绑定；fn 返回错误时不保存
+	UpdateBinding(key string, fn func(b *Binding) error) error
+}
+
+// bindingsFromProjectPaths 把旧版配置文件中的项目绑定（会话 ID -> 项目路径）转换为存储中的绑定
+func bindingsFromProjectPaths(paths map[string]string) []Binding {
+	bindings := make([]Binding, 0, len(paths))
+	for key, path := range paths {
+		bindings = append(bindings, Binding{Key: key, ProjectPath: path})
+	}
+	sortBindings(bindings)
+	return bindings
+}
+
+// Dedup 已处理的消息 ID，防止飞书重推导致同一条消息被处理多次
+type Dedup interface {
+	// MarkSeen 记录消息 ID；window 内已记录过时返回 true（不刷新记录时间）
+	MarkSeen(messageID string, now time.Time, window time.Duration) (bool, error)
+	// PruneDedup 删除 before 之前的记录
+	PruneDedup(before time.Time) error
+}
+
+// Run 一次 Claude 运行（ID 为触发消息的 message_id）
+type Run struct {
+	ID         string    `json:"id"`
+	ChatID     string    `json:"chat_id,omitempty"` // 群聊 chat_id 或单聊用户 open_id
+	OpenID     string    `json:"open_id,omitempty"` // 提问者
+	SessionID  string    `json:"session_id,omitempty"`
+	ProjectDir string    `json:"project_dir,omitempty"`
+	IsError    bool      `json:"is_error,omitempty"`
+	CostUSD    float64   `json:"cost_usd,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	NumTurns   int       `json:"num_turns,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// MessageLink 机器人发出的消息属于哪次运行
+type MessageLink struct {
+	RunID     string    `json:"run_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Runs 运行记录，以及出站消息与运行的对应关系（回复机器人消息时据此恢复会话）
+type Runs interface {
+	LinkMessage(messageID string, link MessageLink) error
+	// LookupMessage 查找消息所属的运行，不存在时返回 false
+	LookupMessage(messageID string) (MessageLink, bool, error)
+	// SaveRun 保存运行记录（按 ID 覆盖）
+	SaveRun(run Run) error
+	// GetRun 获取运行记录，不存在时返回 false
+	GetRun(runID string) (Run, bool, error)
+	// ListRuns 列出 since 之后更新的运行（按更新时间排序）
+	ListRuns(since time.Time) ([]Run, error)
+	// PruneRuns 删除 before 之前的运行记录和消息对应关系
+	PruneRuns(before time.Time) error
+}
+
+// UsageRecord 某天某个会话（群聊 chat_id 或单聊用户 open_id）的用量
+type UsageRecord struct {
+	Day        string  `json:"day"` // 2006-01-02（本地时区）
+	ChatID     string  `json:"chat_id"`
+	Runs       int     `json:"runs"`
+	Errors     int     `json:"errors,omitempty"`
+	CostUSD    float64 `json:"cost_usd"`
+	DurationMs int64   `json:"duration_ms"`
+	NumTurns   int     `json:"num_turns"`
+}
+
+// add 累加另一条记录的用量
+func (u *UsageRecord) add(delta UsageRecord) {
+	u.Runs += delta.Runs
+	u.Errors += delta.Errors
+	u.CostUSD += delta.CostUSD
+	u.DurationMs += delta.DurationMs
+	u.NumTurns += delta.NumTurns
+}
+
+// usageKey 用量记录的主键
+func usageKey(day, chatID string) string {
+	return day + "/" + chatID
+}
+
+// UsageFromRun 由运行结果生成当天的用量增量
+func UsageFromRun(run Run) UsageRecord {
+	record := UsageRecord{
+		Day:        run.UpdatedAt.Format("2006-01-02"),
+		ChatID:     run.ChatID,
+		Runs:       1,
+		CostUSD:    run.CostUSD,
+		DurationMs: run.DurationMs,
+		NumTurns:   run.NumTurns,
+	}
+	if run.IsError {
+		record.Errors = 1
+	}
+	return record
+}
+
+// Usage 按天、按会话累计的用量
+type Usage interface {
+	// AddUsage 把增量累加到 (Day, ChatID) 对应的记录上
+	AddUsage(delta UsageRecord) error
+	// ListUsage 列出 since（含，格式 2006-01-02，空表示全部）之后的记录，按日期和会话排序
+	ListUsage(since string) ([]UsageRecord, error)
+}
+
+// Store 运行状态存储
+type Store interface {
+	Sessions
+	Bindings
+	Dedup
+	Runs
+	Usage
+
+	// SchemaVersion 当前 schema 版本
+	SchemaVersion() int
+	// Export 导出全部数据
+	Export() (*Snapshot, error)
+	// Import 导入快照（同 key 的记录被覆盖，用量按记录覆盖而不是累加）
+	Import(snapshot *Snapshot) error
+	Close() error
+}
+
+// Options 打开存储的参数
+type Options struct {
+	Driver string // json（默认）/ bolt
+	Path   string // 为空时使用对应实现的默认路径
+	// LegacyProjectPaths 旧版保存在 configs/chat_config.json 中的项目绑定，迁移到 schema v1 时导入（为空时跳过）
+	LegacyProjectPaths map[string]string
+}
+
+// Open 按 Driver 打开存储并执行尚未执行的迁移
+func Open(opts Options) (Store, error) {
+	var (
+		s   migratable
+		err error
+	)
+	switch opts.Driver {
+	case "", DriverJSON:
+		s, err = openJSON(pathOrDefault(opts.Path, DefaultJSONFile))
+	case DriverBolt:
+		s, err = openBolt(pathOrDefault(opts.Path, DefaultBoltFile))
+	default:
+		return nil, fmt.Errorf("未知的存储类型: %s（可选 %s / %s）", opts.Driver, DriverJSON, DriverBolt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(s, opts); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// pathOrDefault path 为空时返回默认路径
+func pathOrDefault(path, def string) string {
+	if path == "" {
+		return def
+	}
+	return path
+}
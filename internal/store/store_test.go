@@ -0,0 +1,438 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backends 两种实现使用同一组测试
+var backends = []struct {
+	driver string
+	file   string
+}{
+	{DriverJSON, "state.json"},
+	{DriverBolt, "state.db"},
+}
+
+// t0 测试用的固定时间（UTC，经过 JSON / bbolt 往返后仍可直接比较）
+var t0 = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// openStore 在临时目录中打开存储，测试结束时关闭
+func openStore(t *testing.T, driver, path string, opts Options) Store {
+	t.Helper()
+	opts.Driver, opts.Path = driver, path
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open(%s) error: %v", driver, err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// forEachBackend 对每种实现运行 fn，传入新建的空存储
+func forEachBackend(t *testing.T, fn func(t *testing.T, s Store)) {
+	for _, b := range backends {
+		t.Run(b.driver, func(t *testing.T) {
+			fn(t, openStore(t, b.driver, filepath.Join(t.TempDir(), b.file), Options{}))
+		})
+	}
+}
+
+func TestMarkSeenWindow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		steps := []struct {
+			name string
+			at   time.Duration // 相对 t0
+			want bool
+		}{
+			{"first", 0, false},
+			{"within window", 30 * time.Second, true},
+			{"still counted from first", 50 * time.Second, true},
+			{"after window", 70 * time.Second, false},
+			{"within new window", 90 * time.Second, true},
+		}
+		for _, step := range steps {
+			got, err := s.MarkSeen("om_1", t0.Add(step.at), time.Minute)
+			if err != nil {
+				t.Fatalf("%s: MarkSeen error: %v", step.name, err)
+			}
+			if got != step.want {
+				t.Fatalf("%s: MarkSeen() = %v, want %v", step.name, got, step.want)
+			}
+		}
+
+		if dup, _ := s.MarkSeen("om_2", t0.Add(90*time.Second), time.Minute); dup {
+			t.Fatal("MarkSeen(om_2) reported a duplicate for a new message")
+		}
+	})
+}
+
+func TestPruneRuns(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		old, recent := t0.Add(-2*time.Hour), t0
+		for _, run := range []Run{{ID: "old", UpdatedAt: old}, {ID: "recent", UpdatedAt: recent}} {
+			if err := s.SaveRun(run); err != nil {
+				t.Fatal(err)
+			}
+		}
+		links := map[string]MessageLink{
+			"om_old":    {RunID: "old", CreatedAt: old},
+			"om_recent": {RunID: "recent", CreatedAt: recent},
+		}
+		for id, link := range links {
+			if err := s.LinkMessage(id, link); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.PruneRuns(t0.Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok, _ := s.GetRun("old"); ok {
+			t.Error("old run was not pruned")
+		}
+		if _, ok, _ := s.LookupMessage("om_old"); ok {
+			t.Error("old message link was not pruned")
+		}
+		if run, ok, _ := s.GetRun("recent"); !ok || !run.UpdatedAt.Equal(recent) {
+			t.Errorf("GetRun(recent) = %+v, %v, want the recent run", run, ok)
+		}
+		if link, ok, _ := s.LookupMessage("om_recent"); !ok || link.RunID != "recent" {
+			t.Errorf("LookupMessage(om_recent) = %+v, %v, want run recent", link, ok)
+		}
+		runs, err := s.ListRuns(time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) != 1 || runs[0].ID != "recent" {
+			t.Errorf("ListRuns() = %+v, want only the recent run", runs)
+		}
+	})
+}
+
+func TestAddUsageAccumulates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		deltas := []UsageRecord{
+			{Day: "2024-05-01", ChatID: "oc_1", Runs: 1, CostUSD: 0.25, DurationMs: 1000, NumTurns: 2},
+			{Day: "2024-05-01", ChatID: "oc_1", Runs: 1, Errors: 1, CostUSD: 0.5, DurationMs: 500, NumTurns: 1},
+			{Day: "2024-05-01", ChatID: "oc_2", Runs: 1, CostUSD: 1, DurationMs: 10, NumTurns: 1},
+		}
+		for _, delta := range deltas {
+			if err := s.AddUsage(delta); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := s.ListUsage("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []UsageRecord{
+			{Day: "2024-05-01", ChatID: "oc_1", Runs: 2, Errors: 1, CostUSD: 0.75, DurationMs: 1500, NumTurns: 3},
+			{Day: "2024-05-01", ChatID: "oc_2", Runs: 1, CostUSD: 1, DurationMs: 10, NumTurns: 1},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ListUsage() = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestListUsageOrder(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		// 乱序写入
+		for _, key := range []string{"2024-05-02/oc_b", "2024-04-30/oc_a", "2024-05-02/oc_a", "2024-05-01/oc_c"} {
+			day, chatID, _ := strings.Cut(key, "/")
+			if err := s.AddUsage(UsageRecord{Day: day, ChatID: chatID, Runs: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			since string
+			want  []string
+		}{
+			{"", []string{"2024-04-30/oc_a", "2024-05-01/oc_c", "2024-05-02/oc_a", "2024-05-02/oc_b"}},
+			{"2024-05-01", []string{"2024-05-01/oc_c", "2024-05-02/oc_a", "2024-05-02/oc_b"}},
+			{"2024-05-03", nil},
+		}
+		for _, tt := range tests {
+			records, err := s.ListUsage(tt.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, record := range records {
+				got = append(got, usageKey(record.Day, record.ChatID))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListUsage(%q) = %v, want %v", tt.since, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestBindings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		bind := func(key string, user bool, path string, addDirs []string, at time.Time) {
+			t.Helper()
+			if err := s.UpdateBinding(key, func(b *Binding) error {
+				b.Bind(user, path, addDirs, "ou_admin", at)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		bind("oc_2", false, "/p/b", nil, t0)
+		bind("oc_1", false, "/p/a", []string{"/p/c"}, t0)
+		bind("ou_1", true, "/p/a", nil, t0.Add(time.Minute))
+
+		// 解绑后保留变更记录
+		if err := s.UpdateBinding("oc_2", func(b *Binding) error {
+			if !b.Unbind("ou_other", t0.Add(time.Hour)) {
+				t.Error("Unbind() = false for a bound chat")
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		b, ok, err := s.GetBinding("oc_2")
+		if err != nil || !ok {
+			t.Fatalf("GetBinding(oc_2) = %v, %v", ok, err)
+		}
+		if b.ProjectPath != "" || b.UpdatedBy != "ou_other" || len(b.History) != 2 || b.History[1].Action != BindingActionUnbind {
+			t.Errorf("GetBinding(oc_2) = %+v, want unbound with bind and unbind history", b)
+		}
+
+		// fn 返回错误时不保存
+		if err := s.UpdateBinding("oc_missing", func(b *Binding) error {
+			if b.Unbind("ou_admin", t0) {
+				t.Error("Unbind() = true for a chat that was never bound")
+			}
+			return os.ErrNotExist
+		}); err != os.ErrNotExist {
+			t.Fatalf("UpdateBinding() error = %v, want the error returned by fn", err)
+		}
+		if b, ok, _ := s.GetBinding("oc_missing"); ok || b.Key != "oc_missing" {
+			t.Errorf("GetBinding(oc_missing) = %+v, %v, want an empty binding", b, ok)
+		}
+
+		bindings, err := s.ListBindings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, b := range bindings {
+			keys = append(keys, b.Key)
+		}
+		if want := []string{"oc_1", "oc_2", "ou_1"}; !reflect.DeepEqual(keys, want) {
+			t.Fatalf("ListBindings() keys = %v, want %v", keys, want)
+		}
+		if b := bindings[0]; b.ProjectPath != "/p/a" || !reflect.DeepEqual(b.AddDirs, []string{"/p/c"}) || b.User {
+			t.Errorf("binding oc_1 = %+v", b)
+		}
+		if b := bindings[2]; b.ProjectPath != "/p/a" || !b.User || !b.UpdatedAt.Equal(t0.Add(time.Minute)) {
+			t.Errorf("binding ou_1 = %+v", b)
+		}
+	})
+}
+
+func TestBindingHistoryLimit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		for i := 0; i < maxBindingHistory+5; i++ {
+			if err := s.UpdateBinding("oc_1", func(b *Binding) error {
+				b.Bind(false, "/p/a", nil, "ou_admin", t0.Add(time.Duration(i)*time.Minute))
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, _, err := s.GetBinding("oc_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.History) != maxBindingHistory || !b.History[len(b.History)-1].At.Equal(b.UpdatedAt) {
+			t.Fatalf("History has %d entries (last %v, updated %v), want the latest %d",
+				len(b.History), b.History[len(b.History)-1].At, b.UpdatedAt, maxBindingHistory)
+		}
+	})
+}
+
+func TestJSONUpdateBindingWritesImmediately(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	s, err := openJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	bind := func(path string) error {
+		return s.UpdateBinding("oc_1", func(b *Binding) error {
+			b.Bind(false, path, nil, "ou_admin", t0)
+			return nil
+		})
+	}
+	if err := bind("/p/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不等待延迟写盘：另一份实例立即读到绑定
+	reopened, err := openJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok, _ := reopened.GetBinding("oc_1"); !ok || b.ProjectPath != "/p/a" {
+		t.Fatalf("GetBinding(oc_1) from disk = %+v, %v, want /p/a", b, ok)
+	}
+
+	// 写盘失败时返回错误并撤销修改
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.path = filepath.Join(blocker, "state.json")
+	if err := bind("/p/b"); err == nil {
+		t.Fatal("UpdateBinding() error = nil, want the write error")
+	}
+	if b, _, _ := s.GetBinding("oc_1"); b.ProjectPath != "/p/a" {
+		t.Fatalf("GetBinding(oc_1) after failed write = %+v, want /p/a", b)
+	}
+	s.path = path
+}
+
+// fill 写入每种记录各若干条
+func fill(t *testing.T, s Store) {
+	t.Helper()
+	steps := []error{
+		s.SetSession("ou_1", "session-1"),
+		s.SetSession("global", "session-2"),
+		s.UpdateBinding("oc_1", func(b *Binding) error {
+			b.Bind(false, "/p/a", []string{"/p/b"}, "ou_1", t0)
+			return nil
+		}),
+		s.LinkMessage("om_bot", MessageLink{RunID: "om_user", CreatedAt: t0}),
+		s.SaveRun(Run{ID: "om_user", ChatID: "oc_1", OpenID: "ou_1", SessionID: "session-2", CostUSD: 0.5, UpdatedAt: t0}),
+		s.SaveRun(Run{ID: "om_other", ChatID: "ou_1", IsError: true, UpdatedAt: t0.Add(time.Minute)}),
+		s.AddUsage(UsageRecord{Day: "2024-05-01", ChatID: "oc_1", Runs: 3, CostUSD: 1.5}),
+		s.AddUsage(UsageRecord{Day: "2024-04-30", ChatID: "ou_1", Runs: 1, Errors: 1}),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.MarkSeen("om_user", t0, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, from := range backends {
+		for _, to := range backends {
+			t.Run(from.driver+"_to_"+to.driver, func(t *testing.T) {
+				src := openStore(t, from.driver, filepath.Join(t.TempDir(), from.file), Options{})
+				fill(t, src)
+				exported, err := src.Export()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var buf bytes.Buffer
+				if err := WriteSnapshot(&buf, exported); err != nil {
+					t.Fatal(err)
+				}
+				snapshot, err := ReadSnapshot(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dst := openStore(t, to.driver, filepath.Join(t.TempDir(), to.file), Options{})
+				if err := dst.Import(snapshot); err != nil {
+					t.Fatal(err)
+				}
+				got, err := dst.Export()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// 导出来源和时间不同，其余应完全一致
+				got.Driver, got.ExportedAt = exported.Driver, exported.ExportedAt
+				if !reflect.DeepEqual(got, exported) {
+					t.Fatalf("round trip mismatch\n got: %+v\nwant: %+v", got, exported)
+				}
+			})
+		}
+	}
+}
+
+func TestImportPersists(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), b.file)
+			src := openStore(t, b.driver, filepath.Join(t.TempDir(), b.file), Options{})
+			fill(t, src)
+			snapshot, err := src.Export()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := Open(Options{Driver: b.driver, Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Import(snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened := openStore(t, b.driver, path, Options{})
+			if sessionID, _ := reopened.GetSession("ou_1"); sessionID != "session-1" {
+				t.Errorf("GetSession(ou_1) after reopen = %q, want session-1", sessionID)
+			}
+			if binding, ok, _ := reopened.GetBinding("oc_1"); !ok || binding.ProjectPath != "/p/a" {
+				t.Errorf("GetBinding(oc_1) after reopen = %+v, %v", binding, ok)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyProjectPaths(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), b.file)
+			legacy := map[string]string{"oc_2": "/p/b", "oc_1": "/p/a"}
+			s := openStore(t, b.driver, path, Options{LegacyProjectPaths: legacy})
+			got, err := s.ListBindings()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Binding{{Key: "oc_1", ProjectPath: "/p/a"}, {Key: "oc_2", ProjectPath: "/p/b"}}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("ListBindings() = %+v, want %+v", got, want)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 迁移只执行一次：之后配置文件中的旧绑定不再导入
+			legacy["oc_1"] = "/p/changed"
+			reopened := openStore(t, b.driver, path, Options{LegacyProjectPaths: legacy})
+			if binding, _, _ := reopened.GetBinding("oc_1"); binding.ProjectPath != "/p/a" {
+				t.Errorf("GetBinding(oc_1) after reopen = %+v, want the originally imported binding", binding)
+			}
+		})
+	}
+}
+
+func TestReadSnapshotFormat(t *testing.T) {
+	if _, err := ReadSnapshot(strings.NewReader(`{"format": 99}`)); err == nil {
+		t.Error("ReadSnapshot() accepted an unknown format")
+	}
+}
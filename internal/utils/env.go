@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// GetEnvOrDefault 获取环境变量，如果不存在则返回默认值
+func GetEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvBool 读取开关型环境变量：true/1/on/yes 为开，false/0/off/no 为关，未设置或无法识别时返回默认值
+func GetEnvBool(key string, defaultValue bool) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "true", "1", "on", "yes":
+		return true
+	case "false", "0", "off", "no":
+		return false
+	default:
+		return defaultValue
+	}
+}
@@ -11,6 +11,7 @@ type TimeoutConfig struct {
 	StreamIdleTimeout   time.Duration // 空闲超时：N毫秒无新数据则发送
 	StreamMaxDuration   time.Duration // 最大持续时间：连续输出N秒后强制分段
 	StreamMaxBufferSize int           // 最大缓冲区大小：超过此大小强制分段
+	LongAnswerThreshold int           // 超长回答阈值：回答超过此字符数后改为文件/云文档投递
 
 	// 进程管理超时
 	ProcessWaitTimeout time.Duration // 等待进程退出的超时时间
@@ -26,6 +27,7 @@ func DefaultTimeoutConfig() *TimeoutConfig {
 		StreamIdleTimeout:   8 * time.Second,  // 8秒无新数据则发送（减少API调用）
 		StreamMaxDuration:   20 * time.Second, // 20秒连续输出后强制分段
 		StreamMaxBufferSize: 30000,           // 最大30000字符（防止超过飞书150KB限制）
+		LongAnswerThreshold: 90000,           // 超过90000字符改为附件投递
 
 		// 进程管理：5秒
 		ProcessWaitTimeout: 5 * time.Second,